@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"cmp"
 	"fmt"
+	"maps"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/semafind/semadb/distance"
 	"github.com/semafind/semadb/models"
 	"github.com/semafind/semadb/utils"
 )
@@ -76,10 +79,34 @@ func (c *ClusterNode) GetCollection(userId string, collectionId string) (models.
 	return rpcResp.Collection, nil
 }
 
+func (c *ClusterNode) SetCollectionSynonyms(col models.Collection, synonyms [][]string) error {
+	// ---------------------------
+	rpcReq := RPCSetCollectionSynonymsRequest{
+		RPCRequestArgs: RPCRequestArgs{
+			Source: c.MyHostname,
+			Dest:   RendezvousHash(col.UserId, c.Servers, 1)[0],
+		},
+		UserId:       col.UserId,
+		CollectionId: col.Id,
+		Synonyms:     synonyms,
+	}
+	rpcResp := RPCSetCollectionSynonymsResponse{}
+	if err := c.RPCSetCollectionSynonyms(&rpcReq, &rpcResp); err != nil {
+		return fmt.Errorf("could not set collection synonyms: %w", err)
+	}
+	// ---------------------------
+	if rpcResp.NotFound {
+		return ErrNotFound
+	}
+	// ---------------------------
+	return nil
+}
+
 type shardInfo struct {
-	Id         string
-	Size       int64
-	PointCount int64
+	Id                 string
+	Size               int64
+	PointCount         int64
+	VamanaCalibrations map[string]models.VamanaCalibration
 }
 
 func (c *ClusterNode) GetShardsInfo(col models.Collection) ([]shardInfo, error) {
@@ -103,9 +130,10 @@ func (c *ClusterNode) GetShardsInfo(col models.Collection) ([]shardInfo, error)
 		}
 		// ---------------------------
 		si := shardInfo{
-			Id:         shardId,
-			Size:       getInfoResponse.Size,
-			PointCount: getInfoResponse.PointCount,
+			Id:                 shardId,
+			Size:               getInfoResponse.Size,
+			PointCount:         getInfoResponse.PointCount,
+			VamanaCalibrations: getInfoResponse.VamanaCalibrations,
 		}
 		shards = append(shards, si)
 	}
@@ -178,7 +206,7 @@ type FailedRange struct {
 	Err     string `json:"error"`
 }
 
-func (c *ClusterNode) InsertPoints(col models.Collection, points []models.Point) ([]FailedRange, error) {
+func (c *ClusterNode) InsertPoints(col models.Collection, points []models.Point, bulk bool) ([]FailedRange, error) {
 	// ---------------------------
 	// This is where shard distribution happens
 	shards, err := c.GetShardsInfo(col)
@@ -241,6 +269,7 @@ func (c *ClusterNode) InsertPoints(col models.Collection, points []models.Point)
 				Collection: col,
 				ShardId:    sId,
 				Points:     shardPoints,
+				Bulk:       bulk,
 			}
 			insertResp := RPCInsertPointsResponse{}
 			if err := c.RPCInsertPoints(&insertReq, &insertResp); err != nil {
@@ -272,7 +301,92 @@ func (c *ClusterNode) InsertPoints(col models.Collection, points []models.Point)
 const poissonApproxA = 1.42
 const poissonApproxB = 10.0
 
-func (c *ClusterNode) SearchPoints(col models.Collection, sr models.SearchRequest) ([]models.SearchResult, error) {
+// SearchPoints searches every shard of the collection and merges the results.
+// The profile is nil unless the search request asks to profile.
+func (c *ClusterNode) SearchPoints(col models.Collection, sr models.SearchRequest) ([]models.SearchResult, *models.SearchProfile, error) {
+	// ---------------------------
+	originalLimit := sr.Limit
+	sr, err := c.prepareSearch(col, sr)
+	if err != nil {
+		return nil, nil, err
+	}
+	// ---------------------------
+	/* Search every shard in parallel. If a shard is unavailable, we will simply
+	 * ignore it for now to keep the search request alive. This is not a major
+	 * problem especially for approximate nearest neighbour based search
+	 * requests. */
+	results := make([]models.SearchResult, 0, len(col.ShardIds)*10)
+	var profile *models.SearchProfile
+	if sr.Profile {
+		profile = &models.SearchProfile{Shards: make([]models.ShardProfile, 0, len(col.ShardIds))}
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var searchErr error
+	var errOnce sync.Once
+	for _, shardId := range col.ShardIds {
+		wg.Add(1)
+		go func(sId string) {
+			defer wg.Done()
+			targetServer := RendezvousHash(sId, c.Servers, 1)[0]
+			// ---------------------------
+			searchReq := RPCSearchPointsRequest{
+				RPCRequestArgs: RPCRequestArgs{
+					Source: c.MyHostname,
+					Dest:   targetServer,
+				},
+				Collection:    col,
+				ShardId:       sId,
+				SearchRequest: sr,
+			}
+			searchResp := RPCSearchPointsResponse{}
+			startTime := time.Now()
+			if err := c.RPCSearchPoints(&searchReq, &searchResp); err != nil {
+				errOnce.Do(func() {
+					// If we encounter an error, we only want to report it once.
+					searchErr = fmt.Errorf("shard could not search points: %w", err)
+				})
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not search points")
+			} else {
+				// Alternatively we can stream the results into a channel and
+				// loop over. This is more straightforward for now.
+				mu.Lock()
+				results = append(results, searchResp.Points...)
+				if profile != nil && searchResp.Profile != nil {
+					searchResp.Profile.LatencyMs = time.Since(startTime).Seconds() * 1000
+					profile.Shards = append(profile.Shards, *searchResp.Profile)
+				}
+				mu.Unlock()
+			}
+		}(shardId)
+	}
+	// ---------------------------
+	wg.Wait()
+	if searchErr != nil {
+		return nil, nil, searchErr
+	}
+	results, err = mergeSearchResults(col, sr, originalLimit, results)
+	if err != nil {
+		return nil, nil, err
+	}
+	// ---------------------------
+	return results, profile, nil
+}
+
+// prepareSearch resolves everything the shards need to search on their own
+// and sets the limit and offset of the search for each shard.
+func (c *ClusterNode) prepareSearch(col models.Collection, sr models.SearchRequest) (models.SearchRequest, error) {
+	// Similar points can be in any shard, so each shard needs the terms of
+	// the source point before searching.
+	if err := c.resolveMoreLikeThis(col, &sr.Query); err != nil {
+		return sr, fmt.Errorf("could not resolve moreLikeThis: %w", err)
+	}
+	// The examples of a recommendation can be in any shard as well
+	if err := c.resolveRecommend(col, &sr.Query); err != nil {
+		return sr, fmt.Errorf("could not resolve recommend: %w", err)
+	}
+	// Every shard has to decay scores from the same time
+	sr.ScoreFunctions = resolveScoreOrigins(sr.ScoreFunctions)
 	// ---------------------------
 	/* Here we calculate the target limit for each shard. We want to reduce the
 	 * number of points discarded. For example, 5 chards with a limit of 100
@@ -288,7 +402,6 @@ func (c *ClusterNode) SearchPoints(col models.Collection, sr models.SearchReques
 	 * computation required. The inverse of Poisson CDF doesn't have a closed
 	 * form, so we use a linear approximation for our expected operational ranges
 	 * for lambda. */
-	originalLimit := sr.Limit
 	targetLimit := int(float32(sr.Limit)*(1/float32(len(col.ShardIds)))*poissonApproxA + poissonApproxB)
 	if targetLimit > c.cfg.MaxSearchLimit {
 		targetLimit = c.cfg.MaxSearchLimit
@@ -308,57 +421,150 @@ func (c *ClusterNode) SearchPoints(col models.Collection, sr models.SearchReques
 	if len(col.ShardIds) > 1 && sr.Offset%len(col.ShardIds) == 0 {
 		sr.Offset = sr.Offset / len(col.ShardIds)
 	}
+	return sr, nil
+}
+
+// SearchPointsBatch runs multiple searches with a single request to each shard
+// instead of a request per search. The results and errors are in the order of
+// the searches and a failing search does not affect the others.
+func (c *ClusterNode) SearchPointsBatch(col models.Collection, srs []models.SearchRequest) ([][]models.SearchResult, []error) {
+	// ---------------------------
+	results := make([][]models.SearchResult, len(srs))
+	errs := make([]error, len(srs))
+	/* Only the searches that could be prepared are sent to the shards, so we
+	 * keep track of which search each shard request belongs to. */
+	shardSearches := make([]models.SearchRequest, 0, len(srs))
+	searchIndices := make([]int, 0, len(srs))
+	for i, sr := range srs {
+		prepared, err := c.prepareSearch(col, sr)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		shardSearches = append(shardSearches, prepared)
+		searchIndices = append(searchIndices, i)
+	}
+	if len(shardSearches) == 0 {
+		return results, errs
+	}
 	// ---------------------------
-	/* Search every shard in parallel. If a shard is unavailable, we will simply
-	 * ignore it for now to keep the search request alive. This is not a major
-	 * problem especially for approximate nearest neighbour based search
-	 * requests. */
-	results := make([]models.SearchResult, 0, len(col.ShardIds)*10)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	var searchErr error
+	for _, shardId := range col.ShardIds {
+		wg.Add(1)
+		go func(sId string) {
+			defer wg.Done()
+			searchReq := RPCSearchPointsBatchRequest{
+				RPCRequestArgs: RPCRequestArgs{
+					Source: c.MyHostname,
+					Dest:   RendezvousHash(sId, c.Servers, 1)[0],
+				},
+				Collection:     col,
+				ShardId:        sId,
+				SearchRequests: shardSearches,
+			}
+			searchResp := RPCSearchPointsBatchResponse{}
+			err := c.RPCSearchPointsBatch(&searchReq, &searchResp)
+			if err == nil && (len(searchResp.Points) != len(shardSearches) || len(searchResp.Errors) != len(shardSearches)) {
+				err = fmt.Errorf("expected %d batch results, got %d", len(shardSearches), len(searchResp.Errors))
+			}
+			if err != nil {
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not batch search points")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for j, i := range searchIndices {
+				switch {
+				case errs[i] != nil:
+					// Only the first error of a search is reported
+				case err != nil:
+					errs[i] = fmt.Errorf("shard could not search points: %w", err)
+				case searchResp.Errors[j] != "":
+					errs[i] = fmt.Errorf("shard could not search points: %s", searchResp.Errors[j])
+				default:
+					results[i] = append(results[i], searchResp.Points[j]...)
+				}
+			}
+		}(shardId)
+	}
+	wg.Wait()
+	// ---------------------------
+	for j, i := range searchIndices {
+		if errs[i] != nil {
+			results[i] = nil
+			continue
+		}
+		results[i], errs[i] = mergeSearchResults(col, shardSearches[j], srs[i].Limit, results[i])
+	}
+	return results, errs
+}
+
+// CountPoints adds up the number of points matching the query in every shard.
+// Each point lives in a single shard so the counts do not overlap.
+func (c *ClusterNode) CountPoints(col models.Collection, req models.CountRequest) (uint64, error) {
+	// ---------------------------
+	if err := c.resolveMoreLikeThis(col, &req.Query); err != nil {
+		return 0, fmt.Errorf("could not resolve moreLikeThis: %w", err)
+	}
+	if err := c.resolveRecommend(col, &req.Query); err != nil {
+		return 0, fmt.Errorf("could not resolve recommend: %w", err)
+	}
+	// ---------------------------
+	var total uint64
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var countErr error
 	var errOnce sync.Once
 	for _, shardId := range col.ShardIds {
 		wg.Add(1)
 		go func(sId string) {
 			defer wg.Done()
-			targetServer := RendezvousHash(sId, c.Servers, 1)[0]
-			// ---------------------------
-			searchReq := RPCSearchPointsRequest{
+			countReq := RPCCountPointsRequest{
 				RPCRequestArgs: RPCRequestArgs{
 					Source: c.MyHostname,
-					Dest:   targetServer,
+					Dest:   RendezvousHash(sId, c.Servers, 1)[0],
 				},
-				Collection:    col,
-				ShardId:       sId,
-				SearchRequest: sr,
+				Collection: col,
+				ShardId:    sId,
+				Query:      req.Query,
 			}
-			searchResp := RPCSearchPointsResponse{}
-			if err := c.RPCSearchPoints(&searchReq, &searchResp); err != nil {
+			countResp := RPCCountPointsResponse{}
+			if err := c.RPCCountPoints(&countReq, &countResp); err != nil {
 				errOnce.Do(func() {
-					// If we encounter an error, we only want to report it once.
-					searchErr = fmt.Errorf("shard could not search points: %w", err)
+					countErr = fmt.Errorf("shard could not count points: %w", err)
 				})
-				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not search points")
-			} else {
-				// Alternatively we can stream the results into a channel and
-				// loop over. This is more straightforward for now.
-				mu.Lock()
-				results = append(results, searchResp.Points...)
-				mu.Unlock()
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not count points")
+				return
 			}
+			mu.Lock()
+			total += countResp.Count
+			mu.Unlock()
 		}(shardId)
 	}
-	// ---------------------------
 	wg.Wait()
-	if searchErr != nil {
-		return nil, searchErr
+	if countErr != nil {
+		return 0, countErr
 	}
+	return total, nil
+}
+
+// mergeSearchResults orders the results of all shards and takes the top limit
+// results.
+func mergeSearchResults(col models.Collection, sr models.SearchRequest, limit int, results []models.SearchResult) ([]models.SearchResult, error) {
 	if len(col.ShardIds) > 1 {
 		// Merge results in a single slice. We could instead use a channel to stream
 		// and merge results on the go but that adds more complexity which could be
 		// future work.
-		if len(sr.Sort) == 0 {
+		mergeMMR, weight := sr.MergeMMR()
+		if mergeMMR != nil && canMergeMMR(results) {
+			// Each shard diversified its own results, but the points
+			// across shards may still be near duplicates of one another.
+			merged, err := mmrMerge(col.IndexSchema, sr.Query.Property, results, *mergeMMR, weight)
+			if err != nil {
+				return nil, fmt.Errorf("could not merge with mmr: %w", err)
+			}
+			results = merged
+		} else if len(sr.Sort) == 0 {
 			slices.SortFunc(results, func(a, b models.SearchResult) int {
 				return cmp.Compare(b.HybridScore, a.HybridScore)
 			})
@@ -371,15 +577,342 @@ func (c *ClusterNode) SearchPoints(col models.Collection, sr models.SearchReques
 	} // End of merge
 	// ---------------------------
 	// Take the top limit points
-	if len(results) > originalLimit {
-		results = results[:originalLimit]
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		results[i].MMRVector = nil
 	}
-	// ---------------------------
 	return results, nil
 }
 
 // ---------------------------
 
+// canMergeMMR reports whether every result has the distance and vector needed
+// to diversify the merged results, e.g. a shard may be running an older
+// version that does not send the vectors.
+func canMergeMMR(results []models.SearchResult) bool {
+	for _, r := range results {
+		if r.Distance == nil || r.MMRVector == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// mmrMerge reranks the merged shard results with maximal marginal relevance
+// using the vectors sent back by the shards.
+func mmrMerge(schema models.IndexSchema, property string, results []models.SearchResult, mmr models.SearchMMROptions, weight float32) ([]models.SearchResult, error) {
+	_, metric, ok := schema.VectorParameters(property)
+	if !ok {
+		return nil, fmt.Errorf("property %s is not a vector index", property)
+	}
+	distFn, err := distance.GetVectorDistanceFn(metric)
+	if err != nil {
+		return nil, fmt.Errorf("could not get distance function: %w", err)
+	}
+	queryDists := make([]float32, len(results))
+	for i, r := range results {
+		queryDists[i] = *r.Distance
+	}
+	distFrom := func(i int) func(j int) float32 {
+		return func(j int) float32 {
+			return distFn(results[i].MMRVector, results[j].MMRVector)
+		}
+	}
+	order, scores := utils.MaximalMarginalRelevance(queryDists, distFrom, mmr.Lambda, len(results))
+	merged := make([]models.SearchResult, len(order))
+	for i, idx := range order {
+		merged[i] = results[idx]
+		merged[i].HybridScore = scores[i] * weight
+	}
+	return merged, nil
+}
+
+// ---------------------------
+
+// resolveScoreOrigins replaces originNow in decay functions with the current
+// time, returning a copy so the request of the caller is left unchanged.
+func resolveScoreOrigins(sf *models.ScoreFunctions) *models.ScoreFunctions {
+	if sf == nil {
+		return nil
+	}
+	now := float64(time.Now().Unix())
+	resolved := *sf
+	resolved.Functions = slices.Clone(sf.Functions)
+	for i, f := range resolved.Functions {
+		if f.Decay == nil || !f.Decay.OriginNow {
+			continue
+		}
+		decay := *f.Decay
+		decay.Origin = now
+		decay.OriginNow = false
+		resolved.Functions[i].Decay = &decay
+	}
+	return &resolved
+}
+
+// resolveMoreLikeThis fills in the terms of the source point of any
+// moreLikeThis text query, including nested and filter queries.
+func (c *ClusterNode) resolveMoreLikeThis(col models.Collection, q *models.Query) error {
+	for i := range q.And {
+		if err := c.resolveMoreLikeThis(col, &q.And[i]); err != nil {
+			return err
+		}
+	}
+	for i := range q.Or {
+		if err := c.resolveMoreLikeThis(col, &q.Or[i]); err != nil {
+			return err
+		}
+	}
+	var filter *models.Query
+	switch {
+	case q.VectorFlat != nil:
+		filter = q.VectorFlat.Filter
+	case q.VectorVamana != nil:
+		filter = q.VectorVamana.Filter
+	case q.Text != nil:
+		filter = q.Text.Filter
+	}
+	if filter != nil {
+		if err := c.resolveMoreLikeThis(col, filter); err != nil {
+			return err
+		}
+	}
+	if q.Text == nil || q.Text.Operator != models.OperatorMoreLikeThis || q.Text.Terms != nil {
+		return nil
+	}
+	// ---------------------------
+	pointId, err := uuid.Parse(q.Text.Value)
+	if err != nil {
+		return fmt.Errorf("could not parse point id %s: %w", q.Text.Value, err)
+	}
+	/* We ask every shard as we don't know where the point is, the ones that
+	 * don't have it reply with no terms. If no shard has the point, the query
+	 * matches nothing like any other query for missing data. */
+	terms := make([]models.WeightedTerm, 0)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var termsErr error
+	var errOnce sync.Once
+	for _, shardId := range col.ShardIds {
+		wg.Add(1)
+		go func(sId string) {
+			defer wg.Done()
+			termsReq := RPCMoreLikeThisTermsRequest{
+				RPCRequestArgs: RPCRequestArgs{
+					Source: c.MyHostname,
+					Dest:   RendezvousHash(sId, c.Servers, 1)[0],
+				},
+				Collection: col,
+				ShardId:    sId,
+				Property:   q.Property,
+				PointId:    pointId,
+				MaxTerms:   q.Text.MaxTerms,
+			}
+			termsResp := RPCMoreLikeThisTermsResponse{}
+			if err := c.RPCMoreLikeThisTerms(&termsReq, &termsResp); err != nil {
+				errOnce.Do(func() {
+					termsErr = fmt.Errorf("shard could not get moreLikeThis terms: %w", err)
+				})
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not get moreLikeThis terms")
+				return
+			}
+			if termsResp.Terms != nil {
+				mu.Lock()
+				terms = termsResp.Terms
+				mu.Unlock()
+			}
+		}(shardId)
+	}
+	wg.Wait()
+	if termsErr != nil {
+		return termsErr
+	}
+	q.Text.Terms = terms
+	return nil
+}
+
+// resolveRecommend fills in the stored vectors of the examples of any
+// recommend vector query, including nested and filter queries.
+func (c *ClusterNode) resolveRecommend(col models.Collection, q *models.Query) error {
+	for i := range q.And {
+		if err := c.resolveRecommend(col, &q.And[i]); err != nil {
+			return err
+		}
+	}
+	for i := range q.Or {
+		if err := c.resolveRecommend(col, &q.Or[i]); err != nil {
+			return err
+		}
+	}
+	var filter *models.Query
+	var recommend *models.SearchRecommendOptions
+	switch {
+	case q.VectorFlat != nil:
+		filter = q.VectorFlat.Filter
+		if q.VectorFlat.Operator == models.OperatorRecommend {
+			recommend = q.VectorFlat.Recommend
+		}
+	case q.VectorVamana != nil:
+		filter = q.VectorVamana.Filter
+		if q.VectorVamana.Operator == models.OperatorRecommend {
+			recommend = q.VectorVamana.Recommend
+		}
+	case q.Text != nil:
+		filter = q.Text.Filter
+	}
+	if filter != nil {
+		if err := c.resolveRecommend(col, filter); err != nil {
+			return err
+		}
+	}
+	if recommend == nil || recommend.PositiveVectors != nil {
+		return nil
+	}
+	// ---------------------------
+	pointIds := make([]uuid.UUID, 0, len(recommend.Positive)+len(recommend.Negative))
+	for _, value := range slices.Concat(recommend.Positive, recommend.Negative) {
+		pointId, err := uuid.Parse(value)
+		if err != nil {
+			return fmt.Errorf("could not parse point id %s: %w", value, err)
+		}
+		pointIds = append(pointIds, pointId)
+	}
+	/* Like moreLikeThis, we ask every shard for the examples and only the ones
+	 * that have them reply with their vectors. Missing examples are ignored and
+	 * without any positive example the query matches nothing. */
+	vectors := make(map[uuid.UUID][]float32, len(pointIds))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var vectorsErr error
+	var errOnce sync.Once
+	for _, shardId := range col.ShardIds {
+		wg.Add(1)
+		go func(sId string) {
+			defer wg.Done()
+			vectorsReq := RPCRecommendVectorsRequest{
+				RPCRequestArgs: RPCRequestArgs{
+					Source: c.MyHostname,
+					Dest:   RendezvousHash(sId, c.Servers, 1)[0],
+				},
+				Collection: col,
+				ShardId:    sId,
+				Property:   q.Property,
+				PointIds:   pointIds,
+			}
+			vectorsResp := RPCRecommendVectorsResponse{}
+			if err := c.RPCRecommendVectors(&vectorsReq, &vectorsResp); err != nil {
+				errOnce.Do(func() {
+					vectorsErr = fmt.Errorf("shard could not get recommend vectors: %w", err)
+				})
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not get recommend vectors")
+				return
+			}
+			mu.Lock()
+			maps.Copy(vectors, vectorsResp.Vectors)
+			mu.Unlock()
+		}(shardId)
+	}
+	wg.Wait()
+	if vectorsErr != nil {
+		return vectorsErr
+	}
+	// ---------------------------
+	recommend.PositiveVectors = make([][]float32, 0, len(recommend.Positive))
+	recommend.NegativeVectors = make([][]float32, 0, len(recommend.Negative))
+	for i, pointId := range pointIds {
+		vector, ok := vectors[pointId]
+		if !ok {
+			continue
+		}
+		if i < len(recommend.Positive) {
+			recommend.PositiveVectors = append(recommend.PositiveVectors, vector)
+		} else {
+			recommend.NegativeVectors = append(recommend.NegativeVectors, vector)
+		}
+	}
+	return nil
+}
+
+// ---------------------------
+
+func (c *ClusterNode) Suggest(col models.Collection, req models.SuggestRequest) ([]models.Suggestion, error) {
+	// ---------------------------
+	/* Every shard suggests its most frequent terms and we add up the document
+	 * counts of the same term. A term that just misses the limit in some
+	 * shards is undercounted, so the merged counts are a lower bound. Terms
+	 * are spread evenly across shards by random point placement, so the top
+	 * terms are usually the same in every shard. */
+	if req.Filter != nil {
+		// The filter is run by every shard, so it needs what only some
+		// shards can look up just like a search
+		if err := c.resolveMoreLikeThis(col, req.Filter); err != nil {
+			return nil, fmt.Errorf("could not resolve moreLikeThis: %w", err)
+		}
+		if err := c.resolveRecommend(col, req.Filter); err != nil {
+			return nil, fmt.Errorf("could not resolve recommend: %w", err)
+		}
+	}
+	// ---------------------------
+	counts := make(map[string]uint64)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var suggestErr error
+	var errOnce sync.Once
+	for _, shardId := range col.ShardIds {
+		wg.Add(1)
+		go func(sId string) {
+			defer wg.Done()
+			targetServer := RendezvousHash(sId, c.Servers, 1)[0]
+			// ---------------------------
+			suggestReq := RPCSuggestRequest{
+				RPCRequestArgs: RPCRequestArgs{
+					Source: c.MyHostname,
+					Dest:   targetServer,
+				},
+				Collection:     col,
+				ShardId:        sId,
+				SuggestRequest: req,
+			}
+			suggestResp := RPCSuggestResponse{}
+			if err := c.RPCSuggest(&suggestReq, &suggestResp); err != nil {
+				errOnce.Do(func() {
+					suggestErr = fmt.Errorf("shard could not suggest: %w", err)
+				})
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not suggest")
+				return
+			}
+			mu.Lock()
+			for _, s := range suggestResp.Suggestions {
+				counts[s.Term] += s.DocumentCount
+			}
+			mu.Unlock()
+		}(shardId)
+	}
+	// ---------------------------
+	wg.Wait()
+	if suggestErr != nil {
+		return nil, suggestErr
+	}
+	suggestions := make([]models.Suggestion, 0, len(counts))
+	for term, count := range counts {
+		suggestions = append(suggestions, models.Suggestion{Term: term, DocumentCount: count})
+	}
+	slices.SortFunc(suggestions, func(a, b models.Suggestion) int {
+		if c := cmp.Compare(b.DocumentCount, a.DocumentCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Term, b.Term)
+	})
+	if len(suggestions) > req.Limit {
+		suggestions = suggestions[:req.Limit]
+	}
+	return suggestions, nil
+}
+
+// ---------------------------
+
 type FailedPoint struct {
 	Id  uuid.UUID `json:"id"`
 	Err string    `json:"error"`
@@ -519,3 +1052,56 @@ func (c *ClusterNode) DeletePoints(col models.Collection, pointIds []uuid.UUID)
 	// *** Return which points were NOT deleted. ***
 	return curateFailedPoints(pointIds, deletedIds, successCount == len(col.ShardIds)), nil
 }
+
+// DeletePointsByQuery deletes the points matching the query in every shard. It
+// returns the number of deleted points and the number of shards that could not
+// delete, the points in those shards are left as they are. If no shard could
+// delete, it returns an error instead.
+func (c *ClusterNode) DeletePointsByQuery(col models.Collection, query models.Query) (int, int, error) {
+	// ---------------------------
+	// Similar points can be in any shard, so the terms are looked up first
+	if err := c.resolveMoreLikeThis(col, &query); err != nil {
+		return 0, 0, fmt.Errorf("could not resolve moreLikeThis: %w", err)
+	}
+	// ---------------------------
+	deletedCount := 0
+	failedShards := 0
+	var deleteErr error
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, shardId := range col.ShardIds {
+		wg.Add(1)
+		go func(sId string) {
+			defer wg.Done()
+			deleteReq := RPCDeletePointsByQueryRequest{
+				RPCRequestArgs: RPCRequestArgs{
+					Source: c.MyHostname,
+					Dest:   RendezvousHash(sId, c.Servers, 1)[0],
+				},
+				Collection: col,
+				ShardId:    sId,
+				Query:      query,
+			}
+			deleteResp := RPCDeletePointsByQueryResponse{}
+			err := c.RPCDeletePointsByQuery(&deleteReq, &deleteResp)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				c.logger.Error().Err(err).Str("userId", col.UserId).Str("collectionId", col.Id).Str("shardId", sId).Msg("could not delete points by query")
+				failedShards++
+				if deleteErr == nil {
+					deleteErr = fmt.Errorf("shard could not delete points: %w", err)
+				}
+				return
+			}
+			deletedCount += deleteResp.DeletedCount
+		}(shardId)
+	}
+	wg.Wait()
+	// Nothing was deleted if every shard failed, otherwise it is a partial
+	// success reported with the number of failed shards
+	if failedShards > 0 && failedShards == len(col.ShardIds) {
+		return 0, failedShards, deleteErr
+	}
+	return deletedCount, failedShards, nil
+}
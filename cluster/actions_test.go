@@ -0,0 +1,160 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/semafind/semadb/models"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func Test_mmrMerge(t *testing.T) {
+	schema := models.IndexSchema{
+		"vector": models.IndexSchemaValue{
+			Type: models.IndexTypeVectorFlat,
+			VectorFlat: &models.IndexVectorFlatParameters{
+				VectorSize:     2,
+				DistanceMetric: models.DistanceEuclidean,
+			},
+		},
+	}
+	newResult := func(nodeId uint64, vector []float32) models.SearchResult {
+		dist := vector[0]*vector[0] + vector[1]*vector[1]
+		return models.SearchResult{
+			NodeId:      nodeId,
+			Distance:    &dist,
+			HybridScore: -dist,
+			MMRVector:   vector,
+		}
+	}
+	// Two shards each sent back their nearest points, 1 and 2 are near
+	// duplicates of each other
+	results := []models.SearchResult{
+		newResult(1, []float32{0, 0}),
+		newResult(2, []float32{0.01, 0}),
+		newResult(3, []float32{1, 1}),
+	}
+	require.True(t, canMergeMMR(results))
+	merged, err := mmrMerge(schema, "vector", results, models.SearchMMROptions{Lambda: 0.3}, 1)
+	require.NoError(t, err)
+	require.Len(t, merged, 3)
+	ids := []uint64{merged[0].NodeId, merged[1].NodeId, merged[2].NodeId}
+	require.Equal(t, []uint64{1, 3, 2}, ids)
+	require.GreaterOrEqual(t, merged[0].HybridScore, merged[1].HybridScore)
+	require.GreaterOrEqual(t, merged[1].HybridScore, merged[2].HybridScore)
+	// ---------------------------
+	results[1].MMRVector = nil
+	require.False(t, canMergeMMR(results))
+	_, err = mmrMerge(schema, "nonExistent", results, models.SearchMMROptions{Lambda: 0.3}, 1)
+	require.Error(t, err)
+}
+
+func Test_SuggestFilterAcrossShards(t *testing.T) {
+	tempDir := t.TempDir()
+	c, err := NewNode(ClusterNodeConfig{
+		RootDir:            tempDir,
+		Servers:            []string{"localhost:9898"},
+		RpcHost:            "localhost",
+		RpcPort:            9898,
+		RpcTimeout:         5,
+		RpcRetries:         2,
+		MaxShardSize:       268435456,
+		MaxShardPointCount: 4,
+		ShardManager: ShardManagerConfig{
+			RootDir:      tempDir,
+			ShardTimeout: 30,
+		},
+	})
+	require.NoError(t, err)
+	col := models.Collection{
+		Id:     "gandalf",
+		UserId: "testy",
+		IndexSchema: models.IndexSchema{
+			"vector": models.IndexSchemaValue{
+				Type: models.IndexTypeVectorVamana,
+				VectorVamana: &models.IndexVectorVamanaParameters{
+					VectorSize:     2,
+					DistanceMetric: models.DistanceEuclidean,
+					SearchSize:     75,
+					DegreeBound:    64,
+					Alpha:          1.2,
+				},
+			},
+			"description": models.IndexSchemaValue{
+				Type: models.IndexTypeText,
+				Text: &models.IndexTextParameters{Analyser: "standard"},
+			},
+		},
+		UserPlan: models.UserPlan{
+			MaxCollections:          1,
+			MaxCollectionPointCount: 8,
+			MaxPointSize:            100,
+		},
+	}
+	require.NoError(t, c.CreateCollection(col))
+	newPoints := func(descriptions []string, vectors [][]float32) []models.Point {
+		points := make([]models.Point, len(descriptions))
+		for i, d := range descriptions {
+			data, err := msgpack.Marshal(models.PointAsMap{"description": d, "vector": vectors[i]})
+			require.NoError(t, err)
+			points[i] = models.Point{Id: uuid.New(), Data: data}
+		}
+		return points
+	}
+	// Each batch fills a shard of its own
+	source := newPoints([]string{"hobbit frodo"}, [][]float32{{0, 0}})[0]
+	first := append(newPoints(
+		[]string{"hobbit sam", "wizard gandalf", "elf legolas"},
+		[][]float32{{0.1, 0}, {5, 5}, {6, 6}}), source)
+	second := newPoints(
+		[]string{"hobbit bilbo", "hobbit merry", "dwarf gimli", "ent treebeard"},
+		[][]float32{{0.2, 0}, {3, 3}, {7, 7}, {8, 8}})
+	for _, points := range [][]models.Point{first, second} {
+		col, err = c.GetCollection(col.UserId, col.Id)
+		require.NoError(t, err)
+		failedRanges, err := c.InsertPoints(col, points, false)
+		require.NoError(t, err)
+		require.Len(t, failedRanges, 0)
+	}
+	col, err = c.GetCollection(col.UserId, col.Id)
+	require.NoError(t, err)
+	require.Len(t, col.ShardIds, 2)
+	// ---------------------------
+	// The hobbits of the other shard are like the source as well
+	suggestions, err := c.Suggest(col, models.SuggestRequest{
+		Property: "description",
+		Prefix:   "h",
+		Filter: &models.Query{
+			Property: "description",
+			Text: &models.SearchTextOptions{
+				Value:    source.Id.String(),
+				Operator: models.OperatorMoreLikeThis,
+				Limit:    10,
+			},
+		},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{{Term: "hobbit", DocumentCount: 3}}, suggestions)
+	// Every shard recommends its nearest point to the source
+	suggestions, err = c.Suggest(col, models.SuggestRequest{
+		Property: "description",
+		Prefix:   "h",
+		Filter: &models.Query{
+			Property: "vector",
+			VectorVamana: &models.SearchVectorVamanaOptions{
+				Operator:   models.OperatorRecommend,
+				SearchSize: 75,
+				Limit:      1,
+				Recommend: &models.SearchRecommendOptions{
+					Positive: []string{source.Id.String()},
+				},
+			},
+		},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{{Term: "hobbit", DocumentCount: 2}}, suggestions)
+	require.NoError(t, c.Close())
+}
@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"github.com/semafind/semadb/diskstore"
 	"github.com/semafind/semadb/models"
 	"github.com/semafind/semadb/shard"
+	"github.com/semafind/semadb/shard/index"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
@@ -293,6 +295,57 @@ func (c *ClusterNode) RPCGetCollection(args *RPCGetCollectionRequest, reply *RPC
 
 // ---------------------------
 
+type RPCSetCollectionSynonymsRequest struct {
+	RPCRequestArgs
+	UserId       string
+	CollectionId string
+	Synonyms     [][]string
+}
+
+type RPCSetCollectionSynonymsResponse struct {
+	NotFound bool
+}
+
+func (c *ClusterNode) RPCSetCollectionSynonyms(args *RPCSetCollectionSynonymsRequest, reply *RPCSetCollectionSynonymsResponse) error {
+	c.logger.Debug().Str("userId", args.UserId).Str("collectionId", args.CollectionId).Msg("RPCSetCollectionSynonyms")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCSetCollectionSynonyms", args, reply)
+	}
+	err := c.nodedb.Write(func(bm diskstore.BucketManager) error {
+		// ---------------------------
+		b, err := bm.Get(USERCOLSBUCKETKEY)
+		if err != nil {
+			return fmt.Errorf("could not get write user collections bucket: %w", err)
+		}
+		// ---------------------------
+		// We only replace the synonyms of the stored collection so that
+		// concurrent changes such as new shards are not lost.
+		key := []byte(args.UserId + DBDELIMITER + args.CollectionId)
+		value := b.Get(key)
+		if value == nil {
+			reply.NotFound = true
+			return nil
+		}
+		var col models.Collection
+		if err := msgpack.Unmarshal(value, &col); err != nil {
+			return fmt.Errorf("could not unmarshal collection %s: %w", key, err)
+		}
+		col.Synonyms = args.Synonyms
+		// ---------------------------
+		colBytes, err := msgpack.Marshal(col)
+		if err != nil {
+			return fmt.Errorf("could not marshal collection: %w", err)
+		}
+		if err := b.Put(key, colBytes); err != nil {
+			return fmt.Errorf("could not put collection: %w", err)
+		}
+		return nil
+	})
+	return err
+}
+
+// ---------------------------
+
 type RPCCreateShardRequest struct {
 	RPCRequestArgs
 	UserId       string
@@ -352,8 +405,9 @@ type RPCGetShardInfoRequest struct {
 }
 
 type RPCGetShardInfoResponse struct {
-	PointCount int64
-	Size       int64
+	PointCount         int64
+	Size               int64
+	VamanaCalibrations map[string]models.VamanaCalibration
 }
 
 func (c *ClusterNode) RPCGetShardInfo(args *RPCGetShardInfoRequest, reply *RPCGetShardInfoResponse) error {
@@ -366,6 +420,7 @@ func (c *ClusterNode) RPCGetShardInfo(args *RPCGetShardInfoRequest, reply *RPCGe
 		si, err := s.Info()
 		reply.PointCount = int64(si.PointCount)
 		reply.Size = si.Size
+		reply.VamanaCalibrations = si.VamanaCalibrations
 		return err
 	})
 }
@@ -398,6 +453,7 @@ type RPCInsertPointsRequest struct {
 	Collection models.Collection
 	ShardId    string
 	Points     []models.Point
+	Bulk       bool
 }
 
 // This response is not really used, but we need to return something otherwise
@@ -414,7 +470,12 @@ func (c *ClusterNode) RPCInsertPoints(args *RPCInsertPointsRequest, reply *RPCIn
 	}
 	// ---------------------------
 	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
-		err := s.InsertPoints(args.Points)
+		var err error
+		if args.Bulk {
+			err = s.BulkInsertPoints(args.Points)
+		} else {
+			err = s.InsertPoints(args.Points)
+		}
 		if err == nil {
 			reply.Count = len(args.Points)
 			c.metrics.pointInsertCount.Add(float64(len(args.Points)))
@@ -487,6 +548,36 @@ func (c *ClusterNode) RPCDeletePoints(args *RPCDeletePointsRequest, reply *RPCDe
 
 // ---------------------------
 
+type RPCDeletePointsByQueryRequest struct {
+	RPCRequestArgs
+	Collection models.Collection
+	ShardId    string
+	Query      models.Query
+}
+
+type RPCDeletePointsByQueryResponse struct {
+	DeletedCount int
+}
+
+func (c *ClusterNode) RPCDeletePointsByQuery(args *RPCDeletePointsByQueryRequest, reply *RPCDeletePointsByQueryResponse) error {
+	c.logger.Debug().Str("userId", args.Collection.UserId).Str("collectionId", args.Collection.Id).Str("shardId", args.ShardId).Msg("RPCDeletePointsByQuery")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCDeletePointsByQuery", args, reply)
+	}
+	// ---------------------------
+	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
+		ctx := index.WithSynonyms(context.Background(), args.Collection.Synonyms)
+		count, err := s.DeletePointsByQuery(ctx, args.Query)
+		reply.DeletedCount = count
+		if err == nil {
+			c.metrics.pointDeleteCount.Add(float64(count))
+		}
+		return err
+	})
+}
+
+// ---------------------------
+
 type RPCSearchPointsRequest struct {
 	RPCRequestArgs
 	Collection    models.Collection
@@ -496,6 +587,8 @@ type RPCSearchPointsRequest struct {
 
 type RPCSearchPointsResponse struct {
 	Points []models.SearchResult
+	// Only set if the search request asks to profile
+	Profile *models.ShardProfile
 }
 
 func (c *ClusterNode) RPCSearchPoints(args *RPCSearchPointsRequest, reply *RPCSearchPointsResponse) error {
@@ -505,7 +598,20 @@ func (c *ClusterNode) RPCSearchPoints(args *RPCSearchPointsRequest, reply *RPCSe
 	}
 	// ---------------------------
 	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
-		points, err := s.SearchPoints(args.SearchRequest)
+		// The collection is loaded fresh for each request whereas the shard
+		// may have been loaded with an older copy, so we pass the synonyms on.
+		ctx := index.WithSynonyms(context.Background(), args.Collection.Synonyms)
+		if args.SearchRequest.Profile {
+			reply.Profile = &models.ShardProfile{Shard: args.ShardId}
+			ctx = shard.WithProfile(ctx, reply.Profile)
+		}
+		points, err := s.SearchPoints(ctx, args.SearchRequest)
+		// Only the cluster knows which shard this is
+		for _, p := range points {
+			if p.Explanation != nil {
+				p.Explanation.Shard = args.ShardId
+			}
+		}
 		reply.Points = points
 		if err == nil {
 			c.metrics.pointSearchCount.Add(float64(len(points)))
@@ -515,3 +621,156 @@ func (c *ClusterNode) RPCSearchPoints(args *RPCSearchPointsRequest, reply *RPCSe
 }
 
 // ---------------------------
+
+type RPCSearchPointsBatchRequest struct {
+	RPCRequestArgs
+	Collection     models.Collection
+	ShardId        string
+	SearchRequests []models.SearchRequest
+}
+
+type RPCSearchPointsBatchResponse struct {
+	// The points and errors are in the order of the search requests, an empty
+	// error means the search succeeded.
+	Points [][]models.SearchResult
+	Errors []string
+}
+
+func (c *ClusterNode) RPCSearchPointsBatch(args *RPCSearchPointsBatchRequest, reply *RPCSearchPointsBatchResponse) error {
+	c.logger.Debug().Str("userId", args.Collection.UserId).Str("collectionId", args.Collection.Id).Str("shardId", args.ShardId).Int("count", len(args.SearchRequests)).Msg("RPCSearchPointsBatch")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCSearchPointsBatch", args, reply)
+	}
+	// ---------------------------
+	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
+		ctx := index.WithSynonyms(context.Background(), args.Collection.Synonyms)
+		points, errs := s.SearchPointsBatch(ctx, args.SearchRequests)
+		reply.Points = points
+		reply.Errors = make([]string, len(errs))
+		for i, err := range errs {
+			if err != nil {
+				reply.Errors[i] = err.Error()
+				continue
+			}
+			for _, p := range points[i] {
+				if p.Explanation != nil {
+					p.Explanation.Shard = args.ShardId
+				}
+			}
+			c.metrics.pointSearchCount.Add(float64(len(points[i])))
+		}
+		return nil
+	})
+}
+
+// ---------------------------
+
+type RPCCountPointsRequest struct {
+	RPCRequestArgs
+	Collection models.Collection
+	ShardId    string
+	Query      models.Query
+}
+
+type RPCCountPointsResponse struct {
+	Count uint64
+}
+
+func (c *ClusterNode) RPCCountPoints(args *RPCCountPointsRequest, reply *RPCCountPointsResponse) error {
+	c.logger.Debug().Str("userId", args.Collection.UserId).Str("collectionId", args.Collection.Id).Str("shardId", args.ShardId).Msg("RPCCountPoints")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCCountPoints", args, reply)
+	}
+	// ---------------------------
+	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
+		ctx := index.WithSynonyms(context.Background(), args.Collection.Synonyms)
+		count, err := s.CountPoints(ctx, args.Query)
+		reply.Count = count
+		return err
+	})
+}
+
+// ---------------------------
+
+type RPCSuggestRequest struct {
+	RPCRequestArgs
+	Collection     models.Collection
+	ShardId        string
+	SuggestRequest models.SuggestRequest
+}
+
+type RPCSuggestResponse struct {
+	Suggestions []models.Suggestion
+}
+
+func (c *ClusterNode) RPCSuggest(args *RPCSuggestRequest, reply *RPCSuggestResponse) error {
+	c.logger.Debug().Str("userId", args.Collection.UserId).Str("collectionId", args.Collection.Id).Str("shardId", args.ShardId).Msg("RPCSuggest")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCSuggest", args, reply)
+	}
+	// ---------------------------
+	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
+		suggestions, err := s.Suggest(context.Background(), args.SuggestRequest)
+		reply.Suggestions = suggestions
+		return err
+	})
+}
+
+// ---------------------------
+
+type RPCMoreLikeThisTermsRequest struct {
+	RPCRequestArgs
+	Collection models.Collection
+	ShardId    string
+	Property   string
+	PointId    uuid.UUID
+	MaxTerms   int
+}
+
+type RPCMoreLikeThisTermsResponse struct {
+	// Nil if the point is not in the shard
+	Terms []models.WeightedTerm
+}
+
+func (c *ClusterNode) RPCMoreLikeThisTerms(args *RPCMoreLikeThisTermsRequest, reply *RPCMoreLikeThisTermsResponse) error {
+	c.logger.Debug().Str("userId", args.Collection.UserId).Str("collectionId", args.Collection.Id).Str("shardId", args.ShardId).Msg("RPCMoreLikeThisTerms")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCMoreLikeThisTerms", args, reply)
+	}
+	// ---------------------------
+	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
+		terms, err := s.MoreLikeThisTerms(args.Property, args.PointId, args.MaxTerms)
+		reply.Terms = terms
+		return err
+	})
+}
+
+// ---------------------------
+
+type RPCRecommendVectorsRequest struct {
+	RPCRequestArgs
+	Collection models.Collection
+	ShardId    string
+	Property   string
+	PointIds   []uuid.UUID
+}
+
+type RPCRecommendVectorsResponse struct {
+	// Only the points in the shard are included
+	Vectors map[uuid.UUID][]float32
+}
+
+func (c *ClusterNode) RPCRecommendVectors(args *RPCRecommendVectorsRequest, reply *RPCRecommendVectorsResponse) error {
+	c.logger.Debug().Str("userId", args.Collection.UserId).Str("collectionId", args.Collection.Id).Str("shardId", args.ShardId).Msg("RPCRecommendVectors")
+	if args.Dest != c.MyHostname {
+		return c.internalRoute("ClusterNode.RPCRecommendVectors", args, reply)
+	}
+	// ---------------------------
+	return c.shardManager.DoWithShard(args.Collection, args.ShardId, func(s *shard.Shard) error {
+		vectors, err := s.RecommendVectors(args.Property, args.PointIds)
+		reply.Vectors = vectors
+		return err
+	})
+}
+
+// ---------------------------
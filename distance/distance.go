@@ -92,3 +92,26 @@ func GetBitDistanceFn(name string) (BitDistFunc, error) {
 		return nil, fmt.Errorf("unknown bit distance function: %s", name)
 	}
 }
+
+// Returns a distance function on float vectors for any metric. Hamming and
+// jaccard compare the vectors as bits set where values are above 0.5, the same
+// threshold the vector indices use for these metrics.
+func GetVectorDistanceFn(name string) (FloatDistFunc, error) {
+	bitDistFn, err := GetBitDistanceFn(name)
+	if err != nil {
+		return GetFloatDistanceFn(name)
+	}
+	return func(x, y []float32) float32 {
+		return bitDistFn(floatsToBits(x), floatsToBits(y))
+	}, nil
+}
+
+func floatsToBits(vector []float32) []uint64 {
+	encoded := make([]uint64, (len(vector)+63)/64)
+	for i, v := range vector {
+		if v > 0.5 {
+			encoded[i/64] |= 1 << (i % 64)
+		}
+	}
+	return encoded
+}
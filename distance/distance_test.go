@@ -65,3 +65,21 @@ func TestHaversineDistance(t *testing.T) {
 	dist /= 1000 // in km
 	require.InDelta(t, 11099.54, dist, 0.01)
 }
+
+func TestVectorDistanceFn(t *testing.T) {
+	// Bit metrics threshold the float vectors at 0.5
+	x := []float32{1, 0, 0, 1, 1}
+	y := []float32{1, 0, 1, 0.2, 0.9}
+	hammingFn, err := GetVectorDistanceFn("hamming")
+	require.NoError(t, err)
+	require.Equal(t, float32(2), hammingFn(x, y))
+	jaccardFn, err := GetVectorDistanceFn("jaccard")
+	require.NoError(t, err)
+	require.Equal(t, float32(0.5), jaccardFn(x, y))
+	// Float metrics are passed through
+	euclideanFn, err := GetVectorDistanceFn("euclidean")
+	require.NoError(t, err)
+	require.Equal(t, float32(27), euclideanFn([]float32{1, 2, 3}, []float32{4, 5, 6}))
+	_, err = GetVectorDistanceFn("manhattan")
+	require.Error(t, err)
+}
@@ -0,0 +1,395 @@
+package expression
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/* A small arithmetic expression language for custom scores such as
+ *
+ *   0.7 * _hybridScore + 0.3 * log(1 + views) - 0.01 * ageDays
+ *
+ * Expressions have numbers, variables, the operators + - * / with the usual
+ * precedence, parentheses and a fixed set of functions. There are no loops or
+ * assignments, so evaluating is linear in the size of the expression. The
+ * grammar is:
+ *
+ *   expr    = term { ("+" | "-") term }
+ *   term    = unary { ("*" | "/") unary }
+ *   unary   = "-" unary | primary
+ *   primary = number | variable | function "(" expr { "," expr } ")" | "(" expr ")"
+ */
+
+// Maximum length of an expression
+const MaxLength = 1024
+
+// Maximum nesting of parentheses and unary minus
+const maxDepth = 32
+
+type function struct {
+	minArgs int
+	maxArgs int
+	fn      func(args []float64) float64
+}
+
+var functions = map[string]function{
+	"abs":   {1, 1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, 1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"log":   {1, 1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, 1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log1p": {1, 1, func(a []float64) float64 { return math.Log1p(a[0]) }},
+	"exp":   {1, 1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"pow":   {2, 2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min": {2, 8, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {2, 8, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+// ---------------------------
+
+type node interface {
+	eval(vars map[string]float64) (float64, bool)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) (float64, bool) {
+	return float64(n), true
+}
+
+type variableNode string
+
+func (n variableNode) eval(vars map[string]float64) (float64, bool) {
+	v, ok := vars[string(n)]
+	return v, ok
+}
+
+type negateNode struct {
+	operand node
+}
+
+func (n negateNode) eval(vars map[string]float64) (float64, bool) {
+	v, ok := n.operand.eval(vars)
+	return -v, ok
+}
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+func (n binaryNode) eval(vars map[string]float64) (float64, bool) {
+	l, ok := n.left.eval(vars)
+	if !ok {
+		return 0, false
+	}
+	r, ok := n.right.eval(vars)
+	if !ok {
+		return 0, false
+	}
+	switch n.op {
+	case '+':
+		return l + r, true
+	case '-':
+		return l - r, true
+	case '*':
+		return l * r, true
+	case '/':
+		return l / r, true
+	}
+	return 0, false
+}
+
+type callNode struct {
+	fn   function
+	args []node
+}
+
+func (n callNode) eval(vars map[string]float64) (float64, bool) {
+	args := make([]float64, len(n.args))
+	for i, a := range n.args {
+		v, ok := a.eval(vars)
+		if !ok {
+			return 0, false
+		}
+		args[i] = v
+	}
+	return n.fn.fn(args), true
+}
+
+// ---------------------------
+
+// Expr is a parsed expression ready to be evaluated.
+type Expr struct {
+	root      node
+	variables []string
+}
+
+// Returns the distinct variables of the expression in the order they appear.
+func (e *Expr) Variables() []string {
+	return e.variables
+}
+
+// Evaluates the expression, ok is false if a variable is missing or the
+// result is not a finite number, e.g. after dividing by zero.
+func (e *Expr) Eval(vars map[string]float64) (float64, bool) {
+	v, ok := e.root.eval(vars)
+	if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// Parses an expression, the error points at the offending position.
+func Parse(s string) (*Expr, error) {
+	if len(s) > MaxLength {
+		return nil, fmt.Errorf("expression length %d exceeds maximum of %d", len(s), MaxLength)
+	}
+	p := &parser{input: s, seen: make(map[string]struct{})}
+	p.next()
+	root, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Expr{root: root, variables: p.variables}, nil
+}
+
+// ---------------------------
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type parser struct {
+	input     string
+	pos       int
+	tok       token
+	err       error
+	variables []string
+	seen      map[string]struct{}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("position %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func isIdentRune(r rune, first bool) bool {
+	if r == '_' || unicode.IsLetter(r) {
+		return true
+	}
+	// Dots access nested properties such as nested.field
+	return !first && (unicode.IsDigit(r) || r == '.')
+}
+
+// Reads the next token into p.tok, recording the first error.
+func (p *parser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+	c := rune(p.input[p.pos])
+	switch {
+	case strings.ContainsRune("+-*/(),", c):
+		p.pos++
+		p.tok = token{kind: tokenOperator, text: string(c), pos: start}
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.input) && (unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponents such as 1e-3
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			p.pos++
+			if p.pos < len(p.input) && (p.input[p.pos] == '+' || p.input[p.pos] == '-') {
+				p.pos++
+			}
+			for p.pos < len(p.input) && unicode.IsDigit(rune(p.input[p.pos])) {
+				p.pos++
+			}
+		}
+		text := p.input[start:p.pos]
+		value, err := strconv.ParseFloat(text, 64)
+		p.tok = token{kind: tokenNumber, text: text, value: value, pos: start}
+		if err != nil && p.err == nil {
+			p.err = p.errorf("invalid number %q", text)
+		}
+	case isIdentRune(c, true):
+		for p.pos < len(p.input) && isIdentRune(rune(p.input[p.pos]), false) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenIdent, text: p.input[start:p.pos], pos: start}
+	default:
+		p.tok = token{kind: tokenOperator, text: string(c), pos: start}
+		if p.err == nil {
+			p.err = p.errorf("unexpected character %q", c)
+		}
+	}
+}
+
+func (p *parser) parseExpr(depth int) (node, error) {
+	left, err := p.parseTerm(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOperator && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseTerm(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseTerm(depth int) (node, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOperator && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, p.errorf("expression nested deeper than %d", maxDepth)
+	}
+	if p.tok.kind == tokenOperator && p.tok.text == "-" {
+		p.next()
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negateNode{operand: operand}, nil
+	}
+	return p.parsePrimary(depth)
+}
+
+func (p *parser) parsePrimary(depth int) (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch {
+	case tok.kind == tokenNumber:
+		p.next()
+		return numberNode(tok.value), nil
+	case tok.kind == tokenIdent:
+		p.next()
+		if p.tok.kind == tokenOperator && p.tok.text == "(" {
+			return p.parseCall(tok, depth)
+		}
+		if _, ok := p.seen[tok.text]; !ok {
+			p.seen[tok.text] = struct{}{}
+			p.variables = append(p.variables, tok.text)
+		}
+		return variableNode(tok.text), nil
+	case tok.kind == tokenOperator && tok.text == "(":
+		p.next()
+		inner, err := p.parseExpr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return nil, p.errorf("unexpected %s, expected a number, variable or function", tok)
+}
+
+// Parses the arguments of a function call, the current token is the opening
+// parenthesis.
+func (p *parser) parseCall(name token, depth int) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("position %d: unknown function %s", name.pos+1, name.text)
+	}
+	p.next()
+	var args []node
+	for {
+		arg, err := p.parseExpr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.tok.kind == tokenOperator && p.tok.text == "," {
+			p.next()
+			continue
+		}
+		break
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		if fn.minArgs == fn.maxArgs {
+			return nil, fmt.Errorf("position %d: function %s expects %d arguments, got %d", name.pos+1, name.text, fn.minArgs, len(args))
+		}
+		return nil, fmt.Errorf("position %d: function %s expects %d to %d arguments, got %d", name.pos+1, name.text, fn.minArgs, fn.maxArgs, len(args))
+	}
+	return callNode{fn: fn, args: args}, nil
+}
+
+func (p *parser) expect(text string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.tok.kind != tokenOperator || p.tok.text != text {
+		return p.errorf("unexpected %s, expected %q", p.tok, text)
+	}
+	p.next()
+	return nil
+}
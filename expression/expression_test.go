@@ -0,0 +1,98 @@
+package expression_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/semafind/semadb/expression"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{
+		"_hybridScore": 2,
+		"views":        math.E - 1,
+		"ageDays":      10,
+		"nested.size":  4,
+	}
+	tests := []struct {
+		expr     string
+		expected float64
+	}{
+		{"1", 1},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"-2 * -3", 6},
+		{"--2", 2},
+		{"1.5e2 + .5", 150.5},
+		{"0.7*_hybridScore + 0.3*log(1+views) - 0.01*ageDays", 1.6},
+		{"sqrt(nested.size) + abs(-1)", 3},
+		{"min(3, ageDays, 5) + max(1, 2)", 5},
+		{"pow(2, 10)", 1024},
+		{"log10(100) * exp(0) + log1p(0)", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := expression.Parse(tt.expr)
+			require.NoError(t, err)
+			v, ok := e.Eval(vars)
+			require.True(t, ok)
+			require.InDelta(t, tt.expected, v, 1e-9)
+		})
+	}
+}
+
+func TestEval_Missing(t *testing.T) {
+	e, err := expression.Parse("_distance + views")
+	require.NoError(t, err)
+	require.Equal(t, []string{"_distance", "views"}, e.Variables())
+	_, ok := e.Eval(map[string]float64{"views": 1})
+	require.False(t, ok)
+	v, ok := e.Eval(map[string]float64{"views": 1, "_distance": 2})
+	require.True(t, ok)
+	require.Equal(t, 3.0, v)
+	// Results that are not finite numbers count as missing
+	e, err = expression.Parse("views / 0")
+	require.NoError(t, err)
+	_, ok = e.Eval(map[string]float64{"views": 1})
+	require.False(t, ok)
+	e, err = expression.Parse("log(-1)")
+	require.NoError(t, err)
+	_, ok = e.Eval(nil)
+	require.False(t, ok)
+}
+
+func TestParse_Variables(t *testing.T) {
+	e, err := expression.Parse("a * b + a - log(c) + 2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, e.Variables())
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "expected a number"},
+		{"1 +", "expected a number"},
+		{"(1 + 2", `expected ")"`},
+		{"1 2", `unexpected "2"`},
+		{"1 $ 2", "unexpected character"},
+		{"1.2.3", "invalid number"},
+		{"unknown(1)", "unknown function unknown"},
+		{"log(1, 2)", "function log expects 1 arguments, got 2"},
+		{"min(1)", "function min expects 2 to 8 arguments, got 1"},
+		{"log(", "expected a number"},
+		{strings.Repeat("(", 40) + "1" + strings.Repeat(")", 40), "nested deeper"},
+		{strings.Repeat("1+", expression.MaxLength), "exceeds maximum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := expression.Parse(tt.expr)
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -331,7 +331,7 @@ func (sdbh *SemaDBHandlers) HandleInsertPoints(w http.ResponseWriter, r *http.Re
 	}
 	// ---------------------------
 	// Insert points returns a range of errors for failed shards
-	failedRanges, err := sdbh.clusterNode.InsertPoints(collection, points)
+	failedRanges, err := sdbh.clusterNode.InsertPoints(collection, points, false)
 	if errors.Is(err, cluster.ErrQuotaReached) {
 		utils.Encode(w, http.StatusForbidden, map[string]string{"error": "quota reached"})
 		return
@@ -555,7 +555,7 @@ func (sdbh *SemaDBHandlers) HandleSearchPoints(w http.ResponseWriter, r *http.Re
 		Select: []string{"metadata"},
 		Limit:  req.Limit,
 	}
-	points, err := sdbh.clusterNode.SearchPoints(collection, sr)
+	points, _, err := sdbh.clusterNode.SearchPoints(collection, sr)
 	if err != nil {
 		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
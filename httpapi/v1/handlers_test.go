@@ -83,7 +83,7 @@ func setupClusterNode(t *testing.T, nodeS clusterNodeState) *cluster.ClusterNode
 				Data: pointDataBytes,
 			}
 		}
-		failedRanges, err := cnode.InsertPoints(colState.Collection, points)
+		failedRanges, err := cnode.InsertPoints(colState.Collection, points, false)
 		require.NoError(t, err)
 		require.Len(t, failedRanges, 0)
 	}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -40,12 +42,17 @@ func SetupV2Handlers(clusterNode *cluster.ClusterNode) http.Handler {
 	}
 	mux.Handle("GET /collections/{collectionId}", withCol(semaDBHandlers.HandleGetCollection))
 	mux.Handle("DELETE /collections/{collectionId}", withCol(semaDBHandlers.HandleDeleteCollection))
+	mux.Handle("PUT /collections/{collectionId}/synonyms", withCol(semaDBHandlers.HandleSetSynonyms))
 	// We're batching point requests for peformance reasons. Alternatively we
 	// can provide points/:pointId endpoint in the future.
 	mux.Handle("POST /collections/{collectionId}/points", withCol(semaDBHandlers.HandleInsertPoints))
 	mux.Handle("PUT /collections/{collectionId}/points", withCol(semaDBHandlers.HandleUpdatePoints))
 	mux.Handle("DELETE /collections/{collectionId}/points", withCol(semaDBHandlers.HandleDeletePoints))
+	mux.Handle("POST /collections/{collectionId}/points/delete", withCol(semaDBHandlers.HandleDeletePointsByQuery))
 	mux.Handle("POST /collections/{collectionId}/points/search", withCol(semaDBHandlers.HandleSearchPoints))
+	mux.Handle("POST /collections/{collectionId}/points/search/batch", withCol(semaDBHandlers.HandleSearchBatch))
+	mux.Handle("POST /collections/{collectionId}/points/count", withCol(semaDBHandlers.HandleCountPoints))
+	mux.Handle("POST /collections/{collectionId}/suggest", withCol(semaDBHandlers.HandleSuggest))
 	// ---------------------------
 	return mux
 }
@@ -169,13 +176,15 @@ func (sdbh *SemaDBHandlers) CollectionURIMiddleware(next http.Handler) http.Hand
 // ---------------------------
 
 type ShardItem struct {
-	Id         string `json:"id"`
-	PointCount int64  `json:"pointCount"`
+	Id                 string                              `json:"id"`
+	PointCount         int64                               `json:"pointCount"`
+	VamanaCalibrations map[string]models.VamanaCalibration `json:"vamanaCalibrations,omitempty"`
 }
 
 type GetCollectionResponse struct {
 	Id          string             `json:"id"`
 	IndexSchema models.IndexSchema `json:"indexSchema"`
+	Synonyms    [][]string         `json:"synonyms,omitempty"`
 	Shards      []ShardItem        `json:"shards"`
 }
 
@@ -195,11 +204,12 @@ func (sdbh *SemaDBHandlers) HandleGetCollection(w http.ResponseWriter, r *http.R
 	// ---------------------------
 	shardItems := make([]ShardItem, len(shards))
 	for i, shard := range shards {
-		shardItems[i] = ShardItem{Id: shard.Id, PointCount: shard.PointCount}
+		shardItems[i] = ShardItem{Id: shard.Id, PointCount: shard.PointCount, VamanaCalibrations: shard.VamanaCalibrations}
 	}
 	resp := GetCollectionResponse{
 		Id:          collection.Id,
 		IndexSchema: collection.IndexSchema,
+		Synonyms:    collection.Synonyms,
 		Shards:      shardItems,
 	}
 	utils.Encode(w, http.StatusOK, resp)
@@ -225,8 +235,57 @@ func (sdbh *SemaDBHandlers) HandleDeleteCollection(w http.ResponseWriter, r *htt
 
 // ---------------------------
 
+type SetSynonymsRequest struct {
+	Synonyms [][]string `json:"synonyms" binding:"max=1000,dive,min=2,max=20,dive,min=1,max=64"`
+}
+
+func (req SetSynonymsRequest) Validate() error {
+	if len(req.Synonyms) > 1000 {
+		return fmt.Errorf("number of synonym sets must be at most 1000, got %d", len(req.Synonyms))
+	}
+	for _, set := range req.Synonyms {
+		if len(set) < 2 || len(set) > 20 {
+			return fmt.Errorf("synonym sets must have between 2 and 20 words, got %d", len(set))
+		}
+		for _, word := range set {
+			if len(word) < 1 || len(word) > 64 {
+				return fmt.Errorf("synonyms must be between 1 and 64 characters, got %d", len(word))
+			}
+			// Synonyms expand single query terms so phrases can't match
+			if strings.IndexFunc(word, unicode.IsSpace) != -1 {
+				return fmt.Errorf("synonyms must be single words, got %s", word)
+			}
+		}
+	}
+	return nil
+}
+
+func (sdbh *SemaDBHandlers) HandleSetSynonyms(w http.ResponseWriter, r *http.Request) {
+	req, err := utils.DecodeValid[SetSynonymsRequest](r)
+	if err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// ---------------------------
+	collection := r.Context().Value(collectionContextKey).(models.Collection)
+	// ---------------------------
+	switch err := sdbh.clusterNode.SetCollectionSynonyms(collection, req.Synonyms); err {
+	case nil:
+		utils.Encode(w, http.StatusOK, map[string]string{"message": "synonyms updated"})
+	case cluster.ErrNotFound:
+		utils.Encode(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("collection %s not found", collection.Id)})
+	default:
+		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Error().Err(err).Str("id", collection.Id).Msg("SetCollectionSynonyms failed")
+	}
+}
+
+// ---------------------------
+
 type InsertPointsRequest struct {
 	Points []models.PointAsMap `json:"points" binding:"required,max=10000"`
+	// Build the indices in bulk, useful for initial loads
+	Bulk bool `json:"bulk"`
 }
 
 func (req InsertPointsRequest) Validate() error {
@@ -283,7 +342,7 @@ func (sdbh *SemaDBHandlers) HandleInsertPoints(w http.ResponseWriter, r *http.Re
 	}
 	// ---------------------------
 	// Insert points returns a range of errors for failed shards
-	failedRanges, err := sdbh.clusterNode.InsertPoints(collection, points)
+	failedRanges, err := sdbh.clusterNode.InsertPoints(collection, points, req.Bulk)
 	if errors.Is(err, cluster.ErrQuotaReached) {
 		utils.Encode(w, http.StatusForbidden, map[string]string{"error": "quota reached"})
 		return
@@ -428,8 +487,43 @@ func (sdbh *SemaDBHandlers) HandleDeletePoints(w http.ResponseWriter, r *http.Re
 
 // ---------------------------
 
+type DeletePointsByQueryResponse struct {
+	Message      string `json:"message"`
+	DeletedCount int    `json:"deletedCount"`
+	FailedShards int    `json:"failedShards"`
+}
+
+func (sdbh *SemaDBHandlers) HandleDeletePointsByQuery(w http.ResponseWriter, r *http.Request) {
+	// ---------------------------
+	req, err := utils.DecodeValid[models.DeleteByQueryRequest](r)
+	if err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// ---------------------------
+	collection := r.Context().Value(collectionContextKey).(models.Collection)
+	if err := req.ValidateSchema(collection.IndexSchema); err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// ---------------------------
+	deletedCount, failedShards, err := sdbh.clusterNode.DeletePointsByQuery(collection, req.Query)
+	if err != nil {
+		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	resp := DeletePointsByQueryResponse{Message: "success", DeletedCount: deletedCount, FailedShards: failedShards}
+	if failedShards > 0 {
+		resp.Message = "partial success"
+	}
+	utils.Encode(w, http.StatusOK, resp)
+}
+
+// ---------------------------
+
 type SearchPointsResponse struct {
-	Points []models.PointAsMap `json:"points"`
+	Points  []models.PointAsMap   `json:"points"`
+	Profile *models.SearchProfile `json:"profile,omitempty"`
 }
 
 func (sdbh *SemaDBHandlers) HandleSearchPoints(w http.ResponseWriter, r *http.Request) {
@@ -448,16 +542,94 @@ func (sdbh *SemaDBHandlers) HandleSearchPoints(w http.ResponseWriter, r *http.Re
 	collection := r.Context().Value(collectionContextKey).(models.Collection)
 	// ---------------------------
 	// Validate query against schema, checks vector dimensions, query options etc.
-	if err := req.Query.ValidateSchema(collection.IndexSchema); err != nil {
+	if err := req.ValidateSchema(collection.IndexSchema); err != nil {
 		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 	// ---------------------------
-	points, err := sdbh.clusterNode.SearchPoints(collection, req)
+	points, profile, err := sdbh.clusterNode.SearchPoints(collection, req)
+	if err != nil {
+		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	results, err := searchResultsToMaps(points)
 	if err != nil {
 		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	resp := SearchPointsResponse{Points: results, Profile: profile}
+	utils.Encode(w, http.StatusOK, resp)
+	// ---------------------------
+}
+
+// ---------------------------
+
+type SearchBatchResult struct {
+	Points []models.PointAsMap `json:"points"`
+	Error  string              `json:"error,omitempty"`
+}
+
+type SearchBatchResponse struct {
+	Results []SearchBatchResult `json:"results"`
+}
+
+func (sdbh *SemaDBHandlers) HandleSearchBatch(w http.ResponseWriter, r *http.Request) {
+	// ---------------------------
+	req, err := utils.DecodeValid[models.SearchBatchRequest](r)
+	if err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// ---------------------------
+	collection := r.Context().Value(collectionContextKey).(models.Collection)
+	// ---------------------------
+	/* Invalid searches are reported in their place and only the valid ones are
+	 * run, so we keep track of where each search belongs in the response. */
+	resp := SearchBatchResponse{Results: make([]SearchBatchResult, len(req))}
+	searches := make([]models.SearchRequest, 0, len(req))
+	searchIndices := make([]int, 0, len(req))
+	for i, sr := range req {
+		// Default limit is 10
+		if sr.Limit == 0 {
+			sr.Limit = 10
+		}
+		if err := sr.Validate(); err != nil {
+			resp.Results[i].Error = fmt.Sprintf("validation error: %v", err)
+			continue
+		}
+		if err := sr.ValidateSchema(collection.IndexSchema); err != nil {
+			resp.Results[i].Error = err.Error()
+			continue
+		}
+		if sr.Profile {
+			resp.Results[i].Error = "profile is not supported in batch searches"
+			continue
+		}
+		searches = append(searches, sr)
+		searchIndices = append(searchIndices, i)
+	}
+	// ---------------------------
+	if len(searches) > 0 {
+		points, errs := sdbh.clusterNode.SearchPointsBatch(collection, searches)
+		for j, i := range searchIndices {
+			if errs[j] != nil {
+				resp.Results[i].Error = errs[j].Error()
+				continue
+			}
+			results, err := searchResultsToMaps(points[j])
+			if err != nil {
+				resp.Results[i].Error = err.Error()
+				continue
+			}
+			resp.Results[i].Points = results
+		}
+	}
+	utils.Encode(w, http.StatusOK, resp)
+}
+
+// searchResultsToMaps decodes the point data of the search results and adds
+// the system fields such as _id and _distance.
+func searchResultsToMaps(points []models.SearchResult) ([]models.PointAsMap, error) {
 	results := make([]models.PointAsMap, len(points))
 	for i, sp := range points {
 		pointData := sp.DecodedData
@@ -465,9 +637,7 @@ func (sdbh *SemaDBHandlers) HandleSearchPoints(w http.ResponseWriter, r *http.Re
 			pointData = models.PointAsMap{}
 			if len(sp.Point.Data) > 0 {
 				if err := msgpack.Unmarshal(sp.Point.Data, &pointData); err != nil {
-					errMsg := fmt.Sprintf("could not decode point %s", sp.Point.Id.String())
-					utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": errMsg})
-					return
+					return nil, fmt.Errorf("could not decode point %s", sp.Point.Id.String())
 				}
 			}
 		}
@@ -481,9 +651,79 @@ func (sdbh *SemaDBHandlers) HandleSearchPoints(w http.ResponseWriter, r *http.Re
 			pointData["_score"] = *sp.Score
 		}
 		pointData["_hybridScore"] = sp.HybridScore
+		if len(sp.Highlights) > 0 {
+			pointData["_highlights"] = sp.Highlights
+		}
+		if len(sp.SortDistances) > 0 {
+			pointData["_sortDistances"] = sp.SortDistances
+		}
+		if sp.CustomScore != nil {
+			pointData["_customScore"] = *sp.CustomScore
+		}
+		if sp.Explanation != nil {
+			pointData["_explain"] = sp.Explanation
+		}
 		results[i] = pointData
 	}
-	resp := SearchPointsResponse{Points: results}
-	utils.Encode(w, http.StatusOK, resp)
+	return results, nil
+}
+
+// ---------------------------
+
+type CountPointsResponse struct {
+	Count uint64 `json:"count"`
+}
+
+func (sdbh *SemaDBHandlers) HandleCountPoints(w http.ResponseWriter, r *http.Request) {
+	// ---------------------------
+	req, err := utils.DecodeValid[models.CountRequest](r)
+	if err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 	// ---------------------------
+	collection := r.Context().Value(collectionContextKey).(models.Collection)
+	if err := req.ValidateSchema(collection.IndexSchema); err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// ---------------------------
+	count, err := sdbh.clusterNode.CountPoints(collection, req)
+	if err != nil {
+		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.Encode(w, http.StatusOK, CountPointsResponse{Count: count})
+}
+
+// ---------------------------
+
+type SuggestResponse struct {
+	Suggestions []models.Suggestion `json:"suggestions"`
+}
+
+func (sdbh *SemaDBHandlers) HandleSuggest(w http.ResponseWriter, r *http.Request) {
+	// ---------------------------
+	req, err := utils.DecodeValid[models.SuggestRequest](r)
+	if err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// Default limit is 10
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+	// ---------------------------
+	collection := r.Context().Value(collectionContextKey).(models.Collection)
+	if err := req.ValidateSchema(collection.IndexSchema); err != nil {
+		utils.Encode(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// ---------------------------
+	suggestions, err := sdbh.clusterNode.Suggest(collection, req)
+	if err != nil {
+		utils.Encode(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	utils.Encode(w, http.StatusOK, SuggestResponse{Suggestions: suggestions})
 }
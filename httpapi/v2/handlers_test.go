@@ -131,7 +131,7 @@ func setupClusterNode(t *testing.T, nodeS clusterNodeState) *cluster.ClusterNode
 				Data: pointDataBytes,
 			}
 		}
-		failedRanges, err := cnode.InsertPoints(colState.Collection, points)
+		failedRanges, err := cnode.InsertPoints(colState.Collection, points, false)
 		require.NoError(t, err)
 		require.Len(t, failedRanges, 0)
 	}
@@ -362,6 +362,86 @@ func Test_DeleteCollection(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, resp)
 }
 
+func Test_SetSynonyms(t *testing.T) {
+	nodeS := clusterNodeState{
+		Collections: []collectionState{
+			{
+				Collection: sampleCollection,
+				Points: []pointState{
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{1, 2},
+							"description": "hobbit frodo halfling",
+						},
+					},
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{2, 3},
+							"description": "halfling sam",
+						},
+					},
+				},
+			},
+		},
+	}
+	router := setupTestRouter(t, nodeS)
+	// ---------------------------
+	// Unknown collection returns not found
+	req := v2.SetSynonymsRequest{Synonyms: [][]string{{"hobbit", "halfling"}}}
+	resp := makeRequest(t, router, "PUT", "/collections/boromir/synonyms", req, nil)
+	require.Equal(t, http.StatusNotFound, resp)
+	// ---------------------------
+	invalid := [][][]string{
+		{{"hobbit"}},
+		{{"hobbit", ""}},
+		{{"hobbit", "little person"}},
+	}
+	for _, synonyms := range invalid {
+		resp = makeRequest(t, router, "PUT", "/collections/gandalf/synonyms", v2.SetSynonymsRequest{Synonyms: synonyms}, nil)
+		require.Equal(t, http.StatusBadRequest, resp)
+	}
+	// ---------------------------
+	resp = makeRequest(t, router, "PUT", "/collections/gandalf/synonyms", req, nil)
+	require.Equal(t, http.StatusOK, resp)
+	var colResp v2.GetCollectionResponse
+	resp = makeRequest(t, router, "GET", "/collections/gandalf", nil, &colResp)
+	require.Equal(t, http.StatusOK, resp)
+	require.Equal(t, req.Synonyms, colResp.Synonyms)
+	// ---------------------------
+	// The shard was loaded before the synonyms were set, search should still
+	// use them
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "description",
+			Text: &models.SearchTextOptions{
+				Value:    "hobbit",
+				Operator: models.OperatorContainsAny,
+				Limit:    10,
+			},
+		},
+		Select: []string{"description"},
+		Limit:  10,
+	}
+	var respBody v2.SearchPointsResponse
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	// Halfling occurs in both points so it is more common than hobbit, and
+	// synonym matches lose a share of their score on top
+	require.Len(t, respBody.Points, 2)
+	require.Equal(t, "hobbit frodo halfling", respBody.Points[0]["description"])
+	require.Equal(t, "halfling sam", respBody.Points[1]["description"])
+	require.Greater(t, respBody.Points[0]["_score"], respBody.Points[1]["_score"])
+	// ---------------------------
+	// Setting an empty list removes the synonyms
+	resp = makeRequest(t, router, "PUT", "/collections/gandalf/synonyms", v2.SetSynonymsRequest{}, nil)
+	require.Equal(t, http.StatusOK, resp)
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Len(t, respBody.Points, 1)
+}
+
 func Test_InsertPoints(t *testing.T) {
 	nodeS := clusterNodeState{
 		Collections: []collectionState{
@@ -584,6 +664,70 @@ func Test_DeletePoints(t *testing.T) {
 	require.Equal(t, r.Ids[0], respBody.FailedPoints[0].Id.String())
 }
 
+func Test_DeletePointsByQuery(t *testing.T) {
+	nodeS := clusterNodeState{
+		Collections: []collectionState{
+			{
+				Collection: sampleCollection,
+				Points: []pointState{
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{1, 2},
+							"description": "hobbit frodo",
+						},
+					},
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{2, 3},
+							"description": "hobbit sam",
+						},
+					},
+				},
+			},
+		},
+	}
+	router := setupTestRouter(t, nodeS)
+	// ---------------------------
+	textQuery := func(value string) models.Query {
+		return models.Query{
+			Property: "description",
+			Text: &models.SearchTextOptions{
+				Value:    value,
+				Operator: models.OperatorContainsAll,
+				Limit:    10,
+			},
+		}
+	}
+	req := models.DeleteByQueryRequest{Query: textQuery("frodo")}
+	var respBody v2.DeletePointsByQueryResponse
+	resp := makeRequest(t, router, "POST", "/collections/gandalf/points/delete", req, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Equal(t, "success", respBody.Message)
+	require.Equal(t, 1, respBody.DeletedCount)
+	require.Equal(t, 0, respBody.FailedShards)
+	// ---------------------------
+	sr := models.SearchRequest{Query: textQuery("hobbit"), Limit: 10}
+	var searchBody v2.SearchPointsResponse
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, &searchBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Len(t, searchBody.Points, 1)
+	require.Equal(t, nodeS.Collections[0].Points[1].Id.String(), searchBody.Points[0]["_id"])
+	// ---------------------------
+	req.Query = models.Query{
+		Property: "vector",
+		VectorVamana: &models.SearchVectorVamanaOptions{
+			Vector:     []float32{1, 2},
+			Operator:   models.OperatorNear,
+			SearchSize: 75,
+			Limit:      1,
+		},
+	}
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/delete", req, nil)
+	require.Equal(t, http.StatusBadRequest, resp)
+}
+
 func Test_SearchPoints_Empty(t *testing.T) {
 	nodeS := clusterNodeState{
 		Collections: []collectionState{
@@ -655,6 +799,207 @@ func Test_SearchPoints(t *testing.T) {
 	require.Equal(t, "hobbit frodo", respBody.Points[0]["description"])
 	require.Equal(t, float64(0), respBody.Points[0]["_hybridScore"])
 	require.Equal(t, nodeS.Collections[0].Points[0].Id.String(), respBody.Points[0]["_id"])
+	require.NotContains(t, respBody.Points[0], "_explain")
+	// ---------------------------
+	sr.Explain = true
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Len(t, respBody.Points, 1)
+	explanation := respBody.Points[0]["_explain"].(map[string]any)
+	require.NotEmpty(t, explanation["shard"])
+	queries := explanation["queries"].([]any)
+	require.Len(t, queries, 1)
+	query := queries[0].(map[string]any)
+	require.Equal(t, "description", query["property"])
+	require.Equal(t, float64(1), query["weight"])
+	require.Equal(t, respBody.Points[0]["_hybridScore"], query["contribution"])
+	require.Nil(t, respBody.Profile)
+	// ---------------------------
+	sr.Explain = false
+	sr.Profile = true
+	var profileBody v2.SearchPointsResponse
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, &profileBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Len(t, profileBody.Points, 1)
+	require.NotNil(t, profileBody.Profile)
+	require.Len(t, profileBody.Profile.Shards, 1)
+	shardProfile := profileBody.Profile.Shards[0]
+	require.NotEmpty(t, shardProfile.Shard)
+	require.GreaterOrEqual(t, shardProfile.LatencyMs, shardProfile.TotalMs)
+	require.Equal(t, "description", shardProfile.Query.Property)
+	require.Equal(t, 1, shardProfile.Query.Results)
+}
+
+func Test_SearchPoints_Recommend(t *testing.T) {
+	points := make([]pointState, 2)
+	for i := range points {
+		points[i] = pointState{
+			Id: uuid.New(),
+			Data: models.PointAsMap{
+				"vector": []float32{float32(i), 0},
+			},
+		}
+	}
+	nodeS := clusterNodeState{
+		Collections: []collectionState{
+			{
+				Collection: sampleCollection,
+				Points:     points,
+			},
+		},
+	}
+	router := setupTestRouter(t, nodeS)
+	// ---------------------------
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "vector",
+			VectorVamana: &models.SearchVectorVamanaOptions{
+				Operator:   models.OperatorRecommend,
+				SearchSize: 75,
+				Limit:      2,
+				Recommend: &models.SearchRecommendOptions{
+					Positive: []string{points[0].Id.String()},
+				},
+			},
+		},
+		Limit: 2,
+	}
+	// The example is not recommended back
+	var respBody v2.SearchPointsResponse
+	resp := makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Len(t, respBody.Points, 1)
+	require.Equal(t, points[1].Id.String(), respBody.Points[0]["_id"])
+	// ---------------------------
+	sr.Query.VectorVamana.Vector = []float32{1, 2}
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search", sr, nil)
+	require.Equal(t, http.StatusBadRequest, resp)
+}
+
+func Test_SearchBatch(t *testing.T) {
+	nodeS := clusterNodeState{
+		Collections: []collectionState{
+			{
+				Collection: sampleCollection,
+				Points: []pointState{
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{1, 2},
+							"description": "hobbit frodo",
+						},
+					},
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{2, 3},
+							"description": "hobbit sam",
+						},
+					},
+				},
+			},
+		},
+	}
+	router := setupTestRouter(t, nodeS)
+	// ---------------------------
+	textSearch := func(value string) models.SearchRequest {
+		return models.SearchRequest{
+			Query: models.Query{
+				Property: "description",
+				Text: &models.SearchTextOptions{
+					Value:    value,
+					Operator: models.OperatorContainsAll,
+					Limit:    10,
+				},
+			},
+			Select: []string{"description"},
+		}
+	}
+	invalidSearch := models.SearchRequest{
+		Query: models.Query{
+			Property: "gandalf",
+			Integer: &models.SearchIntegerOptions{
+				Value:    1,
+				Operator: models.OperatorEquals,
+			},
+		},
+		Limit: 10,
+	}
+	req := models.SearchBatchRequest{textSearch("frodo"), invalidSearch, textSearch("hobbit")}
+	var respBody v2.SearchBatchResponse
+	resp := makeRequest(t, router, "POST", "/collections/gandalf/points/search/batch", req, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Len(t, respBody.Results, 3)
+	// The results are in the order of the searches
+	require.Empty(t, respBody.Results[0].Error)
+	require.Len(t, respBody.Results[0].Points, 1)
+	require.Equal(t, "hobbit frodo", respBody.Results[0].Points[0]["description"])
+	require.Equal(t, nodeS.Collections[0].Points[0].Id.String(), respBody.Results[0].Points[0]["_id"])
+	require.NotEmpty(t, respBody.Results[1].Error)
+	require.Nil(t, respBody.Results[1].Points)
+	require.Empty(t, respBody.Results[2].Error)
+	require.Len(t, respBody.Results[2].Points, 2)
+	// ---------------------------
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/search/batch", models.SearchBatchRequest{}, nil)
+	require.Equal(t, http.StatusBadRequest, resp)
+}
+
+func Test_CountPoints(t *testing.T) {
+	nodeS := clusterNodeState{
+		Collections: []collectionState{
+			{
+				Collection: sampleCollection,
+				Points: []pointState{
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{1, 2},
+							"description": "hobbit frodo",
+						},
+					},
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{2, 3},
+							"description": "hobbit sam",
+						},
+					},
+				},
+			},
+		},
+	}
+	router := setupTestRouter(t, nodeS)
+	// ---------------------------
+	req := models.CountRequest{
+		Query: models.Query{
+			Property: "description",
+			Text: &models.SearchTextOptions{
+				Value:    "hobbit",
+				Operator: models.OperatorContainsAll,
+				Limit:    1,
+			},
+		},
+	}
+	var respBody v2.CountPointsResponse
+	resp := makeRequest(t, router, "POST", "/collections/gandalf/points/count", req, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.EqualValues(t, 2, respBody.Count)
+	// ---------------------------
+	req.Query = models.Query{
+		Property: "vector",
+		VectorVamana: &models.SearchVectorVamanaOptions{
+			Vector:     []float32{1, 2},
+			Operator:   models.OperatorNear,
+			SearchSize: 75,
+			Limit:      1,
+		},
+	}
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/count", req, nil)
+	require.Equal(t, http.StatusBadRequest, resp)
+	req.Approximate = true
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/points/count", req, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.EqualValues(t, 1, respBody.Count)
 }
 
 func Test_SearchPoints_NonExistent(t *testing.T) {
@@ -795,3 +1140,56 @@ func Test_SearchPoints_Invalid(t *testing.T) {
 		})
 	}
 }
+
+func Test_Suggest(t *testing.T) {
+	nodeS := clusterNodeState{
+		Collections: []collectionState{
+			{
+				Collection: sampleCollection,
+				Points: []pointState{
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{1, 2},
+							"description": "hobbit frodo",
+						},
+					},
+					{
+						Id: uuid.New(),
+						Data: models.PointAsMap{
+							"vector":      []float32{2, 3},
+							"description": "hobbit in the fields",
+						},
+					},
+				},
+			},
+		},
+	}
+	router := setupTestRouter(t, nodeS)
+	// ---------------------------
+	req := models.SuggestRequest{
+		Property: "description",
+		Prefix:   "f",
+	}
+	var respBody v2.SuggestResponse
+	resp := makeRequest(t, router, "POST", "/collections/gandalf/suggest", req, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Equal(t, []models.Suggestion{
+		{Term: "fields", DocumentCount: 1},
+		{Term: "frodo", DocumentCount: 1},
+	}, respBody.Suggestions)
+	// ---------------------------
+	req.Prefix = "hob"
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/suggest", req, &respBody)
+	require.Equal(t, http.StatusOK, resp)
+	require.Equal(t, []models.Suggestion{{Term: "hobbit", DocumentCount: 2}}, respBody.Suggestions)
+	// ---------------------------
+	// Only text properties can be suggested from
+	req.Property = "size"
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/suggest", req, nil)
+	require.Equal(t, http.StatusBadRequest, resp)
+	req.Property = "description"
+	req.Prefix = ""
+	resp = makeRequest(t, router, "POST", "/collections/gandalf/suggest", req, nil)
+	require.Equal(t, http.StatusBadRequest, resp)
+}
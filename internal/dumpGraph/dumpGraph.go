@@ -1,16 +1,26 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
 
+	"github.com/RoaringBitmap/roaring/roaring64"
 	"github.com/rs/zerolog/log"
 	"github.com/semafind/semadb/conversion"
 	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/index/vamana"
+	"github.com/semafind/semadb/shard/pointstore"
 )
 
 // Run using
 // go run ./internal/dumpGraph/dumpGraph.go -path /path/to/db
+// To verify the graph instead of dumping it, give the index parameters as in
+// the collection schema
+// go run ./internal/dumpGraph/dumpGraph.go -path /path/to/db -verify -params '{"vectorSize": 128, "distanceMetric": "euclidean", ...}'
+// and add -repair to fix the problems found.
 
 func main() {
 	// ---------------------------
@@ -19,6 +29,12 @@ func main() {
 	flag.StringVar(&dbPath, "path", "", "Path to the database")
 	var buckeName string
 	flag.StringVar(&buckeName, "bucket", "index/vectorVamana/vector", "Name of the bucket to dump")
+	var verify bool
+	flag.BoolVar(&verify, "verify", false, "Verify the graph and print a report instead of dumping it")
+	var repair bool
+	flag.BoolVar(&repair, "repair", false, "Repair the problems found when verifying")
+	var paramsJSON string
+	flag.StringVar(&paramsJSON, "params", "", "Vamana index parameters as JSON, required to verify")
 	flag.Parse()
 	log.Info().Str("path", dbPath).Msg("starting dumpGraph")
 	// ---------------------------
@@ -28,6 +44,17 @@ func main() {
 	}
 	defer db.Close()
 	// ---------------------------
+	if verify || repair {
+		var params models.IndexVectorVamanaParameters
+		if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+			log.Fatal().Err(err).Msg("could not parse index parameters")
+		}
+		if err := verifyGraph(db, buckeName, params, repair); err != nil {
+			log.Fatal().Err(err).Msg("could not verify graph index")
+		}
+		return
+	}
+	// ---------------------------
 	err = db.Read(func(bm diskstore.BucketManager) error {
 		b, err := bm.Get(buckeName)
 		if err != nil {
@@ -53,3 +80,55 @@ func main() {
 	}
 	// ---------------------------
 }
+
+func verifyGraph(db diskstore.DiskStore, bucketName string, params models.IndexVectorVamanaParameters, repair bool) error {
+	verifyFn := func(bm diskstore.BucketManager) error {
+		// ---------------------------
+		// Collect the node ids of the points to check against
+		pointsBucket, err := bm.Get(pointstore.POINTSBUCKETNAME)
+		if err != nil {
+			return fmt.Errorf("could not get points bucket: %w", err)
+		}
+		pointNodeIds := roaring64.New()
+		err = pointsBucket.ForEach(func(k, v []byte) error {
+			if nodeId, ok := conversion.NodeIdFromKey(k, 'i'); ok {
+				pointNodeIds.Add(nodeId)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not scan points: %w", err)
+		}
+		// ---------------------------
+		b, err := bm.Get(bucketName)
+		if err != nil {
+			return err
+		}
+		index, err := vamana.NewIndexVamana(bucketName, params, b)
+		if err != nil {
+			return fmt.Errorf("could not load index: %w", err)
+		}
+		report, err := index.Verify(pointNodeIds)
+		if err != nil {
+			return fmt.Errorf("could not verify index: %w", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("could not print report: %w", err)
+		}
+		if !repair || report.IsValid() {
+			return nil
+		}
+		// ---------------------------
+		if err := index.Repair(report); err != nil {
+			return fmt.Errorf("could not repair index: %w", err)
+		}
+		log.Info().Msg("repaired graph index")
+		return nil
+	}
+	if repair {
+		return db.Write(verifyFn)
+	}
+	return db.Read(verifyFn)
+}
@@ -3,6 +3,7 @@ package main
 
 import (
 	"C"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -183,7 +184,7 @@ func query(x []float32, k int, out []uint32) {
 		},
 		Select: []string{"xid"},
 	}
-	res, err := globalShard.SearchPoints(sr)
+	res, err := globalShard.SearchPoints(context.Background(), sr)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -10,4 +10,7 @@ type Collection struct {
 	// Active user plan, dynamically assigned
 	UserPlan    UserPlan
 	IndexSchema IndexSchema
+	// Sets of words that mean the same thing, text searches match any word
+	// in the set of a query word
+	Synonyms [][]string
 }
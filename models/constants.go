@@ -28,17 +28,34 @@ const (
 // ---------------------------
 
 const (
-	OperatorNear        = "near"
-	OperatorContainsAll = "containsAll"
-	OperatorContainsAny = "containsAny"
-	OperatorEquals      = "equals"
-	OperatorNotEquals   = "notEquals"
-	OperatorStartsWith  = "startsWith"
-	OperatorGreaterThan = "greaterThan"
-	OperatorGreaterOrEq = "greaterThanOrEquals"
-	OperatorLessThan    = "lessThan"
-	OperatorLessOrEq    = "lessThanOrEquals"
-	OperatorInRange     = "inRange"
+	OperatorNear         = "near"
+	OperatorContainsAll  = "containsAll"
+	OperatorContainsAny  = "containsAny"
+	OperatorContainsNone = "containsNone"
+	OperatorEquals       = "equals"
+	OperatorNotEquals    = "notEquals"
+	OperatorStartsWith   = "startsWith"
+	OperatorEndsWith     = "endsWith"
+	OperatorContains     = "contains"
+	OperatorRegex        = "regex"
+	OperatorGreaterThan  = "greaterThan"
+	OperatorGreaterOrEq  = "greaterThanOrEquals"
+	OperatorLessThan     = "lessThan"
+	OperatorLessOrEq     = "lessThanOrEquals"
+	OperatorInRange      = "inRange"
+	OperatorIn           = "in"
+	OperatorNotIn        = "notIn"
+	OperatorPhrase       = "phrase"
+	OperatorProximity    = "proximity"
+	OperatorMoreLikeThis = "moreLikeThis"
+	OperatorRecommend    = "recommend"
+
+	// Array length operators
+	OperatorSizeEquals      = "sizeEquals"
+	OperatorSizeGreaterThan = "sizeGreaterThan"
+	OperatorSizeGreaterOrEq = "sizeGreaterThanOrEquals"
+	OperatorSizeLessThan    = "sizeLessThan"
+	OperatorSizeLessOrEq    = "sizeLessThanOrEquals"
 )
 
 // ---------------------------
@@ -50,3 +67,55 @@ const (
 )
 
 // ---------------------------
+
+const (
+	TextAnalyserStandard = "standard"
+	TextAnalyserCustom   = "custom"
+)
+
+// ---------------------------
+
+const (
+	TextScoringTfIdf = "tfidf"
+	TextScoringBM25  = "bm25"
+)
+
+// ---------------------------
+
+const (
+	TextMatchExact  = "exact"
+	TextMatchPrefix = "prefix"
+	TextMatchFuzzy  = "fuzzy"
+)
+
+// ---------------------------
+
+const (
+	RecommendStrategyAverage   = "average"
+	RecommendStrategyBestScore = "bestScore"
+)
+
+// ---------------------------
+
+const (
+	ScoreModeSum      = "sum"
+	ScoreModeMultiply = "multiply"
+)
+
+// ---------------------------
+
+const (
+	ScoreDecayGauss       = "gauss"
+	ScoreDecayLinear      = "linear"
+	ScoreDecayExponential = "exponential"
+)
+
+// ---------------------------
+
+const (
+	ScoreModifierNone  = "none"
+	ScoreModifierLog1p = "log1p"
+	ScoreModifierSqrt  = "sqrt"
+)
+
+// ---------------------------
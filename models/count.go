@@ -0,0 +1,46 @@
+package models
+
+import "fmt"
+
+type CountRequest struct {
+	Query Query `json:"query" binding:"required"`
+	// Vector queries only find the nearest limit points, so they can only be
+	// counted approximately as the nearest points of each shard.
+	Approximate bool `json:"approximate"`
+}
+
+func (r CountRequest) Validate() error {
+	if err := r.Query.Validate(); err != nil {
+		return fmt.Errorf("query validation failed: %v", err)
+	}
+	if !r.Approximate && hasVectorQuery(r.Query) {
+		return fmt.Errorf("vector queries can only be counted with approximate set")
+	}
+	return nil
+}
+
+func (r CountRequest) ValidateSchema(schema IndexSchema) error {
+	return r.Query.ValidateSchema(schema)
+}
+
+// hasVectorQuery reports whether the query or any of its nested and filter
+// queries is a vector query.
+func hasVectorQuery(q Query) bool {
+	if q.VectorFlat != nil || q.VectorVamana != nil {
+		return true
+	}
+	if q.Text != nil && q.Text.Filter != nil && hasVectorQuery(*q.Text.Filter) {
+		return true
+	}
+	for _, sub := range q.And {
+		if hasVectorQuery(sub) {
+			return true
+		}
+	}
+	for _, sub := range q.Or {
+		if hasVectorQuery(sub) {
+			return true
+		}
+	}
+	return false
+}
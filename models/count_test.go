@@ -0,0 +1,68 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/semafind/semadb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCount_Validate(t *testing.T) {
+	integerQuery := models.Query{
+		Property: "propInteger",
+		Integer: &models.SearchIntegerOptions{
+			Value:    1,
+			Operator: models.OperatorEquals,
+		},
+	}
+	vectorQuery := models.Query{
+		Property: "propVectorFlat",
+		VectorFlat: &models.SearchVectorFlatOptions{
+			Vector:   []float32{1, 2},
+			Operator: models.OperatorNear,
+			Limit:    10,
+		},
+	}
+	tests := []struct {
+		name string
+		req  models.CountRequest
+		fail bool
+	}{
+		{
+			name: "Valid",
+			req:  models.CountRequest{Query: integerQuery},
+		},
+		{
+			name: "Invalid query",
+			req:  models.CountRequest{Query: models.Query{}},
+			fail: true,
+		},
+		{
+			name: "Exact vector",
+			req:  models.CountRequest{Query: vectorQuery},
+			fail: true,
+		},
+		{
+			name: "Exact nested vector",
+			req: models.CountRequest{Query: models.Query{
+				Property: "_or",
+				Or:       []models.Query{integerQuery, vectorQuery},
+			}},
+			fail: true,
+		},
+		{
+			name: "Approximate vector",
+			req:  models.CountRequest{Query: vectorQuery, Approximate: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
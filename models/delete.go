@@ -0,0 +1,23 @@
+package models
+
+import "fmt"
+
+type DeleteByQueryRequest struct {
+	// The points matching the query are deleted
+	Query Query `json:"query" binding:"required"`
+}
+
+func (r DeleteByQueryRequest) Validate() error {
+	if err := r.Query.Validate(); err != nil {
+		return fmt.Errorf("query validation failed: %v", err)
+	}
+	// Deleting the nearest points of a vector query is rarely intended
+	if hasVectorQuery(r.Query) {
+		return fmt.Errorf("vector queries are not supported for deleting points")
+	}
+	return nil
+}
+
+func (r DeleteByQueryRequest) ValidateSchema(schema IndexSchema) error {
+	return r.Query.ValidateSchema(schema)
+}
@@ -0,0 +1,31 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/semafind/semadb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteByQuery_Validate(t *testing.T) {
+	req := models.DeleteByQueryRequest{
+		Query: models.Query{
+			Property: "propInteger",
+			Integer: &models.SearchIntegerOptions{
+				Value:    1,
+				Operator: models.OperatorEquals,
+			},
+		},
+	}
+	require.NoError(t, req.Validate())
+	req.Query = models.Query{
+		Property: "propVectorFlat",
+		VectorFlat: &models.SearchVectorFlatOptions{
+			Vector:   []float32{1, 2},
+			Operator: models.OperatorNear,
+			Limit:    10,
+		},
+	}
+	require.Error(t, req.Validate())
+	require.Error(t, models.DeleteByQueryRequest{}.Validate())
+}
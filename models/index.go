@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -73,7 +74,7 @@ func (v IndexSchemaValue) Validate() error {
 }
 
 // Attempts to convert a given value to a vector
-func convertToVector(v any) ([]float32, error) {
+func ConvertToVector(v any) ([]float32, error) {
 	// This mess happens because we are dealing with arbitrary JSON.
 	// Nothing stops the user from passing "vector": "memes" as valid
 	// JSON. Furthermore, JSON by default decodes floats to float64.
@@ -106,6 +107,22 @@ func convertToVector(v any) ([]float32, error) {
 }
 
 // Check if a given map is compatible with the index schema
+// Returns the vector size and distance metric of a vector property, ok is
+// false if the property is not a vector index.
+func (s IndexSchema) VectorParameters(property string) (size uint, metric string, ok bool) {
+	schema, found := s[property]
+	if !found {
+		return 0, "", false
+	}
+	switch {
+	case schema.Type == IndexTypeVectorFlat && schema.VectorFlat != nil:
+		return schema.VectorFlat.VectorSize, schema.VectorFlat.DistanceMetric, true
+	case schema.Type == IndexTypeVectorVamana && schema.VectorVamana != nil:
+		return schema.VectorVamana.VectorSize, schema.VectorVamana.DistanceMetric, true
+	}
+	return 0, "", false
+}
+
 func (s IndexSchema) CheckCompatibleMap(pointMap PointAsMap) error {
 	// We will go through each index field, check if the map has them, is of
 	// right type and any extra checks needed
@@ -158,7 +175,7 @@ func (s IndexSchema) CheckCompatibleMap(pointMap PointAsMap) error {
 		// ---------------------------
 		switch schema.Type {
 		case IndexTypeVectorFlat:
-			vector, err := convertToVector(v)
+			vector, err := ConvertToVector(v)
 			if err != nil {
 				return fmt.Errorf("expected a vector for property %s: %w", k, err)
 			}
@@ -172,7 +189,7 @@ func (s IndexSchema) CheckCompatibleMap(pointMap PointAsMap) error {
 			// use the vector directly.
 			m[k] = vector
 		case IndexTypeVectorVamana:
-			vector, err := convertToVector(v)
+			vector, err := ConvertToVector(v)
 			if err != nil {
 				return fmt.Errorf("expected a vector for property %s: %w", k, err)
 			}
@@ -312,22 +329,125 @@ func (p IndexVectorVamanaParameters) Validate() error {
 
 }
 
+// The measured recall of a vamana index at a given search size.
+type VamanaCalibrationPoint struct {
+	SearchSize int     `json:"searchSize"`
+	Recall     float32 `json:"recall"`
+}
+
+// Calibration results of a vamana index, the curve is sorted by search size and
+// is used to pick a search size for queries with a target recall.
+type VamanaCalibration struct {
+	PointCount int                      `json:"pointCount"`
+	SampleSize int                      `json:"sampleSize"`
+	Limit      int                      `json:"limit"`
+	Curve      []VamanaCalibrationPoint `json:"curve"`
+}
+
+// Languages with a built-in analyser, stopword list and stemmer. The text
+// index maps these to the corresponding bleve components.
+var TextAnalyserLanguages = []string{"ar", "da", "de", "en", "es", "fi", "fr", "hi", "hu", "it", "nl", "no", "pt", "ro", "ru", "sv", "tr"}
+
 type IndexTextParameters struct {
-	Analyser string `json:"analyser" binding:"required,oneof=standard"`
+	// Either standard, a language code such as en or custom
+	Analyser string `json:"analyser" binding:"required,oneof=standard custom ar da de en es fi fr hi hu it nl no pt ro ru sv tr"`
+	// Required when the analyser is custom
+	Custom *TextAnalyserParameters `json:"custom,omitempty"`
+	// Ranking function, empty defaults to tf-idf for existing indices
+	Scoring string `json:"scoring" binding:"omitempty,oneof=tfidf bm25"`
+	// BM25 term frequency saturation, defaults to 1.2
+	K1 *float32 `json:"k1,omitempty" binding:"omitempty,min=0,max=3"`
+	// BM25 document length normalisation, defaults to 0.75
+	B *float32 `json:"b,omitempty" binding:"omitempty,min=0,max=1"`
 }
 
 func (p IndexTextParameters) Validate() error {
-	if p.Analyser != "standard" {
+	switch {
+	case p.Analyser == TextAnalyserStandard:
+	case p.Analyser == TextAnalyserCustom:
+		if p.Custom == nil {
+			return fmt.Errorf("custom analyser parameters are required")
+		}
+		if err := p.Custom.Validate(); err != nil {
+			return fmt.Errorf("invalid custom analyser: %w", err)
+		}
+	case slices.Contains(TextAnalyserLanguages, p.Analyser):
+	default:
 		return fmt.Errorf("unknown analyser %s", p.Analyser)
 	}
+	if p.Analyser != TextAnalyserCustom && p.Custom != nil {
+		return fmt.Errorf("custom analyser parameters given for %s analyser", p.Analyser)
+	}
+	if p.Scoring != "" && p.Scoring != TextScoringTfIdf && p.Scoring != TextScoringBM25 {
+		return fmt.Errorf("unknown scoring %s", p.Scoring)
+	}
+	if p.K1 != nil && (*p.K1 < 0 || *p.K1 > 3) {
+		return fmt.Errorf("k1 must be between 0 and 3, got %f", *p.K1)
+	}
+	if p.B != nil && (*p.B < 0 || *p.B > 1) {
+		return fmt.Errorf("b must be between 0 and 1, got %f", *p.B)
+	}
 	return nil
 }
 
+// A custom analyser pipeline. The text is split into tokens by the tokenizer
+// and the tokens then go through the enabled filters in the order the fields
+// are listed.
+type TextAnalyserParameters struct {
+	Tokenizer string `json:"tokenizer" binding:"required,oneof=unicode whitespace letter single"`
+	// Splits identifiers such as parseHttpRequest into parse, Http, Request
+	SplitCamelCase bool `json:"splitCamelCase"`
+	Lowercase      bool `json:"lowercase"`
+	// Removes the built-in stopwords of the language
+	StopwordsLanguage string `json:"stopwordsLanguage" binding:"omitempty,oneof=ar da de en es fi fr hi hu it nl no pt ro ru sv tr"`
+	// Additional stopwords to remove, matched after lowercasing if enabled
+	Stopwords       []string `json:"stopwords" binding:"max=1000"`
+	StemmerLanguage string   `json:"stemmerLanguage" binding:"omitempty,oneof=ar da de en es fi fr hi hu it nl no pt ro ru sv tr"`
+	// Breaks tokens into n-grams, useful for partial matching
+	NGram *TextNGramParameters `json:"ngram,omitempty"`
+}
+
+func (p TextAnalyserParameters) Validate() error {
+	switch p.Tokenizer {
+	case "unicode", "whitespace", "letter", "single":
+	default:
+		return fmt.Errorf("unknown tokenizer %s", p.Tokenizer)
+	}
+	if p.StopwordsLanguage != "" && !slices.Contains(TextAnalyserLanguages, p.StopwordsLanguage) {
+		return fmt.Errorf("unknown stopwords language %s", p.StopwordsLanguage)
+	}
+	if len(p.Stopwords) > 1000 {
+		return fmt.Errorf("too many stopwords, got %d, expected at most 1000", len(p.Stopwords))
+	}
+	if p.StemmerLanguage != "" && !slices.Contains(TextAnalyserLanguages, p.StemmerLanguage) {
+		return fmt.Errorf("unknown stemmer language %s", p.StemmerLanguage)
+	}
+	if p.NGram != nil {
+		if p.NGram.Min < 1 || p.NGram.Max > 20 || p.NGram.Min > p.NGram.Max {
+			return fmt.Errorf("ngram min and max must satisfy 1 <= min <= max <= 20, got %d and %d", p.NGram.Min, p.NGram.Max)
+		}
+	}
+	return nil
+}
+
+type TextNGramParameters struct {
+	Min int `json:"min" binding:"required,min=1,max=20"`
+	Max int `json:"max" binding:"required,min=1,max=20"`
+	// Only produce n-grams from the start of tokens, useful for autocomplete
+	Edge bool `json:"edge"`
+}
+
 type IndexStringParameters struct {
 	CaseSensitive bool `json:"caseSensitive"`
+	// Length of the n-grams indexed for contains, endsWith and regex queries,
+	// zero disables the n-gram index and these queries scan all values
+	NGramSize int `json:"ngramSize,omitempty" binding:"omitempty,min=2,max=4"`
 }
 
 func (p IndexStringParameters) Validate() error {
+	if p.NGramSize != 0 && (p.NGramSize < 2 || p.NGramSize > 4) {
+		return fmt.Errorf("ngramSize must be between 2 and 4, got %d", p.NGramSize)
+	}
 	return nil
 }
 
@@ -48,6 +48,51 @@ func TestIndexSchema_Validate_Haversine(t *testing.T) {
 	}
 }
 
+func TestIndexSchema_Validate_TextScoring(t *testing.T) {
+	k1 := float32(1.5)
+	b := float32(2)
+	params := models.IndexTextParameters{
+		Analyser: "standard",
+		Scoring:  models.TextScoringBM25,
+		K1:       &k1,
+	}
+	require.NoError(t, params.Validate())
+	params.B = &b
+	require.Error(t, params.Validate())
+	params.B = nil
+	params.Scoring = "pagerank"
+	require.Error(t, params.Validate())
+}
+
+func TestIndexSchema_Validate_StringNGram(t *testing.T) {
+	params := models.IndexStringArrayParameters{
+		IndexStringParameters: models.IndexStringParameters{NGramSize: 3},
+	}
+	require.NoError(t, params.Validate())
+	params.NGramSize = 1
+	require.Error(t, params.Validate())
+	params.NGramSize = 5
+	require.Error(t, params.Validate())
+}
+
+func TestIndexSchema_Validate_TextAnalyser(t *testing.T) {
+	params := models.IndexTextParameters{Analyser: "de"}
+	require.NoError(t, params.Validate())
+	params.Analyser = "klingon"
+	require.Error(t, params.Validate())
+	params.Analyser = models.TextAnalyserCustom
+	require.Error(t, params.Validate())
+	params.Custom = &models.TextAnalyserParameters{
+		Tokenizer:       "letter",
+		Lowercase:       true,
+		StemmerLanguage: "fr",
+		NGram:           &models.TextNGramParameters{Min: 3, Max: 2},
+	}
+	require.Error(t, params.Validate())
+	params.Custom.NGram.Max = 4
+	require.NoError(t, params.Validate())
+}
+
 // ---------------------------
 // Here is a kitchen sink schema
 var sampleSchema models.IndexSchema = models.IndexSchema{
@@ -0,0 +1,119 @@
+package models
+
+import "fmt"
+
+/* Score functions adjust the hybrid score of search results based on the
+ * values of numeric properties, e.g. preferring recent and popular items. Each
+ * function computes a value per point which is then added to or multiplied
+ * with the hybrid score depending on the mode. */
+
+type ScoreFunctions struct {
+	Functions []ScoreFunction `json:"functions" binding:"required,min=1,max=10,dive"`
+	// How function values are combined with the hybrid score, sum by default
+	Mode string `json:"mode" binding:"omitempty,oneof=sum multiply"`
+}
+
+func (s ScoreFunctions) Validate() error {
+	if len(s.Functions) < 1 || len(s.Functions) > 10 {
+		return fmt.Errorf("number of score functions must be between 1 and 10, got %d", len(s.Functions))
+	}
+	for _, f := range s.Functions {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Mode != "" && s.Mode != ScoreModeSum && s.Mode != ScoreModeMultiply {
+		return fmt.Errorf("invalid score mode %s, expected %s or %s", s.Mode, ScoreModeSum, ScoreModeMultiply)
+	}
+	return nil
+}
+
+func (s ScoreFunctions) ValidateSchema(schema IndexSchema) error {
+	for _, f := range s.Functions {
+		value, ok := schema[f.Property]
+		if !ok {
+			return fmt.Errorf("property %s not found in index schema, cannot score", f.Property)
+		}
+		if value.Type != IndexTypeInteger && value.Type != IndexTypeFloat {
+			return fmt.Errorf("property %s is not an integer or float index, got %s", f.Property, value.Type)
+		}
+	}
+	return nil
+}
+
+// ---------------------------
+
+// A ScoreFunction computes a value from a numeric property, exactly one of
+// decay or fieldValueFactor is given.
+type ScoreFunction struct {
+	Property         string                 `json:"property" binding:"required"`
+	Decay            *ScoreDecay            `json:"decay"`
+	FieldValueFactor *ScoreFieldValueFactor `json:"fieldValueFactor"`
+	Weight           *float32               `json:"weight"`
+}
+
+func (f ScoreFunction) Validate() error {
+	if len(f.Property) == 0 {
+		return fmt.Errorf("score function property cannot be empty")
+	}
+	if (f.Decay == nil) == (f.FieldValueFactor == nil) {
+		return fmt.Errorf("exactly one of decay or fieldValueFactor must be given for score function on %s", f.Property)
+	}
+	if f.Decay != nil {
+		if err := f.Decay.Validate(); err != nil {
+			return fmt.Errorf("invalid decay for %s: %w", f.Property, err)
+		}
+	}
+	if f.FieldValueFactor != nil {
+		if err := f.FieldValueFactor.Validate(); err != nil {
+			return fmt.Errorf("invalid fieldValueFactor for %s: %w", f.Property, err)
+		}
+	}
+	return nil
+}
+
+// ScoreDecay is 1 within offset of the origin and decays to the decay value
+// at scale further away. Dates are integer Unix timestamps in seconds, with
+// originNow using the time of the search as the origin.
+type ScoreDecay struct {
+	Function  string  `json:"function" binding:"required,oneof=gauss linear exponential"`
+	Origin    float64 `json:"origin"`
+	OriginNow bool    `json:"originNow"`
+	Scale     float64 `json:"scale" binding:"required,gt=0"`
+	Offset    float64 `json:"offset" binding:"min=0"`
+	// Defaults to 0.5
+	Decay float64 `json:"decay" binding:"omitempty,gt=0,lt=1"`
+}
+
+func (d ScoreDecay) Validate() error {
+	if d.Function != ScoreDecayGauss && d.Function != ScoreDecayLinear && d.Function != ScoreDecayExponential {
+		return fmt.Errorf("invalid decay function %s", d.Function)
+	}
+	if d.Scale <= 0 {
+		return fmt.Errorf("scale must be greater than 0, got %f", d.Scale)
+	}
+	if d.Offset < 0 {
+		return fmt.Errorf("offset must be greater than or equal to 0, got %f", d.Offset)
+	}
+	if d.Decay != 0 && (d.Decay <= 0 || d.Decay >= 1) {
+		return fmt.Errorf("decay must be between 0 and 1 exclusive, got %f", d.Decay)
+	}
+	return nil
+}
+
+// ScoreFieldValueFactor uses the property value multiplied by the factor
+// with an optional modifier applied, e.g. log1p of a popularity count.
+type ScoreFieldValueFactor struct {
+	// Defaults to 1
+	Factor   float64 `json:"factor"`
+	Modifier string  `json:"modifier" binding:"omitempty,oneof=none log1p sqrt"`
+	// Used for points without the property, otherwise the function is skipped
+	Missing *float64 `json:"missing"`
+}
+
+func (f ScoreFieldValueFactor) Validate() error {
+	if f.Modifier != "" && f.Modifier != ScoreModifierNone && f.Modifier != ScoreModifierLog1p && f.Modifier != ScoreModifierSqrt {
+		return fmt.Errorf("invalid modifier %s", f.Modifier)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/semafind/semadb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreFunctions_Validate(t *testing.T) {
+	gauss := &models.ScoreDecay{Function: models.ScoreDecayGauss, Scale: 10}
+	tests := []struct {
+		name string
+		sf   models.ScoreFunctions
+		fail bool
+	}{
+		{
+			name: "Valid decay",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{Property: "propInteger", Decay: gauss}},
+			},
+		},
+		{
+			name: "Valid field value factor",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{
+					Property:         "propFloat",
+					FieldValueFactor: &models.ScoreFieldValueFactor{Factor: 2, Modifier: models.ScoreModifierLog1p},
+				}},
+				Mode: models.ScoreModeMultiply,
+			},
+		},
+		{
+			name: "No functions",
+			sf:   models.ScoreFunctions{},
+			fail: true,
+		},
+		{
+			name: "Invalid mode",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{Property: "propInteger", Decay: gauss}},
+				Mode:      "max",
+			},
+			fail: true,
+		},
+		{
+			name: "Both decay and field value factor",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{
+					Property:         "propInteger",
+					Decay:            gauss,
+					FieldValueFactor: &models.ScoreFieldValueFactor{},
+				}},
+			},
+			fail: true,
+		},
+		{
+			name: "Neither decay nor field value factor",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{Property: "propInteger"}},
+			},
+			fail: true,
+		},
+		{
+			name: "Zero scale",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{
+					Property: "propInteger",
+					Decay:    &models.ScoreDecay{Function: models.ScoreDecayLinear},
+				}},
+			},
+			fail: true,
+		},
+		{
+			name: "Invalid decay value",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{
+					Property: "propInteger",
+					Decay:    &models.ScoreDecay{Function: models.ScoreDecayExponential, Scale: 1, Decay: 1},
+				}},
+			},
+			fail: true,
+		},
+		{
+			name: "Invalid modifier",
+			sf: models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{
+					Property:         "propInteger",
+					FieldValueFactor: &models.ScoreFieldValueFactor{Modifier: "square"},
+				}},
+			},
+			fail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sf.Validate()
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScoreFunctions_ValidateSchema(t *testing.T) {
+	fvf := &models.ScoreFieldValueFactor{}
+	tests := []struct {
+		name     string
+		property string
+		fail     bool
+	}{
+		{"Integer", "propInteger", false},
+		{"Float", "propFloat", false},
+		{"String", "propString", true},
+		{"Non-existent", "nonExistent", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf := models.ScoreFunctions{
+				Functions: []models.ScoreFunction{{Property: tt.property, FieldValueFactor: fvf}},
+			}
+			err := sf.ValidateSchema(sampleSchema)
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
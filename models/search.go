@@ -2,8 +2,11 @@ package models
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
 
 	"github.com/google/uuid"
+	"github.com/semafind/semadb/expression"
 )
 
 /* The search query design is based on the following key steps:
@@ -22,6 +25,14 @@ type SearchRequest struct {
 	Sort   []SortOption `json:"sort" binding:"max=10,dive"`
 	Offset int          `json:"offset" binding:"min=0"`
 	Limit  int          `json:"limit" binding:"required,min=1,max=100"`
+	// Adjusts the hybrid scores before results are ordered
+	ScoreFunctions *ScoreFunctions `json:"scoreFunctions"`
+	// Computes a custom score for each result that can be sorted by
+	ScoreExpression string `json:"scoreExpression" binding:"max=1024"`
+	// Attaches a breakdown of how each result was found and scored
+	Explain bool `json:"explain"`
+	// Records where the time of the search is spent
+	Profile bool `json:"profile"`
 }
 
 func (r SearchRequest) Validate() error {
@@ -37,6 +48,20 @@ func (r SearchRequest) Validate() error {
 		if err := sort.Validate(); err != nil {
 			return fmt.Errorf("sort validation failed: %v", err)
 		}
+		if sort.Property == "_customScore" && r.ScoreExpression == "" {
+			return fmt.Errorf("sorting by _customScore requires a score expression")
+		}
+	}
+	// ---------------------------
+	if r.ScoreFunctions != nil {
+		if err := r.ScoreFunctions.Validate(); err != nil {
+			return fmt.Errorf("score functions validation failed: %v", err)
+		}
+	}
+	if r.ScoreExpression != "" {
+		if _, err := expression.Parse(r.ScoreExpression); err != nil {
+			return fmt.Errorf("invalid score expression: %v", err)
+		}
 	}
 	// ---------------------------
 	if r.Offset < 0 {
@@ -49,6 +74,85 @@ func (r SearchRequest) Validate() error {
 	return nil
 }
 
+func (r SearchRequest) ValidateSchema(schema IndexSchema) error {
+	if err := r.Query.ValidateSchema(schema); err != nil {
+		return err
+	}
+	for _, sort := range r.Sort {
+		if err := sort.ValidateSchema(schema); err != nil {
+			return err
+		}
+	}
+	if r.ScoreFunctions != nil {
+		if err := r.ScoreFunctions.ValidateSchema(schema); err != nil {
+			return err
+		}
+	}
+	if r.ScoreExpression != "" {
+		expr, err := expression.Parse(r.ScoreExpression)
+		if err != nil {
+			return fmt.Errorf("invalid score expression: %v", err)
+		}
+		for _, v := range expr.Variables() {
+			if err := validateScoreVariable(schema, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the mmr options and weight of a top level vector query whose shard
+// results are diversified once more after merging. Explicit sorts and score
+// functions reorder the results, in which case it returns nil.
+func (r SearchRequest) MergeMMR() (*SearchMMROptions, float32) {
+	if len(r.Sort) > 0 || r.ScoreFunctions != nil {
+		return nil, 0
+	}
+	var mmr *SearchMMROptions
+	var weight *float32
+	switch {
+	case r.Query.VectorVamana != nil:
+		mmr, weight = r.Query.VectorVamana.MMR, r.Query.VectorVamana.Weight
+	case r.Query.VectorFlat != nil:
+		mmr, weight = r.Query.VectorFlat.MMR, r.Query.VectorFlat.Weight
+	}
+	if mmr == nil {
+		return nil, 0
+	}
+	if weight == nil {
+		return mmr, 1
+	}
+	return mmr, *weight
+}
+
+// SearchBatchRequest runs multiple searches together. Each search is validated
+// on its own so that an invalid search only fails itself.
+type SearchBatchRequest []SearchRequest
+
+func (r SearchBatchRequest) Validate() error {
+	if len(r) < 1 || len(r) > 50 {
+		return fmt.Errorf("batch must have between 1 and 50 searches, got %d", len(r))
+	}
+	return nil
+}
+
+// The search values and numeric properties that score expressions can use
+func validateScoreVariable(schema IndexSchema, name string) error {
+	switch name {
+	case "_distance", "_score", "_hybridScore":
+		return nil
+	}
+	value, ok := schema[name]
+	if !ok {
+		return fmt.Errorf("unknown variable %s in score expression, expected _distance, _score, _hybridScore or an integer or float property", name)
+	}
+	if value.Type != IndexTypeInteger && value.Type != IndexTypeFloat {
+		return fmt.Errorf("property %s in score expression is not an integer or float index, got %s", name, value.Type)
+	}
+	return nil
+}
+
 // ---------------------------
 
 type Query struct {
@@ -183,7 +287,7 @@ func (q Query) ValidateSchema(schema IndexSchema) error {
 		if q.VectorFlat == nil {
 			return fmt.Errorf("vectorFlat query options not provided for property %s", q.Property)
 		}
-		if len(q.VectorFlat.Vector) != int(value.VectorFlat.VectorSize) {
+		if q.VectorFlat.Operator != OperatorRecommend && len(q.VectorFlat.Vector) != int(value.VectorFlat.VectorSize) {
 			return fmt.Errorf("vectorFlat query vector length mismatch for property %s, expected %d got %d", q.Property, value.VectorFlat.VectorSize, len(q.VectorFlat.Vector))
 		}
 		if q.VectorFlat.Filter != nil {
@@ -195,7 +299,7 @@ func (q Query) ValidateSchema(schema IndexSchema) error {
 		if q.VectorVamana == nil {
 			return fmt.Errorf("vectorVamana query options not provided for property %s", q.Property)
 		}
-		if len(q.VectorVamana.Vector) != int(value.VectorVamana.VectorSize) {
+		if q.VectorVamana.Operator != OperatorRecommend && len(q.VectorVamana.Vector) != int(value.VectorVamana.VectorSize) {
 			return fmt.Errorf("vectorVamana query vector length mismatch for property %s, expected %d got %d", q.Property, value.VectorVamana.VectorSize, len(q.VectorVamana.Vector))
 		}
 		if q.VectorVamana.Filter != nil {
@@ -249,6 +353,74 @@ type SearchResult struct {
 	Score *float32 `json:"_score,omitempty" msgpack:"_score,omitempty"`
 	// Combined final score
 	HybridScore float32 `json:"_hybridScore" msgpack:"_hybridScore"`
+	// Matched text fragments by property for text queries with highlighting
+	Highlights map[string][]string `json:"_highlights,omitempty" msgpack:"_highlights,omitempty"`
+	// Computed distances for sort options with distanceTo, aligned with the
+	// sort options and nil where the point has no vector to compare
+	SortDistances []*float32 `json:"_sortDistances,omitempty" msgpack:"_sortDistances,omitempty"`
+	// Computed from the score expression, nil if a value it uses is missing
+	CustomScore *float32 `json:"_customScore,omitempty" msgpack:"_customScore,omitempty"`
+	// Vector of the queried property sent back by shards for the final mmr
+	// pass when merging, not exposed to the client
+	MMRVector []float32 `json:"-" msgpack:"_mmrVector,omitempty"`
+	// Only computed if the search request asks to explain
+	Explanation *SearchExplanation `json:"_explain,omitempty" msgpack:"_explain,omitempty"`
+}
+
+// SearchExplanation breaks down how a search result was found and scored.
+type SearchExplanation struct {
+	// The shard the result came from
+	Shard string `json:"shard"`
+	// The vector and text queries that found the result, the contributions add
+	// up to the hybrid score before any score functions are applied
+	Queries []QueryExplanation `json:"queries,omitempty"`
+}
+
+type QueryExplanation struct {
+	Property string   `json:"property"`
+	Distance *float32 `json:"distance,omitempty"`
+	Score    *float32 `json:"score,omitempty"`
+	Weight   float32  `json:"weight"`
+	// The part of the hybrid score that comes from this query
+	Contribution float32 `json:"contribution"`
+}
+
+// ---------------------------
+
+// SearchProfile records where the time of a search request is spent in each
+// shard. Durations are in milliseconds.
+type SearchProfile struct {
+	Shards []ShardProfile `json:"shards"`
+}
+
+type ShardProfile struct {
+	Shard string `json:"shard"`
+	// Round trip time of the request to the shard as seen by the cluster,
+	// including any network and queueing delays
+	LatencyMs float64 `json:"latencyMs"`
+	// Time spent in the shard from start to finish
+	TotalMs float64 `json:"totalMs"`
+	// Time spent reading the points of the search results
+	BackfillMs float64 `json:"backfillMs"`
+	// Index caches that were reused or had to be loaded from disk
+	CacheHits   int64         `json:"cacheHits"`
+	CacheMisses int64         `json:"cacheMisses"`
+	Query       *QueryProfile `json:"query"`
+}
+
+// QueryProfile mirrors the query tree with the work done for each query.
+type QueryProfile struct {
+	Property   string  `json:"property"`
+	DurationMs float64 `json:"durationMs"`
+	// Number of points that matched
+	Cardinality uint64 `json:"cardinality"`
+	// Number of ordered results, e.g. from vector or text queries
+	Results int `json:"results"`
+	// Number of graph nodes visited by vamana searches
+	NodesVisited int           `json:"nodesVisited,omitempty"`
+	Filter       *QueryProfile `json:"filter,omitempty"`
+	// Profiles of the queries of _and and _or
+	Queries []QueryProfile `json:"queries,omitempty"`
 }
 
 // ---------------------------
@@ -256,44 +428,74 @@ type SearchResult struct {
 type SortOption struct {
 	Property   string `json:"property" binding:"required"`
 	Descending bool   `json:"descending"`
+	// Sorts by the distance of the vector property to this vector instead of
+	// the property value, e.g. [lat, lon] for haversine properties
+	DistanceTo []float32 `json:"distanceTo,omitempty" binding:"max=4096"`
 }
 
 func (s SortOption) Validate() error {
 	if len(s.Property) == 0 {
 		return fmt.Errorf("sorting property cannot be empty")
 	}
+	if len(s.DistanceTo) > 4096 {
+		return fmt.Errorf("distanceTo vector length must be at most 4096, got %d", len(s.DistanceTo))
+	}
+	return nil
+}
+
+func (s SortOption) ValidateSchema(schema IndexSchema) error {
+	if s.DistanceTo == nil {
+		return nil
+	}
+	vectorSize, _, ok := schema.VectorParameters(s.Property)
+	if !ok {
+		return fmt.Errorf("property %s is not a vector index, cannot sort by distance", s.Property)
+	}
+	if len(s.DistanceTo) != int(vectorSize) {
+		return fmt.Errorf("distanceTo vector length mismatch for property %s, expected %d got %d", s.Property, vectorSize, len(s.DistanceTo))
+	}
 	return nil
 }
 
 type SearchVectorVamanaOptions struct {
-	Vector     []float32 `json:"vector" binding:"required,max=4096"`
-	Operator   string    `json:"operator" binding:"required,oneof=near"`
-	SearchSize int       `json:"searchSize" binding:"required,min=25,max=75"`
-	Limit      int       `json:"limit" binding:"required,min=1,max=75"`
-	Filter     *Query    `json:"filter"`
-	Weight     *float32  `json:"weight"`
+	Vector     []float32 `json:"vector" binding:"required_unless=Operator recommend,max=4096"`
+	Operator   string    `json:"operator" binding:"required,oneof=near recommend"`
+	SearchSize int       `json:"searchSize" binding:"min=25,max=75"`
+	// Instead of a search size, the index picks one based on its calibration
+	TargetRecall float32  `json:"targetRecall" binding:"min=0,max=1"`
+	Limit        int      `json:"limit" binding:"required,min=1,max=75"`
+	Filter       *Query   `json:"filter"`
+	Weight       *float32 `json:"weight"`
+	// Diversifies the searchSize candidates before taking the limit
+	MMR *SearchMMROptions `json:"mmr"`
+	// The examples for the recommend operator
+	Recommend *SearchRecommendOptions `json:"recommend"`
 }
 
 func (o SearchVectorVamanaOptions) Validate() error {
 	// ---------------------------
-	if len(o.Vector) < 1 || len(o.Vector) > 4096 {
-		return fmt.Errorf("query vector length must be between 1 and 4096, got %d", len(o.Vector))
-	}
-	// ---------------------------
-	if o.Operator != OperatorNear {
-		return fmt.Errorf("invalid operator %s for vector query, expected %s", o.Operator, OperatorNear)
-	}
-	// ---------------------------
-	if o.SearchSize < 25 || o.SearchSize > 75 {
-		return fmt.Errorf("invalid searchSize %d for vector query, expected 25-75", o.SearchSize)
+	if err := validateVectorOperator(o.Vector, o.Operator, o.Recommend); err != nil {
+		return err
 	}
 	// ---------------------------
 	if o.Limit < 1 || o.Limit > 75 {
 		return fmt.Errorf("invalid limit %d for vector query, expected 1-75", o.Limit)
 	}
 	// ---------------------------
-	if o.SearchSize < o.Limit {
-		return fmt.Errorf("searchSize must be greater than or equal to limit")
+	if o.TargetRecall != 0 {
+		if o.TargetRecall < 0 || o.TargetRecall > 1 {
+			return fmt.Errorf("invalid targetRecall %f for vector query, expected 0-1", o.TargetRecall)
+		}
+		if o.SearchSize != 0 {
+			return fmt.Errorf("only one of searchSize or targetRecall can be set")
+		}
+	} else {
+		if o.SearchSize < 25 || o.SearchSize > 75 {
+			return fmt.Errorf("invalid searchSize %d for vector query, expected 25-75", o.SearchSize)
+		}
+		if o.SearchSize < o.Limit {
+			return fmt.Errorf("searchSize must be greater than or equal to limit")
+		}
 	}
 	// ---------------------------
 	if o.Filter != nil {
@@ -302,25 +504,31 @@ func (o SearchVectorVamanaOptions) Validate() error {
 		}
 	}
 	// ---------------------------
+	if o.MMR != nil {
+		if err := o.MMR.Validate(); err != nil {
+			return fmt.Errorf("mmr validation failed: %v", err)
+		}
+	}
+	// ---------------------------
 	return nil
 }
 
 type SearchVectorFlatOptions struct {
-	Vector   []float32 `json:"vector" binding:"required,max=4096"`
-	Operator string    `json:"operator" binding:"required,oneof=near"`
+	Vector   []float32 `json:"vector" binding:"required_unless=Operator recommend,max=4096"`
+	Operator string    `json:"operator" binding:"required,oneof=near recommend"`
 	Limit    int       `json:"limit" binding:"required,min=1,max=75"`
 	Filter   *Query    `json:"filter"`
 	Weight   *float32  `json:"weight"`
+	// Diversifies the nearest MMRCandidates points before taking the limit
+	MMR *SearchMMROptions `json:"mmr"`
+	// The examples for the recommend operator
+	Recommend *SearchRecommendOptions `json:"recommend"`
 }
 
 func (o SearchVectorFlatOptions) Validate() error {
 	// ---------------------------
-	if len(o.Vector) < 1 || len(o.Vector) > 4096 {
-		return fmt.Errorf("query vector length must be between 1 and 4096, got %d", len(o.Vector))
-	}
-	// ---------------------------
-	if o.Operator != OperatorNear {
-		return fmt.Errorf("invalid operator %s for vector query, expected %s", o.Operator, OperatorNear)
+	if err := validateVectorOperator(o.Vector, o.Operator, o.Recommend); err != nil {
+		return err
 	}
 	// ---------------------------
 	if o.Limit < 1 || o.Limit > 75 {
@@ -333,15 +541,143 @@ func (o SearchVectorFlatOptions) Validate() error {
 		}
 	}
 	// ---------------------------
+	if o.MMR != nil {
+		if err := o.MMR.Validate(); err != nil {
+			return fmt.Errorf("mmr validation failed: %v", err)
+		}
+	}
+	// ---------------------------
+	return nil
+}
+
+// Vector queries search near the query vector or the recommendation examples
+func validateVectorOperator(vector []float32, operator string, recommend *SearchRecommendOptions) error {
+	switch operator {
+	case OperatorNear:
+		if len(vector) < 1 || len(vector) > 4096 {
+			return fmt.Errorf("query vector length must be between 1 and 4096, got %d", len(vector))
+		}
+		if recommend != nil {
+			return fmt.Errorf("recommend options are only supported with the %s operator", OperatorRecommend)
+		}
+	case OperatorRecommend:
+		if len(vector) != 0 {
+			return fmt.Errorf("query vector is not supported with the %s operator, the examples are used instead", OperatorRecommend)
+		}
+		if recommend == nil {
+			return fmt.Errorf("recommend options are required for the %s operator", OperatorRecommend)
+		}
+		if err := recommend.Validate(); err != nil {
+			return fmt.Errorf("recommend validation failed: %v", err)
+		}
+	default:
+		return fmt.Errorf("invalid operator %s for vector query, expected %s or %s", operator, OperatorNear, OperatorRecommend)
+	}
+	return nil
+}
+
+// SearchRecommendOptions finds points like the positive examples and unlike
+// the negative ones, the examples themselves are left out of the results.
+type SearchRecommendOptions struct {
+	Positive []string `json:"positive" binding:"required,min=1,max=16"`
+	Negative []string `json:"negative" binding:"max=16"`
+	// How the examples are combined, defaults to average
+	Strategy string `json:"strategy" binding:"omitempty,oneof=average bestScore"`
+	// The stored vectors of the examples. These are looked up internally before
+	// the query reaches the shards and are not part of the API.
+	PositiveVectors [][]float32 `json:"-"`
+	NegativeVectors [][]float32 `json:"-"`
+}
+
+func (o SearchRecommendOptions) Validate() error {
+	if len(o.Positive) < 1 || len(o.Positive) > 16 {
+		return fmt.Errorf("invalid number of positive examples %d, expected 1-16", len(o.Positive))
+	}
+	if len(o.Negative) > 16 {
+		return fmt.Errorf("invalid number of negative examples %d, expected at most 16", len(o.Negative))
+	}
+	for _, id := range slices.Concat(o.Positive, o.Negative) {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("invalid example point id %s: %v", id, err)
+		}
+	}
+	if o.Strategy != "" && o.Strategy != RecommendStrategyAverage && o.Strategy != RecommendStrategyBestScore {
+		return fmt.Errorf("invalid strategy %s, expected %s or %s", o.Strategy, RecommendStrategyAverage, RecommendStrategyBestScore)
+	}
+	return nil
+}
+
+// The number of nearest points flat indices diversify with maximal marginal
+// relevance, the largest search size of vamana indices
+const MMRCandidates = 75
+
+// SearchMMROptions reranks vector search results with maximal marginal
+// relevance. Each step picks the candidate with the best trade off between
+// being near the query and far from the results picked so far, lambda 1 is
+// plain nearest neighbour search and lower values favour diversity.
+type SearchMMROptions struct {
+	Lambda float32 `json:"lambda" binding:"min=0,max=1"`
+}
+
+func (o SearchMMROptions) Validate() error {
+	if o.Lambda < 0 || o.Lambda > 1 {
+		return fmt.Errorf("invalid lambda %f for mmr, expected 0-1", o.Lambda)
+	}
 	return nil
 }
 
 type SearchTextOptions struct {
-	Value    string   `json:"value" binding:"required"`
-	Operator string   `json:"operator" binding:"required,oneof=containsAll containsAny"`
-	Limit    int      `json:"limit" binding:"required,min=1,max=75"`
-	Filter   *Query   `json:"filter"`
-	Weight   *float32 `json:"weight"`
+	// The query text, or the point id for the moreLikeThis operator
+	Value    string `json:"value" binding:"required"`
+	Operator string `json:"operator" binding:"required,oneof=containsAll containsAny phrase proximity moreLikeThis"`
+	// Maximum number of positions between the first and last term for the
+	// proximity operator
+	Distance int `json:"distance" binding:"omitempty,min=1,max=100"`
+	// Number of terms taken from the point for the moreLikeThis operator
+	MaxTerms int `json:"maxTerms" binding:"omitempty,min=1,max=100"`
+	// How query terms match index terms, empty means exact
+	Match string `json:"match" binding:"omitempty,oneof=exact prefix fuzzy"`
+	// Maximum edit distance for fuzzy matching, the first character of the
+	// query term has to match exactly and does not count towards the edits
+	Fuzziness int      `json:"fuzziness" binding:"omitempty,min=1,max=2"`
+	Limit     int      `json:"limit" binding:"required,min=1,max=75"`
+	Filter    *Query   `json:"filter"`
+	Weight    *float32 `json:"weight"`
+	// Return the matched fragments of the text with each result
+	Highlight *SearchTextHighlight `json:"highlight"`
+	// The terms of the source point for the moreLikeThis operator. These are
+	// looked up internally before the query reaches the shards and are not
+	// part of the API.
+	Terms []WeightedTerm `json:"-"`
+}
+
+// WeightedTerm is an index term with its relative importance
+type WeightedTerm struct {
+	Term   string
+	Weight float32
+}
+
+// Highlighting options for text queries, the matched terms in each fragment
+// are wrapped in the pre and post tags.
+type SearchTextHighlight struct {
+	PreTag  string `json:"preTag" binding:"max=32"`
+	PostTag string `json:"postTag" binding:"max=32"`
+	// Approximate number of characters in each fragment
+	FragmentSize int `json:"fragmentSize" binding:"omitempty,min=10,max=1000"`
+	MaxFragments int `json:"maxFragments" binding:"omitempty,min=1,max=10"`
+}
+
+func (o SearchTextHighlight) Validate() error {
+	if len(o.PreTag) > 32 || len(o.PostTag) > 32 {
+		return fmt.Errorf("highlight tags must be at most 32 characters")
+	}
+	if o.FragmentSize != 0 && (o.FragmentSize < 10 || o.FragmentSize > 1000) {
+		return fmt.Errorf("invalid highlight fragment size %d, expected 10-1000", o.FragmentSize)
+	}
+	if o.MaxFragments != 0 && (o.MaxFragments < 1 || o.MaxFragments > 10) {
+		return fmt.Errorf("invalid highlight max fragments %d, expected 1-10", o.MaxFragments)
+	}
+	return nil
 }
 
 func (o SearchTextOptions) Validate() error {
@@ -353,14 +689,48 @@ func (o SearchTextOptions) Validate() error {
 	switch o.Operator {
 	case OperatorContainsAll:
 	case OperatorContainsAny:
+	case OperatorPhrase:
+	case OperatorProximity:
+		if o.Distance < 1 || o.Distance > 100 {
+			return fmt.Errorf("invalid distance %d for proximity text query, expected 1-100", o.Distance)
+		}
+	case OperatorMoreLikeThis:
+		if _, err := uuid.Parse(o.Value); err != nil {
+			return fmt.Errorf("invalid point id %s for moreLikeThis text query: %v", o.Value, err)
+		}
+		if o.MaxTerms != 0 && (o.MaxTerms < 1 || o.MaxTerms > 100) {
+			return fmt.Errorf("invalid maxTerms %d for moreLikeThis text query, expected 1-100", o.MaxTerms)
+		}
+		if o.Highlight != nil {
+			return fmt.Errorf("highlight is not supported for moreLikeThis text query")
+		}
 	default:
-		return fmt.Errorf("invalid operator %s for text query, expected %s or %s", o.Operator, OperatorContainsAll, OperatorContainsAny)
+		return fmt.Errorf("invalid operator %s for text query, expected %s, %s, %s, %s or %s", o.Operator, OperatorContainsAll, OperatorContainsAny, OperatorPhrase, OperatorProximity, OperatorMoreLikeThis)
+	}
+	// ---------------------------
+	switch o.Match {
+	case "", TextMatchExact, TextMatchPrefix:
+	case TextMatchFuzzy:
+		if o.Fuzziness < 1 || o.Fuzziness > 2 {
+			return fmt.Errorf("invalid fuzziness %d for fuzzy text query, expected 1-2", o.Fuzziness)
+		}
+	default:
+		return fmt.Errorf("invalid match %s for text query, expected %s, %s or %s", o.Match, TextMatchExact, TextMatchPrefix, TextMatchFuzzy)
+	}
+	if o.Match != "" && o.Match != TextMatchExact && (o.Operator == OperatorPhrase || o.Operator == OperatorProximity || o.Operator == OperatorMoreLikeThis) {
+		return fmt.Errorf("%s match is not supported for %s text query", o.Match, o.Operator)
 	}
 	// ---------------------------
 	if o.Limit < 1 || o.Limit > 75 {
 		return fmt.Errorf("invalid limit %d for text query, expected 1-75", o.Limit)
 	}
 	// ---------------------------
+	if o.Highlight != nil {
+		if err := o.Highlight.Validate(); err != nil {
+			return err
+		}
+	}
+	// ---------------------------
 	if o.Filter != nil {
 		if err := o.Filter.Validate(); err != nil {
 			return fmt.Errorf("filter validation failed: %v", err)
@@ -371,18 +741,54 @@ func (o SearchTextOptions) Validate() error {
 }
 
 type SearchStringOptions struct {
-	Value    string `json:"value" binding:"required"`
-	Operator string `json:"operator" binding:"required,oneof=equals notEquals startsWith greaterThan greaterThanOrEquals lessThan lessThanOrEquals inRange"`
+	Value    string `json:"value" binding:"required_unless=Operator in Operator notIn"`
+	Operator string `json:"operator" binding:"required,oneof=equals notEquals startsWith endsWith contains regex greaterThan greaterThanOrEquals lessThan lessThanOrEquals inRange in notIn"`
 	// Used for range queries
 	EndValue string `json:"endValue"`
+	// Used for set membership queries
+	Values []string `json:"values" binding:"omitempty,max=1000"`
+}
+
+// The maximum number of values in an in or notIn query
+const maxSetValues = 1000
+
+func validateSetValues(count int, kind string) error {
+	if count == 0 || count > maxSetValues {
+		return fmt.Errorf("%s set query must have 1-%d values, got %d", kind, maxSetValues, count)
+	}
+	return nil
+}
+
+// The maximum length of a regular expression in a string query
+const maxRegexLength = 256
+
+// Checks the value of a regex query compiles, other operators take any value
+func validateStringPattern(value, operator string) error {
+	if operator != OperatorRegex {
+		return nil
+	}
+	if len(value) > maxRegexLength {
+		return fmt.Errorf("regex cannot be longer than %d characters", maxRegexLength)
+	}
+	if _, err := regexp.Compile(value); err != nil {
+		return fmt.Errorf("invalid regex %s: %v", value, err)
+	}
+	return nil
 }
 
 func (o SearchStringOptions) Validate() error {
+	if o.Operator == OperatorIn || o.Operator == OperatorNotIn {
+		return validateSetValues(len(o.Values), "string")
+	}
 	if len(o.Value) == 0 {
 		return fmt.Errorf("string query value cannot be empty")
 	}
 	switch o.Operator {
 	case OperatorEquals, OperatorNotEquals, OperatorStartsWith:
+	case OperatorEndsWith, OperatorContains, OperatorRegex:
+		if err := validateStringPattern(o.Value, o.Operator); err != nil {
+			return err
+		}
 	case OperatorGreaterThan, OperatorGreaterOrEq:
 	case OperatorLessThan, OperatorLessOrEq:
 	case OperatorInRange:
@@ -396,13 +802,16 @@ func (o SearchStringOptions) Validate() error {
 }
 
 type SearchIntegerOptions struct {
-	Value    int64  `json:"value" binding:"required"`
-	Operator string `json:"operator" binding:"required,oneof=equals notEquals greaterThan greaterThanOrEquals lessThan lessThanOrEquals inRange"`
-	EndValue int64  `json:"endValue"`
+	Value    int64   `json:"value" binding:"required_unless=Operator in Operator notIn"`
+	Operator string  `json:"operator" binding:"required,oneof=equals notEquals greaterThan greaterThanOrEquals lessThan lessThanOrEquals inRange in notIn"`
+	EndValue int64   `json:"endValue"`
+	Values   []int64 `json:"values" binding:"omitempty,max=1000"`
 }
 
 func (o SearchIntegerOptions) Validate() error {
 	switch o.Operator {
+	case OperatorIn, OperatorNotIn:
+		return validateSetValues(len(o.Values), "integer")
 	case OperatorEquals, OperatorNotEquals:
 	case OperatorGreaterThan, OperatorGreaterOrEq:
 	case OperatorLessThan, OperatorLessOrEq:
@@ -417,13 +826,16 @@ func (o SearchIntegerOptions) Validate() error {
 }
 
 type SearchFloatOptions struct {
-	Value    float64 `json:"value" binding:"required"`
-	Operator string  `json:"operator" binding:"required,oneof=equals notEquals greaterThan greaterThanOrEquals lessThan lessThanOrEquals inRange"`
-	EndValue float64 `json:"endValue"`
+	Value    float64   `json:"value" binding:"required_unless=Operator in Operator notIn"`
+	Operator string    `json:"operator" binding:"required,oneof=equals notEquals greaterThan greaterThanOrEquals lessThan lessThanOrEquals inRange in notIn"`
+	EndValue float64   `json:"endValue"`
+	Values   []float64 `json:"values" binding:"omitempty,max=1000"`
 }
 
 func (o SearchFloatOptions) Validate() error {
 	switch o.Operator {
+	case OperatorIn, OperatorNotIn:
+		return validateSetValues(len(o.Values), "float")
 	case OperatorEquals, OperatorNotEquals:
 	case OperatorGreaterThan, OperatorGreaterOrEq:
 	case OperatorLessThan, OperatorLessOrEq:
@@ -438,19 +850,40 @@ func (o SearchFloatOptions) Validate() error {
 }
 
 type SearchStringArrayOptions struct {
-	Value    []string `json:"value" binding:"required"`
-	Operator string   `json:"operator" binding:"required,oneof=containsAll containsAny"`
+	Value []string `json:"value" binding:"required_without=Size"`
+	// The contains, endsWith and regex operators match arrays with at least
+	// one item matching any of the values
+	Operator string `json:"operator" binding:"required,oneof=containsAll containsAny containsNone contains endsWith regex sizeEquals sizeGreaterThan sizeGreaterThanOrEquals sizeLessThan sizeLessThanOrEquals"`
+	// The number of items for the size operators
+	Size int64 `json:"size" binding:"min=0"`
 }
 
 func (o SearchStringArrayOptions) Validate() error {
+	switch o.Operator {
+	case OperatorSizeEquals, OperatorSizeGreaterThan, OperatorSizeGreaterOrEq, OperatorSizeLessThan, OperatorSizeLessOrEq:
+		if o.Size < 0 {
+			return fmt.Errorf("stringArray size cannot be negative, got %d", o.Size)
+		}
+		return nil
+	}
 	if len(o.Value) == 0 {
 		return fmt.Errorf("stringArray query value cannot be empty")
 	}
 	switch o.Operator {
 	case OperatorContainsAll:
 	case OperatorContainsAny:
+	case OperatorContainsNone:
+	case OperatorContains, OperatorEndsWith, OperatorRegex:
+		for _, v := range o.Value {
+			if len(v) == 0 {
+				return fmt.Errorf("stringArray %s query values cannot be empty", o.Operator)
+			}
+			if err := validateStringPattern(v, o.Operator); err != nil {
+				return err
+			}
+		}
 	default:
-		return fmt.Errorf("invalid operator %s for stringArray query, expected %s or %s", o.Operator, OperatorContainsAll, OperatorContainsAny)
+		return fmt.Errorf("invalid operator %s for stringArray query", o.Operator)
 	}
 	return nil
 }
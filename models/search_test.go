@@ -70,6 +70,127 @@ func TestSearch_QueryValidate(t *testing.T) {
 			},
 			fail: false,
 		},
+		{
+			name: "Valid vector vamana target recall",
+			query: models.Query{
+				Property: "propVectorVamana",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Vector:       []float32{1.0, 2.0},
+					Operator:     models.OperatorNear,
+					TargetRecall: 0.9,
+					Limit:        10,
+				},
+			},
+			fail: false,
+		},
+		{
+			name: "Invalid vector vamana target recall and search size",
+			query: models.Query{
+				Property: "propVectorVamana",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Vector:       []float32{1.0, 2.0},
+					Operator:     models.OperatorNear,
+					SearchSize:   25,
+					TargetRecall: 0.9,
+					Limit:        10,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Invalid vector vamana target recall",
+			query: models.Query{
+				Property: "propVectorVamana",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Vector:       []float32{1.0, 2.0},
+					Operator:     models.OperatorNear,
+					TargetRecall: 1.5,
+					Limit:        10,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid vector vamana mmr",
+			query: models.Query{
+				Property: "propVectorVamana",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Vector:     []float32{1.0, 2.0},
+					Operator:   models.OperatorNear,
+					SearchSize: 25,
+					Limit:      10,
+					MMR:        &models.SearchMMROptions{Lambda: 0.5},
+				},
+			},
+			fail: false,
+		},
+		{
+			name: "Invalid vector flat mmr lambda",
+			query: models.Query{
+				Property: "propVectorFlat",
+				VectorFlat: &models.SearchVectorFlatOptions{
+					Vector:   []float32{1.0, 2.0},
+					Operator: models.OperatorNear,
+					Limit:    10,
+					MMR:      &models.SearchMMROptions{Lambda: 1.5},
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid vector vamana recommend",
+			query: models.Query{
+				Property: "propVectorVamana",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Operator:   models.OperatorRecommend,
+					SearchSize: 25,
+					Limit:      10,
+					Recommend: &models.SearchRecommendOptions{
+						Positive: []string{"7f2c5a3e-5b1d-4c8e-9f0a-1b2c3d4e5f60"},
+						Negative: []string{"0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"},
+						Strategy: models.RecommendStrategyBestScore,
+					},
+				},
+			},
+			fail: false,
+		},
+		{
+			name: "Invalid vector flat recommend with vector",
+			query: models.Query{
+				Property: "propVectorFlat",
+				VectorFlat: &models.SearchVectorFlatOptions{
+					Vector:    []float32{1.0, 2.0},
+					Operator:  models.OperatorRecommend,
+					Limit:     10,
+					Recommend: &models.SearchRecommendOptions{Positive: []string{"7f2c5a3e-5b1d-4c8e-9f0a-1b2c3d4e5f60"}},
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Invalid vector flat recommend without positive",
+			query: models.Query{
+				Property: "propVectorFlat",
+				VectorFlat: &models.SearchVectorFlatOptions{
+					Operator:  models.OperatorRecommend,
+					Limit:     10,
+					Recommend: &models.SearchRecommendOptions{Negative: []string{"7f2c5a3e-5b1d-4c8e-9f0a-1b2c3d4e5f60"}},
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Invalid vector flat recommend id",
+			query: models.Query{
+				Property: "propVectorFlat",
+				VectorFlat: &models.SearchVectorFlatOptions{
+					Operator:  models.OperatorRecommend,
+					Limit:     10,
+					Recommend: &models.SearchRecommendOptions{Positive: []string{"gandalf"}},
+				},
+			},
+			fail: true,
+		},
 		{
 			name: "Valid Vector Vamana filter",
 			query: models.Query{
@@ -122,6 +243,215 @@ func TestSearch_QueryValidate(t *testing.T) {
 			},
 			fail: false,
 		},
+		{
+			name: "Proximity text query without distance",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:    "text",
+					Operator: models.OperatorProximity,
+					Limit:    10,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid proximity text query",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:    "text",
+					Operator: models.OperatorProximity,
+					Distance: 3,
+					Limit:    10,
+				},
+			},
+			fail: false,
+		},
+		{
+			name: "Fuzzy phrase text query",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:     "text",
+					Operator:  models.OperatorPhrase,
+					Match:     models.TextMatchFuzzy,
+					Fuzziness: 1,
+					Limit:     10,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid fuzzy text query",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:     "text",
+					Operator:  models.OperatorContainsAll,
+					Match:     models.TextMatchFuzzy,
+					Fuzziness: 2,
+					Limit:     10,
+				},
+			},
+			fail: false,
+		},
+		{
+			name: "Valid moreLikeThis text query",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:    "0c8f6d4c-3b4e-4a43-9d3a-8f2d8b1f5c11",
+					Operator: models.OperatorMoreLikeThis,
+					MaxTerms: 10,
+					Limit:    10,
+				},
+			},
+			fail: false,
+		},
+		{
+			name: "Invalid moreLikeThis point id",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:    "text",
+					Operator: models.OperatorMoreLikeThis,
+					Limit:    10,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Invalid moreLikeThis max terms",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:    "0c8f6d4c-3b4e-4a43-9d3a-8f2d8b1f5c11",
+					Operator: models.OperatorMoreLikeThis,
+					MaxTerms: 101,
+					Limit:    10,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Highlight moreLikeThis text query",
+			query: models.Query{
+				Property: "propText",
+				Text: &models.SearchTextOptions{
+					Value:     "0c8f6d4c-3b4e-4a43-9d3a-8f2d8b1f5c11",
+					Operator:  models.OperatorMoreLikeThis,
+					Limit:     10,
+					Highlight: &models.SearchTextHighlight{},
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid regex string query",
+			query: models.Query{
+				Property: "propString",
+				String: &models.SearchStringOptions{
+					Value:    "^sku-[0-9]+$",
+					Operator: models.OperatorRegex,
+				},
+			},
+		},
+		{
+			name: "Invalid regex string query",
+			query: models.Query{
+				Property: "propString",
+				String: &models.SearchStringOptions{
+					Value:    "sku-[0-9",
+					Operator: models.OperatorRegex,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid contains stringArray query",
+			query: models.Query{
+				Property: "propStringArray",
+				StringArray: &models.SearchStringArrayOptions{
+					Value:    []string{"@example.com", "@example.org"},
+					Operator: models.OperatorContains,
+				},
+			},
+		},
+		{
+			name: "Empty endsWith stringArray value",
+			query: models.Query{
+				Property: "propStringArray",
+				StringArray: &models.SearchStringArrayOptions{
+					Value:    []string{".com", ""},
+					Operator: models.OperatorEndsWith,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid in integer query",
+			query: models.Query{
+				Property: "propInteger",
+				Integer: &models.SearchIntegerOptions{
+					Values:   []int64{0, 1, 2},
+					Operator: models.OperatorIn,
+				},
+			},
+		},
+		{
+			name: "Empty notIn string query",
+			query: models.Query{
+				Property: "propString",
+				String: &models.SearchStringOptions{
+					Value:    "ignored",
+					Operator: models.OperatorNotIn,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Too many in float values",
+			query: models.Query{
+				Property: "propFloat",
+				Float: &models.SearchFloatOptions{
+					Values:   make([]float64, 1001),
+					Operator: models.OperatorIn,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Valid size stringArray query",
+			query: models.Query{
+				Property: "propStringArray",
+				StringArray: &models.SearchStringArrayOptions{
+					Size:     0,
+					Operator: models.OperatorSizeEquals,
+				},
+			},
+		},
+		{
+			name: "Negative size stringArray query",
+			query: models.Query{
+				Property: "propStringArray",
+				StringArray: &models.SearchStringArrayOptions{
+					Size:     -1,
+					Operator: models.OperatorSizeGreaterThan,
+				},
+			},
+			fail: true,
+		},
+		{
+			name: "Empty containsNone stringArray query",
+			query: models.Query{
+				Property: "propStringArray",
+				StringArray: &models.SearchStringArrayOptions{
+					Operator: models.OperatorContainsNone,
+				},
+			},
+			fail: true,
+		},
 		{
 			name: "Valid composite query",
 			query: models.Query{
@@ -280,3 +610,105 @@ func TestSearch_QuerySchemaValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestSortOption_ValidateSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		sort models.SortOption
+		fail bool
+	}{
+		{"Property sort", models.SortOption{Property: "anything"}, false},
+		{"Flat distance", models.SortOption{Property: "propVectorFlat", DistanceTo: []float32{1, 2}}, false},
+		{"Vamana distance", models.SortOption{Property: "propVectorVamana", DistanceTo: []float32{1, 2}, Descending: true}, false},
+		{"Vector size mismatch", models.SortOption{Property: "propVectorFlat", DistanceTo: []float32{1}}, true},
+		{"Not a vector", models.SortOption{Property: "propInteger", DistanceTo: []float32{1, 2}}, true},
+		{"Non-existent property", models.SortOption{Property: "nonExistent", DistanceTo: []float32{1, 2}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sort.ValidateSchema(sampleSchema)
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSearchRequest_ScoreExpression(t *testing.T) {
+	query := models.Query{
+		Property: "propInteger",
+		Integer: &models.SearchIntegerOptions{
+			Operator: models.OperatorEquals,
+			Value:    1,
+		},
+	}
+	tests := []struct {
+		name       string
+		expression string
+		sort       []models.SortOption
+		fail       bool
+	}{
+		{"No expression", "", nil, false},
+		{"Valid", "0.7*_hybridScore + 0.3*log(1+propInteger) - 0.01*propFloat", nil, false},
+		{"Sort by custom score", "_score * 2", []models.SortOption{{Property: "_customScore"}}, false},
+		{"Sort without expression", "", []models.SortOption{{Property: "_customScore"}}, true},
+		{"Syntax error", "1 +", nil, true},
+		{"Unknown variable", "nonExistent * 2", nil, true},
+		{"Non numeric property", "propString * 2", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.SearchRequest{
+				Query:           query,
+				Sort:            tt.sort,
+				Limit:           10,
+				ScoreExpression: tt.expression,
+			}
+			err := req.Validate()
+			if err == nil {
+				err = req.ValidateSchema(sampleSchema)
+			}
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSearchRequest_MergeMMR(t *testing.T) {
+	weight := float32(0.5)
+	req := models.SearchRequest{
+		Query: models.Query{
+			Property: "propVectorFlat",
+			VectorFlat: &models.SearchVectorFlatOptions{
+				Vector: []float32{1.0, 2.0},
+				Limit:  10,
+				MMR:    &models.SearchMMROptions{Lambda: 0.5},
+				Weight: &weight,
+			},
+		},
+		Limit: 10,
+	}
+	mmr, w := req.MergeMMR()
+	require.NotNil(t, mmr)
+	require.Equal(t, weight, w)
+	// ---------------------------
+	req.Sort = []models.SortOption{{Property: "propInteger"}}
+	mmr, _ = req.MergeMMR()
+	require.Nil(t, mmr)
+	// ---------------------------
+	req.Sort = nil
+	req.Query.VectorFlat.MMR = nil
+	mmr, _ = req.MergeMMR()
+	require.Nil(t, mmr)
+}
+
+func TestSearchBatchRequest_Validate(t *testing.T) {
+	require.Error(t, models.SearchBatchRequest{}.Validate())
+	require.NoError(t, make(models.SearchBatchRequest, 50).Validate())
+	require.Error(t, make(models.SearchBatchRequest, 51).Validate())
+}
@@ -0,0 +1,53 @@
+package models
+
+import "fmt"
+
+type SuggestRequest struct {
+	// The text property to suggest terms from
+	Property string `json:"property" binding:"required"`
+	// What the user has typed so far, the last word is completed
+	Prefix string `json:"prefix" binding:"required,max=256"`
+	// Only count points that match the filter
+	Filter *Query `json:"filter"`
+	Limit  int    `json:"limit" binding:"min=0,max=100"`
+}
+
+func (r SuggestRequest) Validate() error {
+	if len(r.Property) == 0 {
+		return fmt.Errorf("property cannot be empty")
+	}
+	if len(r.Prefix) < 1 || len(r.Prefix) > 256 {
+		return fmt.Errorf("prefix length must be between 1 and 256, got %d", len(r.Prefix))
+	}
+	if r.Filter != nil {
+		if err := r.Filter.Validate(); err != nil {
+			return fmt.Errorf("filter validation failed: %v", err)
+		}
+	}
+	if r.Limit < 0 || r.Limit > 100 {
+		return fmt.Errorf("limit must be between 0 and 100, got %d", r.Limit)
+	}
+	return nil
+}
+
+func (r SuggestRequest) ValidateSchema(schema IndexSchema) error {
+	value, ok := schema[r.Property]
+	if !ok {
+		return fmt.Errorf("property %s not found in index schema, cannot suggest", r.Property)
+	}
+	if value.Type != IndexTypeText {
+		return fmt.Errorf("property %s is not a text index, got %s", r.Property, value.Type)
+	}
+	if r.Filter != nil {
+		if err := r.Filter.ValidateSchema(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Suggestion is a term from a text index and the number of points it occurs in
+type Suggestion struct {
+	Term          string `json:"term"`
+	DocumentCount uint64 `json:"documentCount"`
+}
@@ -0,0 +1,92 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/semafind/semadb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggest_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.SuggestRequest
+		fail bool
+	}{
+		{
+			name: "Valid",
+			req:  models.SuggestRequest{Property: "propText", Prefix: "gan", Limit: 10},
+		},
+		{
+			name: "Empty prefix",
+			req:  models.SuggestRequest{Property: "propText", Limit: 10},
+			fail: true,
+		},
+		{
+			name: "Large limit",
+			req:  models.SuggestRequest{Property: "propText", Prefix: "gan", Limit: 101},
+			fail: true,
+		},
+		{
+			name: "Invalid filter",
+			req: models.SuggestRequest{
+				Property: "propText",
+				Prefix:   "gan",
+				Filter:   &models.Query{},
+			},
+			fail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSuggest_ValidateSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.SuggestRequest
+		fail bool
+	}{
+		{
+			name: "Text property",
+			req:  models.SuggestRequest{Property: "propText", Prefix: "gan"},
+		},
+		{
+			name: "Non-text property",
+			req:  models.SuggestRequest{Property: "propString", Prefix: "gan"},
+			fail: true,
+		},
+		{
+			name: "Non-existent property",
+			req:  models.SuggestRequest{Property: "nonExistent", Prefix: "gan"},
+			fail: true,
+		},
+		{
+			name: "Invalid filter",
+			req: models.SuggestRequest{
+				Property: "propText",
+				Prefix:   "gan",
+				Filter:   &models.Query{Property: "nonExistent"},
+			},
+			fail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.ValidateSchema(sampleSchema)
+			if tt.fail {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
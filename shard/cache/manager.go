@@ -111,6 +111,9 @@ type Transaction struct {
 	mu            sync.Mutex
 	manager       *Manager
 	failed        atomic.Bool
+	// Shared caches reused and caches created, for profiling
+	hits   atomic.Int64
+	misses atomic.Int64
 }
 
 func (m *Manager) NewTransaction() *Transaction {
@@ -226,7 +229,10 @@ func (t *Transaction) With(name string, readOnly bool, createFn func() (Cachable
 		}
 		if cacheToUse == existingCache {
 			log.Debug().Str("name", name).Bool("readOnly", readOnly).Msg("Reusing cache")
+			t.hits.Add(1)
 			defer t.manager.checkAndPrune()
+		} else {
+			t.misses.Add(1)
 		}
 		if err := f(cacheToUse.item); err != nil {
 			/* Something went wrong, we'll scrap the cache and delete it from the
@@ -241,6 +247,7 @@ func (t *Transaction) With(name string, readOnly bool, createFn func() (Cachable
 		return nil
 	}
 	log.Debug().Str("name", name).Bool("readOnly", readOnly).Msg("Creating new cache")
+	t.misses.Add(1)
 	freshCachable, err := createFn()
 	if err != nil {
 		t.failed.Store(true)
@@ -281,6 +288,12 @@ func (t *Transaction) With(name string, readOnly bool, createFn func() (Cachable
 	return nil
 }
 
+// Returns the number of times the transaction reused a shared cache and the
+// number of times it had to create one.
+func (t *Transaction) Stats() (hits, misses int64) {
+	return t.hits.Load(), t.misses.Load()
+}
+
 // Releases all the locks on the caches. Must be called after the transaction.
 func (t *Transaction) Commit(fail bool) {
 	t.mu.Lock()
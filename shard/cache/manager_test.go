@@ -91,6 +91,9 @@ func TestManager_CacheReuse(t *testing.T) {
 	})
 	require.NoError(t, err)
 	require.Len(t, m.sharedCaches, 1)
+	hits, misses := tx.Stats()
+	require.Equal(t, int64(1), hits)
+	require.Equal(t, int64(1), misses)
 }
 
 func TestManager_SharedReadWhileWrite(t *testing.T) {
@@ -15,6 +15,13 @@ import (
 	"github.com/vmihailenco/msgpack/v5"
 )
 
+// WithBulkInsert hints the indices that the changes dispatched with this
+// context are a large batch of new points. Indices that support it, such as
+// vamana, can then build in bulk instead of inserting one by one.
+func WithBulkInsert(ctx context.Context) context.Context {
+	return vamana.WithBulkBuild(ctx)
+}
+
 type IndexPointChange struct {
 	NodeId       uint64
 	PreviousData []byte
@@ -176,14 +183,26 @@ func (im indexManager) getDrainFn(bucketName string, params models.IndexSchemaVa
 			return utils.MergeErrorsWithContext(ctx, transformErrC, errC)
 		}
 	case models.IndexTypeString:
-		stringIndex := inverted.NewIndexInvertedString(bucket, *params.String)
+		ngramBucket, err := im.ngramBucket(bucketName, *params.String)
+		if err != nil {
+			return nil, err
+		}
+		stringIndex := inverted.NewIndexInvertedString(bucket, ngramBucket, *params.String)
 		drainFn = func(ctx context.Context, in <-chan decodedPointChange) <-chan error {
 			out, transformErrC := utils.TransformWithContext(ctx, in, preProcessInverted[string])
 			errC := stringIndex.InsertUpdateDelete(ctx, out)
 			return utils.MergeErrorsWithContext(ctx, transformErrC, errC)
 		}
 	case models.IndexTypeStringArray:
-		stringArrayIndex := inverted.NewIndexInvertedArrayString(bucket, *params.StringArray)
+		ngramBucket, err := im.ngramBucket(bucketName, params.StringArray.IndexStringParameters)
+		if err != nil {
+			return nil, err
+		}
+		lengthBucket, err := im.lengthBucket(bucketName)
+		if err != nil {
+			return nil, err
+		}
+		stringArrayIndex := inverted.NewIndexInvertedArrayString(bucket, ngramBucket, lengthBucket, *params.StringArray)
 		drainFn = func(ctx context.Context, in <-chan decodedPointChange) <-chan error {
 			out, transformErrC := utils.TransformWithContext(ctx, in, preProcessInvertedArray[string])
 			errC := stringArrayIndex.InsertUpdateDelete(ctx, out)
@@ -42,6 +42,7 @@ var sampleIndexSchema = models.IndexSchema{
 		Type: models.IndexTypeString,
 		String: &models.IndexStringParameters{
 			CaseSensitive: false,
+			NGramSize:     3,
 		},
 	},
 	"labels": models.IndexSchemaValue{
@@ -168,11 +169,12 @@ func TestDispatch_Delete(t *testing.T) {
 			expected := 0
 			switch params.Type {
 			case models.IndexTypeVectorVamana:
-				// These are max node id, start node vector and edges
-				expected = 3
+				// These are max node id, start node vector and edges and
+				// changes since calibration
+				expected = 4
 			case models.IndexTypeText:
-				// The number of documents is left behind
-				expected = 1
+				// The number of documents and total length are left behind
+				expected = 2
 			}
 			require.Equal(t, expected, count, "expected 0 for %s", bucketName)
 		}
@@ -38,6 +38,11 @@ func (inf IndexFlat) UpdateBucket(bucket diskstore.Bucket) {
 	inf.vecStore.UpdateBucket(bucket)
 }
 
+// GetVector returns the stored vector of the point.
+func (inf IndexFlat) GetVector(id uint64) ([]float32, error) {
+	return inf.vecStore.GetVector(id)
+}
+
 func (inf IndexFlat) InsertUpdateDelete(ctx context.Context, points <-chan vamana.IndexVectorChange) <-chan error {
 	sinkErrC := utils.SinkWithContext(ctx, points, func(point vamana.IndexVectorChange) error {
 		// Does this point exist?
@@ -75,6 +80,12 @@ func (inf IndexFlat) InsertUpdateDelete(ctx context.Context, points <-chan vaman
 
 func (inf IndexFlat) Search(ctx context.Context, options models.SearchVectorFlatOptions, filter *roaring64.Bitmap) (*roaring64.Bitmap, []models.SearchResult, error) {
 	distFn := inf.vecStore.DistanceFromFloat(options.Vector)
+	if options.Operator == models.OperatorRecommend {
+		if options.Recommend == nil || len(options.Recommend.PositiveVectors) == 0 {
+			return roaring64.New(), nil, nil
+		}
+		distFn = vectorstore.RecommendDistance(inf.vecStore, options.Recommend.Strategy, options.Recommend.PositiveVectors, options.Recommend.NegativeVectors)
+	}
 	// ---------------------------
 	var weight float32 = 1
 	if options.Weight != nil {
@@ -87,13 +98,17 @@ func (inf IndexFlat) Search(ctx context.Context, options models.SearchVectorFlat
 	 * we keep it single-threaded. Also no reasonably sized collection should
 	 * use flat index as the main one. */
 	startTime := time.Now()
-	res := make([]models.SearchResult, 0, options.Limit)
+	candidates := options.Limit
+	if options.MMR != nil {
+		candidates = max(options.Limit, models.MMRCandidates)
+	}
+	res := make([]models.SearchResult, 0, candidates)
 	err := inf.vecStore.ForEach(func(point vectorstore.VectorStorePoint) error {
 		if filter != nil && !filter.Contains(point.Id()) {
 			return nil
 		}
 		dist := distFn(point)
-		// cap here is capacity of the array = options.Limit above, in case you
+		// cap here is capacity of the array = candidates above, in case you
 		// are new to the Go language.
 		// Is it worth adding?
 		if len(res) == cap(res) && dist >= *res[len(res)-1].Distance {
@@ -124,9 +139,40 @@ func (inf IndexFlat) Search(ctx context.Context, options models.SearchVectorFlat
 	}
 	log.Debug().Dur("elapsed", time.Since(startTime)).Msg("search flat")
 	// ---------------------------
+	if options.MMR != nil {
+		if res, err = inf.mmrResults(res, options.Limit, options.MMR.Lambda, weight); err != nil {
+			return nil, nil, fmt.Errorf("failed to rerank with mmr: %w", err)
+		}
+	}
+	// ---------------------------
 	rSet := roaring64.New()
 	for _, r := range res {
 		rSet.Add(r.NodeId)
 	}
 	return rSet, res, nil
 }
+
+// Picks the limit results from the nearest candidates with maximal marginal
+// relevance, the hybrid score is the weighted relevance score.
+func (inf IndexFlat) mmrResults(candidates []models.SearchResult, limit int, lambda, weight float32) ([]models.SearchResult, error) {
+	ids := make([]uint64, len(candidates))
+	queryDists := make([]float32, len(candidates))
+	for i, r := range candidates {
+		ids[i] = r.NodeId
+		queryDists[i] = *r.Distance
+	}
+	points, err := inf.vecStore.GetMany(ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get candidate points: %w", err)
+	}
+	if len(points) != len(ids) {
+		return nil, fmt.Errorf("expected %d candidate points, got %d", len(ids), len(points))
+	}
+	order, scores := vectorstore.MaximalMarginalRelevance(inf.vecStore, points, queryDists, lambda, limit)
+	results := make([]models.SearchResult, len(order))
+	for i, idx := range order {
+		results[i] = candidates[idx]
+		results[i].HybridScore = scores[i] * weight
+	}
+	return results, nil
+}
@@ -189,3 +189,56 @@ func Test_Recall(t *testing.T) {
 		})
 	}
 }
+
+func Test_SearchMMR(t *testing.T) {
+	bucket := diskstore.NewMemBucket(false)
+	inv, err := flat.NewIndexFlat(flatParams, bucket)
+	require.NoError(t, err)
+	// Near duplicates around the origin and points spread further away
+	points := make([]vamana.IndexVectorChange, 0, 20)
+	for i := 0; i < 10; i++ {
+		points = append(points, vamana.IndexVectorChange{
+			Id:     uint64(i + 2),
+			Vector: []float32{float32(i) * 0.001, 0},
+		})
+		points = append(points, vamana.IndexVectorChange{
+			Id:     uint64(i + 12),
+			Vector: []float32{0.5 + float32(i)*0.1, 1 - float32(i)*0.1},
+		})
+	}
+	ctx := context.Background()
+	errC := inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, points))
+	require.NoError(t, <-errC)
+	// ---------------------------
+	countDuplicates := func(results []models.SearchResult) int {
+		count := 0
+		for _, r := range results {
+			if r.NodeId < 12 {
+				count++
+			}
+		}
+		return count
+	}
+	options := models.SearchVectorFlatOptions{
+		Vector: []float32{0, 0},
+		Limit:  5,
+	}
+	_, results, err := inv.Search(ctx, options, nil)
+	require.NoError(t, err)
+	require.Equal(t, 5, countDuplicates(results))
+	// ---------------------------
+	options.MMR = &models.SearchMMROptions{Lambda: 0.3}
+	rSet, results, err := inv.Search(ctx, options, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.EqualValues(t, 5, rSet.GetCardinality())
+	// The nearest point, then the farthest and the far end of the spread
+	require.Equal(t, uint64(2), results[0].NodeId)
+	require.Equal(t, float32(0), *results[0].Distance)
+	require.Equal(t, uint64(21), results[1].NodeId)
+	require.Equal(t, uint64(12), results[2].NodeId)
+	require.Less(t, countDuplicates(results), 5)
+	for i := 0; i < len(results)-1; i++ {
+		require.GreaterOrEqual(t, results[i].HybridScore, results[i+1].HybridScore)
+	}
+}
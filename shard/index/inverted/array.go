@@ -10,13 +10,42 @@ import (
 	"github.com/semafind/semadb/utils"
 )
 
+// Maps the array size operators to the operators of the lengths index
+var arraySizeOperators = map[string]string{
+	models.OperatorSizeEquals:      models.OperatorEquals,
+	models.OperatorSizeGreaterThan: models.OperatorGreaterThan,
+	models.OperatorSizeGreaterOrEq: models.OperatorGreaterOrEq,
+	models.OperatorSizeLessThan:    models.OperatorLessThan,
+	models.OperatorSizeLessOrEq:    models.OperatorLessOrEq,
+}
+
 type IndexInvertedArray[T Invertable] struct {
 	inner *IndexInverted[T]
+	// Maps the number of items to the documents with arrays of that length
+	lengths *IndexInverted[int64]
 }
 
-func NewIndexInvertedArray[T Invertable](bucket diskstore.Bucket) *IndexInvertedArray[T] {
+// NewIndexInvertedArray creates an array index with the values in the given
+// bucket and the array lengths in a separate lengths bucket.
+func NewIndexInvertedArray[T Invertable](bucket, lengthBucket diskstore.Bucket) *IndexInvertedArray[T] {
 	inv := NewIndexInverted[T](bucket)
-	return &IndexInvertedArray[T]{inner: inv}
+	lengths := NewIndexInverted[int64](lengthBucket)
+	return &IndexInvertedArray[T]{inner: inv, lengths: lengths}
+}
+
+// BackfillArrayLengths stores the array lengths of existing documents, keyed by
+// document id, in the lengths bucket of an array index. Indices created before
+// the lengths were recorded only have the values.
+func BackfillArrayLengths(ctx context.Context, lengthBucket diskstore.Bucket, lengths map[uint64]int64) error {
+	changes := make([]IndexChange[int64], 0, len(lengths))
+	for id, length := range lengths {
+		changes = append(changes, IndexChange[int64]{Id: id, CurrentData: &length})
+	}
+	inv := NewIndexInverted[int64](lengthBucket)
+	if err := <-inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, changes)); err != nil {
+		return fmt.Errorf("error backfilling array lengths: %w", err)
+	}
+	return nil
 }
 
 type IndexArrayChange[T Invertable] struct {
@@ -26,8 +55,12 @@ type IndexArrayChange[T Invertable] struct {
 }
 
 func (inv *IndexInvertedArray[T]) InsertUpdateDelete(ctx context.Context, in <-chan IndexArrayChange[T]) <-chan error {
+	// The length changes are collected as the values are processed and are
+	// applied after them
+	lengthChanges := make([]IndexChange[int64], 0)
 	// We are ignoring the error because the transformation never returns an error
 	out, _ := utils.TransformWithContextMultiple(ctx, in, func(change IndexArrayChange[T]) ([]IndexChange[T], error) {
+		lengthChanges = append(lengthChanges, arrayLengthChange(change))
 		// Any value present in current but not in previous is added
 		// Any value present in previous but not in current is deleted
 		currentSet := make(map[T]struct{})
@@ -52,7 +85,36 @@ func (inv *IndexInvertedArray[T]) InsertUpdateDelete(ctx context.Context, in <-c
 		}
 		return changes, nil
 	})
-	return inv.inner.InsertUpdateDelete(ctx, out)
+	valuesErrC := inv.inner.InsertUpdateDelete(ctx, out)
+	errC := make(chan error, 1)
+	go func() {
+		defer close(errC)
+		// The values are done once the transform has consumed all the changes
+		if err := <-valuesErrC; err != nil {
+			errC <- err
+			return
+		}
+		lengthsErrC := inv.lengths.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, lengthChanges))
+		if err := <-lengthsErrC; err != nil {
+			errC <- fmt.Errorf("error updating array lengths: %w", err)
+		}
+	}()
+	return errC
+}
+
+// A nil array means the property is not there whereas an empty array has
+// length zero.
+func arrayLengthChange[T Invertable](change IndexArrayChange[T]) IndexChange[int64] {
+	lengthChange := IndexChange[int64]{Id: change.Id}
+	if change.PreviousData != nil {
+		prevLength := int64(len(change.PreviousData))
+		lengthChange.PreviousData = &prevLength
+	}
+	if change.CurrentData != nil {
+		currentLength := int64(len(change.CurrentData))
+		lengthChange.CurrentData = &currentLength
+	}
+	return lengthChange
 }
 
 func (inv *IndexInvertedArray[T]) Search(query []T, operator string) (*roaring64.Bitmap, error) {
@@ -69,6 +131,14 @@ func (inv *IndexInvertedArray[T]) Search(query []T, operator string) (*roaring64
 		resList[i] = res
 	}
 	// ---------------------------
+	if operator == models.OperatorContainsNone {
+		// Every array has a length so these are all the indexed documents
+		all, err := inv.lengths.Search(0, 0, models.OperatorGreaterOrEq)
+		if err != nil {
+			return nil, fmt.Errorf("error getting all arrays: %w", err)
+		}
+		return roaring64.AndNot(all, roaring64.FastOr(resList...)), nil
+	}
 	if len(resList) == 1 {
 		return resList[0], nil
 	}
@@ -84,3 +154,12 @@ func (inv *IndexInvertedArray[T]) Search(query []T, operator string) (*roaring64
 	// ---------------------------
 	return finalSet, nil
 }
+
+// SearchSize finds the documents by the number of items in their arrays.
+func (inv *IndexInvertedArray[T]) SearchSize(size int64, operator string) (*roaring64.Bitmap, error) {
+	lengthOperator, ok := arraySizeOperators[operator]
+	if !ok {
+		return nil, fmt.Errorf("unsupported size operator %s", operator)
+	}
+	return inv.lengths.Search(size, size, lengthOperator)
+}
@@ -28,7 +28,8 @@ var arrayInverseMapping = map[int64]*roaring64.Bitmap{
 
 func setupArrayIndex(t *testing.T) *inverted.IndexInvertedArray[int64] {
 	b := diskstore.NewMemBucket(false)
-	inv := inverted.NewIndexInvertedArray[int64](b)
+	lb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedArray[int64](b, lb)
 	in := make(chan inverted.IndexArrayChange[int64])
 	errC := inv.InsertUpdateDelete(context.Background(), in)
 	for i, v := range arrayItems {
@@ -40,12 +41,15 @@ func setupArrayIndex(t *testing.T) *inverted.IndexInvertedArray[int64] {
 	close(in)
 	require.NoError(t, <-errC)
 	checkTermCount(t, b, 5)
+	// The single array length of 3
+	checkTermCount(t, lb, 1)
 	return inv
 }
 
 func TestArray_Insert(t *testing.T) {
 	b := diskstore.NewMemBucket(false)
-	inv := inverted.NewIndexInvertedArray[int64](b)
+	lb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedArray[int64](b, lb)
 	in := make(chan inverted.IndexArrayChange[int64])
 	errC := inv.InsertUpdateDelete(context.Background(), in)
 	for i, v := range arrayItems {
@@ -57,11 +61,14 @@ func TestArray_Insert(t *testing.T) {
 	close(in)
 	require.NoError(t, <-errC)
 	checkTermCount(t, b, 5)
+	// The single array length of 3
+	checkTermCount(t, lb, 1)
 }
 
 func TestArray_Persistance(t *testing.T) {
 	b := diskstore.NewMemBucket(false)
-	inv := inverted.NewIndexInvertedArray[int64](b)
+	lb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedArray[int64](b, lb)
 	in := make(chan inverted.IndexArrayChange[int64])
 	errC := inv.InsertUpdateDelete(context.Background(), in)
 	for i, v := range arrayItems {
@@ -73,8 +80,10 @@ func TestArray_Persistance(t *testing.T) {
 	close(in)
 	require.NoError(t, <-errC)
 	checkTermCount(t, b, 5)
+	// The single array length of 3
+	checkTermCount(t, lb, 1)
 	// ---------------------------
-	inv2 := inverted.NewIndexInvertedArray[int64](b)
+	inv2 := inverted.NewIndexInvertedArray[int64](b, lb)
 	for k, v := range arrayInverseMapping {
 		set, err := inv2.Search([]int64{k}, models.OperatorContainsAll)
 		require.NoError(t, err)
@@ -152,6 +161,13 @@ func TestArray_ConcurrentCUDD(t *testing.T) {
 		require.NoError(t, err)
 		require.True(t, set.Equals(v))
 	}
+	// The lengths follow the updates and deletes
+	set, err := inv.SearchSize(3, models.OperatorSizeEquals)
+	require.NoError(t, err)
+	require.True(t, set.Equals(roaring64.BitmapOf(10, 11)))
+	set, err = inv.SearchSize(2, models.OperatorSizeEquals)
+	require.NoError(t, err)
+	require.True(t, set.Equals(roaring64.BitmapOf(1)))
 }
 
 func TestArray_Search(t *testing.T) {
@@ -175,6 +191,9 @@ func TestArray_Search(t *testing.T) {
 		{"threeAll", []int64{1, 2, 3}, models.OperatorContainsAll, []uint64{0}},
 		{"threeAllthreeDoc", []int64{1, 2, 3}, models.OperatorContainsAny, []uint64{0, 1, 2}},
 		{"empty", []int64{1, 2, 3, 4}, models.OperatorContainsAll, []uint64{}},
+		{"none", []int64{1, 5}, models.OperatorContainsNone, []uint64{1}},
+		{"noneSingle", []int64{3}, models.OperatorContainsNone, []uint64{}},
+		{"noneMissing", []int64{42}, models.OperatorContainsNone, []uint64{0, 1, 2}},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -185,3 +204,76 @@ func TestArray_Search(t *testing.T) {
 		})
 	}
 }
+
+func TestArray_SearchSize(t *testing.T) {
+	b := diskstore.NewMemBucket(false)
+	lb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedArray[int64](b, lb)
+	in := make(chan inverted.IndexArrayChange[int64])
+	errC := inv.InsertUpdateDelete(context.Background(), in)
+	for i, v := range [][]int64{{}, {1}, {1, 2}, {1, 2, 3}, nil} {
+		in <- inverted.IndexArrayChange[int64]{
+			Id:          uint64(i),
+			CurrentData: v,
+		}
+	}
+	close(in)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	tests := []struct {
+		size        int64
+		operator    string
+		expectedIds []uint64
+	}{
+		{0, models.OperatorSizeEquals, []uint64{0}},
+		{2, models.OperatorSizeEquals, []uint64{2}},
+		{1, models.OperatorSizeGreaterThan, []uint64{2, 3}},
+		{1, models.OperatorSizeGreaterOrEq, []uint64{1, 2, 3}},
+		{2, models.OperatorSizeLessThan, []uint64{0, 1}},
+		{2, models.OperatorSizeLessOrEq, []uint64{0, 1, 2}},
+		{42, models.OperatorSizeGreaterThan, []uint64{}},
+	}
+	for _, test := range tests {
+		res, err := inv.SearchSize(test.size, test.operator)
+		require.NoError(t, err)
+		require.ElementsMatch(t, test.expectedIds, res.ToArray(), "%s %d", test.operator, test.size)
+	}
+	_, err := inv.SearchSize(1, models.OperatorEquals)
+	require.Error(t, err)
+	// The empty array contains none of the values, the missing one is not indexed
+	res, err := inv.Search([]int64{2}, models.OperatorContainsNone)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{0, 1}, res.ToArray())
+}
+
+func TestArray_BackfillLengths(t *testing.T) {
+	b := diskstore.NewMemBucket(false)
+	lb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedArray[int64](b, lb)
+	in := make(chan inverted.IndexArrayChange[int64])
+	errC := inv.InsertUpdateDelete(context.Background(), in)
+	for i, v := range arrayItems {
+		in <- inverted.IndexArrayChange[int64]{
+			Id:          uint64(i),
+			CurrentData: v,
+		}
+	}
+	close(in)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	// An index from before the lengths were recorded
+	lb = diskstore.NewMemBucket(false)
+	inv = inverted.NewIndexInvertedArray[int64](b, lb)
+	res, err := inv.Search([]int64{1}, models.OperatorContainsNone)
+	require.NoError(t, err)
+	require.True(t, res.IsEmpty())
+	lengths := map[uint64]int64{0: 3, 1: 3, 2: 3, 3: 0}
+	require.NoError(t, inverted.BackfillArrayLengths(context.Background(), lb, lengths))
+	inv = inverted.NewIndexInvertedArray[int64](b, lb)
+	res, err = inv.Search([]int64{1}, models.OperatorContainsNone)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1, 2, 3}, res.ToArray())
+	res, err = inv.SearchSize(0, models.OperatorSizeEquals)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{3}, res.ToArray())
+}
@@ -24,11 +24,21 @@ type Invertable interface {
 type setCacheItem struct {
 	set     *roaring64.Bitmap
 	isDirty bool
+	// Whether the set of the term is in the bucket
+	isStored bool
+}
+
+// A sidecar is an auxiliary index over the distinct terms of an inverted
+// index. It is told when a term is first stored or removed during a flush.
+type sidecar[T Invertable] interface {
+	addTerm(term T) error
+	removeTerm(term T) error
 }
 
 type IndexInverted[T Invertable] struct {
 	setCache map[T]*setCacheItem
 	bucket   diskstore.Bucket
+	sidecar  sidecar[T]
 	mu       sync.Mutex
 }
 
@@ -58,7 +68,8 @@ func (inv *IndexInverted[T]) getSetCacheItem(value T, setBytes []byte) (*setCach
 			}
 		}
 		item = &setCacheItem{
-			set: rSet,
+			set:      rSet,
+			isStored: setBytes != nil,
 		}
 		inv.setCache[value] = item
 
@@ -150,6 +161,12 @@ func (inv *IndexInverted[T]) flush() error {
 			if err := inv.bucket.Delete(key); err != nil {
 				return fmt.Errorf("error deleting term set from bucket: %w", err)
 			}
+			if item.isStored && inv.sidecar != nil {
+				if err := inv.sidecar.removeTerm(term); err != nil {
+					return fmt.Errorf("error removing term from sidecar: %w", err)
+				}
+			}
+			item.isStored = false
 			continue
 		}
 		// ---------------------------
@@ -160,6 +177,12 @@ func (inv *IndexInverted[T]) flush() error {
 		if err := inv.bucket.Put(key, setBytes); err != nil {
 			return fmt.Errorf("error putting term set to bucket: %w", err)
 		}
+		if !item.isStored && inv.sidecar != nil {
+			if err := inv.sidecar.addTerm(term); err != nil {
+				return fmt.Errorf("error adding term to sidecar: %w", err)
+			}
+		}
+		item.isStored = true
 	}
 	// ---------------------------
 	return nil
@@ -273,12 +296,67 @@ func (inv *IndexInverted[T]) Search(query T, endQuery T, operator string) (*roar
 		}
 	}
 	// ---------------------------
+	return unionSets(sets), nil
+}
+
+// SearchSet finds the documents with any of the values for the in operator or
+// with any other value for notIn. Like notEquals, notIn scans the whole index
+// but only once for all the values.
+func (inv *IndexInverted[T]) SearchSet(values []T, operator string) (*roaring64.Bitmap, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	// ---------------------------
+	sets := make([]*roaring64.Bitmap, 0, len(values))
+	switch operator {
+	case models.OperatorIn:
+		for _, value := range values {
+			item, err := inv.getSetCacheItem(value, nil)
+			if err != nil {
+				return nil, fmt.Errorf("error getting set cache item: %w", err)
+			}
+			sets = append(sets, item.set)
+		}
+	case models.OperatorNotIn:
+		excluded := make(map[string]struct{}, len(values))
+		for _, value := range values {
+			key, err := toByteSortable(value)
+			if err != nil {
+				return nil, fmt.Errorf("error converting value %v to search: %w", value, err)
+			}
+			excluded[string(key)] = struct{}{}
+		}
+		err := inv.bucket.ForEach(func(k, v []byte) error {
+			if _, ok := excluded[string(k)]; ok {
+				return nil
+			}
+			var reverseKey T
+			if err := fromByteSortable(k, &reverseKey); err != nil {
+				return fmt.Errorf("error converting key to value: %w", err)
+			}
+			item, err := inv.getSetCacheItem(reverseKey, v)
+			if err != nil {
+				return fmt.Errorf("error getting set cache item: %w", err)
+			}
+			sets = append(sets, item.set)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over bucket for inverted search: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown inverted set search operator: %s", operator)
+	}
+	// ---------------------------
+	return unionSets(sets), nil
+}
+
+// Merges the sets of the matching terms, a single set is returned as is.
+func unionSets(sets []*roaring64.Bitmap) *roaring64.Bitmap {
 	if len(sets) == 0 {
-		return roaring64.New(), nil
+		return roaring64.New()
 	}
 	if len(sets) == 1 {
-		return sets[0], nil
+		return sets[0]
 	}
-	// ---------------------------
-	return roaring64.FastOr(sets...), nil
+	return roaring64.FastOr(sets...)
 }
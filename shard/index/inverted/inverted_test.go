@@ -214,3 +214,30 @@ func Test_Search(t *testing.T) {
 		})
 	}
 }
+
+func Test_SearchSet(t *testing.T) {
+	inv := setupIndexInverted(t)
+	// ----------------
+	tests := []struct {
+		values             []int64
+		operator           string
+		expectedBitmapKeys []int64
+	}{
+		{values: []int64{2, 4}, operator: models.OperatorIn, expectedBitmapKeys: []int64{2, 4}},
+		{values: []int64{3, 42}, operator: models.OperatorIn, expectedBitmapKeys: []int64{3}},
+		{values: []int64{42}, operator: models.OperatorIn, expectedBitmapKeys: []int64{}},
+		{values: []int64{2, 4}, operator: models.OperatorNotIn, expectedBitmapKeys: []int64{1, 3}},
+		{values: []int64{42}, operator: models.OperatorNotIn, expectedBitmapKeys: []int64{1, 2, 3, 4}},
+	}
+	for _, test := range tests {
+		rSet, err := inv.SearchSet(test.values, test.operator)
+		require.NoError(t, err)
+		finalSet := roaring64.New()
+		for _, k := range test.expectedBitmapKeys {
+			finalSet.Or(inverseMapping[k])
+		}
+		require.True(t, rSet.Equals(finalSet), "%s %v", test.operator, test.values)
+	}
+	_, err := inv.SearchSet([]int64{1}, models.OperatorEquals)
+	require.Error(t, err)
+}
@@ -0,0 +1,263 @@
+package inverted
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/models"
+)
+
+// ngramIndex maps the n-grams of every distinct string value in an inverted
+// index to the values containing them. The key layout is gram 0x00 value with
+// an empty value, so a prefix scan over a gram lists its values. Contains,
+// endsWith and regex queries use it to check only the values that have all the
+// n-grams of the query instead of every value.
+type ngramIndex struct {
+	bucket diskstore.Bucket
+	size   int
+}
+
+// Returns the distinct n-grams of a value, values shorter than the n-gram size
+// have none.
+func ngrams(value string, size int) []string {
+	runes := []rune(value)
+	if len(runes) < size {
+		return nil
+	}
+	grams := make([]string, 0, len(runes)-size+1)
+	seen := make(map[string]struct{}, len(runes)-size+1)
+	for i := 0; i+size <= len(runes); i++ {
+		gram := string(runes[i : i+size])
+		if _, ok := seen[gram]; ok {
+			continue
+		}
+		seen[gram] = struct{}{}
+		grams = append(grams, gram)
+	}
+	return grams
+}
+
+func ngramKey(gram, value string) []byte {
+	key := make([]byte, 0, len(gram)+1+len(value))
+	key = append(key, gram...)
+	key = append(key, 0)
+	return append(key, value...)
+}
+
+func (ng *ngramIndex) addTerm(value string) error {
+	for _, gram := range ngrams(value, ng.size) {
+		if err := ng.bucket.Put(ngramKey(gram, value), []byte{}); err != nil {
+			return fmt.Errorf("error putting ngram %s: %w", gram, err)
+		}
+	}
+	return nil
+}
+
+func (ng *ngramIndex) removeTerm(value string) error {
+	for _, gram := range ngrams(value, ng.size) {
+		if err := ng.bucket.Delete(ngramKey(gram, value)); err != nil {
+			return fmt.Errorf("error deleting ngram %s: %w", gram, err)
+		}
+	}
+	return nil
+}
+
+// Returns the values that contain all the given n-grams.
+func (ng *ngramIndex) valuesWithAll(grams []string) (map[string]struct{}, error) {
+	var values map[string]struct{}
+	for _, gram := range grams {
+		prefix := ngramKey(gram, "")
+		current := make(map[string]struct{})
+		err := ng.bucket.PrefixScan(prefix, func(k, v []byte) error {
+			value := string(k[len(prefix):])
+			if values == nil {
+				current[value] = struct{}{}
+			} else if _, ok := values[value]; ok {
+				current[value] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error scanning ngram %s: %w", gram, err)
+		}
+		values = current
+		if len(values) == 0 {
+			break
+		}
+	}
+	return values, nil
+}
+
+// candidates returns the values that may match any of the patterns. A nil map
+// means every value has to be checked, either because there is no n-gram index
+// or a pattern has no literal long enough to look up.
+func (ng *ngramIndex) candidates(patterns []stringPattern) (map[string]struct{}, error) {
+	if ng == nil {
+		return nil, nil
+	}
+	gramsPerPattern := make([][]string, len(patterns))
+	for i, p := range patterns {
+		for _, lit := range p.literals {
+			gramsPerPattern[i] = append(gramsPerPattern[i], ngrams(lit, ng.size)...)
+		}
+		if len(gramsPerPattern[i]) == 0 {
+			return nil, nil
+		}
+	}
+	candidates := make(map[string]struct{})
+	for _, grams := range gramsPerPattern {
+		values, err := ng.valuesWithAll(grams)
+		if err != nil {
+			return nil, err
+		}
+		for v := range values {
+			candidates[v] = struct{}{}
+		}
+	}
+	return candidates, nil
+}
+
+// ---------------------------
+
+// A stringPattern is a contains, endsWith or regex query on string values.
+type stringPattern struct {
+	match func(string) bool
+	// Substrings every matching value contains
+	literals []string
+}
+
+// The values are lowercased in case insensitive indices so the query is too,
+// and regular expressions are made case insensitive instead.
+func newStringPattern(value, operator string, caseSensitive bool) (stringPattern, error) {
+	switch operator {
+	case models.OperatorContains:
+		if !caseSensitive {
+			value = strings.ToLower(value)
+		}
+		return stringPattern{
+			match:    func(s string) bool { return strings.Contains(s, value) },
+			literals: []string{value},
+		}, nil
+	case models.OperatorEndsWith:
+		if !caseSensitive {
+			value = strings.ToLower(value)
+		}
+		return stringPattern{
+			match:    func(s string) bool { return strings.HasSuffix(s, value) },
+			literals: []string{value},
+		}, nil
+	case models.OperatorRegex:
+		if !caseSensitive {
+			value = "(?i)" + value
+		}
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return stringPattern{}, fmt.Errorf("invalid regex %s: %w", value, err)
+		}
+		parsed, err := syntax.Parse(value, syntax.Perl)
+		if err != nil {
+			return stringPattern{}, fmt.Errorf("invalid regex %s: %w", value, err)
+		}
+		return stringPattern{
+			match:    re.MatchString,
+			literals: requiredLiterals(parsed.Simplify(), !caseSensitive),
+		}, nil
+	default:
+		return stringPattern{}, fmt.Errorf("unsupported string pattern operator %s", operator)
+	}
+}
+
+// requiredLiterals returns the literal strings that every match of the regular
+// expression contains. Case folded literals are lowercased when the values are
+// lowercase and skipped otherwise.
+func requiredLiterals(re *syntax.Regexp, lowercase bool) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			return []string{string(re.Rune)}
+		}
+		if !lowercase {
+			return nil
+		}
+		// Runes such as s also fold to others, ſ in this case, which
+		// lowercasing does not cover
+		for _, r := range re.Rune {
+			if unicode.SimpleFold(unicode.SimpleFold(r)) != r {
+				return nil
+			}
+		}
+		return []string{strings.ToLower(string(re.Rune))}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0], lowercase)
+	case syntax.OpConcat:
+		var literals []string
+		for _, sub := range re.Sub {
+			literals = append(literals, requiredLiterals(sub, lowercase)...)
+		}
+		return literals
+	}
+	return nil
+}
+
+// ---------------------------
+
+// searchPatterns returns the documents with a value matching any of the
+// patterns. Only the candidates from the n-gram index are checked if there is
+// one, otherwise all the values are scanned.
+func searchPatterns(inv *IndexInverted[string], ng *ngramIndex, patterns []stringPattern) (*roaring64.Bitmap, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	// ---------------------------
+	matches := func(value string) bool {
+		for _, p := range patterns {
+			if p.match(value) {
+				return true
+			}
+		}
+		return false
+	}
+	candidates, err := ng.candidates(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ngram candidates: %w", err)
+	}
+	sets := make([]*roaring64.Bitmap, 0)
+	// ---------------------------
+	if candidates == nil {
+		err := inv.bucket.ForEach(func(k, v []byte) error {
+			var value string
+			if err := fromByteSortable(k, &value); err != nil {
+				return fmt.Errorf("error converting key to value: %w", err)
+			}
+			if !matches(value) {
+				return nil
+			}
+			item, err := inv.getSetCacheItem(value, v)
+			if err != nil {
+				return fmt.Errorf("error getting set cache item: %w", err)
+			}
+			sets = append(sets, item.set)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error iterating over bucket for pattern search: %w", err)
+		}
+	} else {
+		for value := range candidates {
+			if !matches(value) {
+				continue
+			}
+			item, err := inv.getSetCacheItem(value, nil)
+			if err != nil {
+				return nil, fmt.Errorf("error getting set cache item: %w", err)
+			}
+			sets = append(sets, item.set)
+		}
+	}
+	// ---------------------------
+	return unionSets(sets), nil
+}
@@ -0,0 +1,175 @@
+package inverted_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/index/inverted"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InvertedStringPatterns(t *testing.T) {
+	items := []string{"sku-1001", "SKU-2002", "gandalf@example.com", "frodo@shire.org", "ab"}
+	tests := []struct {
+		name          string
+		caseSensitive bool
+		value         string
+		operator      string
+		expected      []uint64
+	}{
+		{"contains", false, "EXAMPLE", models.OperatorContains, []uint64{2}},
+		{"contains prefix", false, "sku-", models.OperatorContains, []uint64{0, 1}},
+		{"contains short", false, "b", models.OperatorContains, []uint64{4}},
+		{"contains none", false, "sauron", models.OperatorContains, []uint64{}},
+		{"endsWith", false, ".org", models.OperatorEndsWith, []uint64{3}},
+		{"endsWith not suffix", false, "shire", models.OperatorEndsWith, []uint64{}},
+		{"regex", false, "^sku-[0-9]+$", models.OperatorRegex, []uint64{0, 1}},
+		{"regex capture", false, "(ample)\\.com", models.OperatorRegex, []uint64{2}},
+		{"regex alternation", false, "shire|ab$", models.OperatorRegex, []uint64{3, 4}},
+		{"case sensitive contains", true, "SKU", models.OperatorContains, []uint64{1}},
+		{"case sensitive regex", true, "^sku", models.OperatorRegex, []uint64{0}},
+		{"case sensitive folded regex", true, "(?i)^sku", models.OperatorRegex, []uint64{0, 1}},
+	}
+	for _, ngramSize := range []int{0, 3} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s ngram %d", tt.name, ngramSize), func(t *testing.T) {
+				b := diskstore.NewMemBucket(false)
+				nb := diskstore.NewMemBucket(false)
+				inv := inverted.NewIndexInvertedString(b, nb, models.IndexStringParameters{
+					CaseSensitive: tt.caseSensitive,
+					NGramSize:     ngramSize,
+				})
+				in := make(chan inverted.IndexChange[string])
+				errC := inv.InsertUpdateDelete(context.Background(), in)
+				for i, v := range items {
+					in <- inverted.IndexChange[string]{
+						Id:          uint64(i),
+						CurrentData: &v,
+					}
+				}
+				close(in)
+				require.NoError(t, <-errC)
+				res, err := inv.Search(models.SearchStringOptions{
+					Value:    tt.value,
+					Operator: tt.operator,
+				})
+				require.NoError(t, err)
+				require.ElementsMatch(t, tt.expected, res.ToArray())
+			})
+		}
+	}
+}
+
+func Test_InvertedStringNGramDelete(t *testing.T) {
+	b := diskstore.NewMemBucket(false)
+	nb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedString(b, nb, models.IndexStringParameters{
+		NGramSize: 3,
+	})
+	// ---------------------------
+	first := "shire"
+	second := "shire"
+	in := make(chan inverted.IndexChange[string])
+	errC := inv.InsertUpdateDelete(context.Background(), in)
+	in <- inverted.IndexChange[string]{Id: 0, CurrentData: &first}
+	in <- inverted.IndexChange[string]{Id: 1, CurrentData: &second}
+	close(in)
+	require.NoError(t, <-errC)
+	// shi, hir and ire of the single distinct value
+	checkTermCount(t, nb, 3)
+	// ---------------------------
+	// The n-grams stay while another point has the value
+	in = make(chan inverted.IndexChange[string])
+	errC = inv.InsertUpdateDelete(context.Background(), in)
+	in <- inverted.IndexChange[string]{Id: 0, PreviousData: &first}
+	close(in)
+	require.NoError(t, <-errC)
+	checkTermCount(t, nb, 3)
+	// ---------------------------
+	mordor := "mordor"
+	in = make(chan inverted.IndexChange[string])
+	errC = inv.InsertUpdateDelete(context.Background(), in)
+	in <- inverted.IndexChange[string]{Id: 1, PreviousData: &second, CurrentData: &mordor}
+	close(in)
+	require.NoError(t, <-errC)
+	// mor, ord, rdo, dor
+	checkTermCount(t, nb, 4)
+	res, err := inv.Search(models.SearchStringOptions{
+		Value:    "hir",
+		Operator: models.OperatorContains,
+	})
+	require.NoError(t, err)
+	require.True(t, res.IsEmpty())
+	res, err = inv.Search(models.SearchStringOptions{
+		Value:    "rdor",
+		Operator: models.OperatorContains,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(1)))
+}
+
+func Test_InvertedArrayStringPatterns(t *testing.T) {
+	items := [][]string{
+		{"gandalf@example.com", "gandalf@shire.org"},
+		{"frodo@shire.org"},
+		{"sauron@mordor.net"},
+	}
+	b := diskstore.NewMemBucket(false)
+	nb := diskstore.NewMemBucket(false)
+	inv := inverted.NewIndexInvertedArrayString(b, nb, diskstore.NewMemBucket(false), models.IndexStringArrayParameters{
+		IndexStringParameters: models.IndexStringParameters{NGramSize: 3},
+	})
+	in := make(chan inverted.IndexArrayChange[string])
+	errC := inv.InsertUpdateDelete(context.Background(), in)
+	for i, v := range items {
+		in <- inverted.IndexArrayChange[string]{
+			Id:          uint64(i),
+			CurrentData: v,
+		}
+	}
+	close(in)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	res, err := inv.Search(models.SearchStringArrayOptions{
+		Value:    []string{"@shire"},
+		Operator: models.OperatorContains,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(0, 1)))
+	// Any of the values can match
+	res, err = inv.Search(models.SearchStringArrayOptions{
+		Value:    []string{".com", ".net"},
+		Operator: models.OperatorEndsWith,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(0, 2)))
+	res, err = inv.Search(models.SearchStringArrayOptions{
+		Value:    []string{"^FRODO@"},
+		Operator: models.OperatorRegex,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(1)))
+	// Without literals every value is scanned
+	res, err = inv.Search(models.SearchStringArrayOptions{
+		Value:    []string{"^.*$"},
+		Operator: models.OperatorRegex,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(0, 1, 2)))
+	res, err = inv.Search(models.SearchStringArrayOptions{
+		Value:    []string{"GANDALF@SHIRE.ORG"},
+		Operator: models.OperatorContainsNone,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(1, 2)))
+	res, err = inv.Search(models.SearchStringArrayOptions{
+		Size:     2,
+		Operator: models.OperatorSizeLessThan,
+	})
+	require.NoError(t, err)
+	require.True(t, res.Equals(roaring64.BitmapOf(1, 2)))
+}
@@ -14,12 +14,24 @@ import (
 
 type IndexInvertedString struct {
 	inner  *IndexInverted[string]
+	ngrams *ngramIndex
 	params models.IndexStringParameters
 }
 
-func NewIndexInvertedString(bucket diskstore.Bucket, params models.IndexStringParameters) *IndexInvertedString {
+// The n-gram bucket is only used if the n-gram size parameter is set.
+func NewIndexInvertedString(bucket, ngramBucket diskstore.Bucket, params models.IndexStringParameters) *IndexInvertedString {
 	inv := NewIndexInverted[string](bucket)
-	return &IndexInvertedString{inner: inv, params: params}
+	ng := newNGramIndex(inv, ngramBucket, params)
+	return &IndexInvertedString{inner: inv, ngrams: ng, params: params}
+}
+
+func newNGramIndex(inv *IndexInverted[string], bucket diskstore.Bucket, params models.IndexStringParameters) *ngramIndex {
+	if params.NGramSize == 0 {
+		return nil
+	}
+	ng := &ngramIndex{bucket: bucket, size: params.NGramSize}
+	inv.sidecar = ng
+	return ng
 }
 
 func (inv *IndexInvertedString) InsertUpdateDelete(ctx context.Context, in <-chan IndexChange[string]) <-chan error {
@@ -43,6 +55,23 @@ func (inv *IndexInvertedString) InsertUpdateDelete(ctx context.Context, in <-cha
 }
 
 func (inv *IndexInvertedString) Search(options models.SearchStringOptions) (*roaring64.Bitmap, error) {
+	switch options.Operator {
+	case models.OperatorContains, models.OperatorEndsWith, models.OperatorRegex:
+		pattern, err := newStringPattern(options.Value, options.Operator, inv.params.CaseSensitive)
+		if err != nil {
+			return nil, err
+		}
+		return searchPatterns(inv.inner, inv.ngrams, []stringPattern{pattern})
+	case models.OperatorIn, models.OperatorNotIn:
+		values := options.Values
+		if !inv.params.CaseSensitive {
+			values = make([]string, len(options.Values))
+			for i, v := range options.Values {
+				values[i] = strings.ToLower(v)
+			}
+		}
+		return inv.inner.SearchSet(values, options.Operator)
+	}
 	query := options.Value
 	if !inv.params.CaseSensitive {
 		query = strings.ToLower(query)
@@ -54,12 +83,15 @@ func (inv *IndexInvertedString) Search(options models.SearchStringOptions) (*roa
 
 type IndexInvertedArrayString struct {
 	inner  *IndexInvertedArray[string]
+	ngrams *ngramIndex
 	params models.IndexStringArrayParameters
 }
 
-func NewIndexInvertedArrayString(bucket diskstore.Bucket, params models.IndexStringArrayParameters) *IndexInvertedArrayString {
-	inv := NewIndexInvertedArray[string](bucket)
-	return &IndexInvertedArrayString{inner: inv, params: params}
+// The n-gram bucket is only used if the n-gram size parameter is set.
+func NewIndexInvertedArrayString(bucket, ngramBucket, lengthBucket diskstore.Bucket, params models.IndexStringArrayParameters) *IndexInvertedArrayString {
+	inv := NewIndexInvertedArray[string](bucket, lengthBucket)
+	ng := newNGramIndex(inv.inner, ngramBucket, params.IndexStringParameters)
+	return &IndexInvertedArrayString{inner: inv, ngrams: ng, params: params}
 }
 
 func (inv *IndexInvertedArrayString) InsertUpdateDelete(ctx context.Context, in <-chan IndexArrayChange[string]) <-chan error {
@@ -81,6 +113,20 @@ func (inv *IndexInvertedArrayString) InsertUpdateDelete(ctx context.Context, in
 }
 
 func (inv *IndexInvertedArrayString) Search(options models.SearchStringArrayOptions) (*roaring64.Bitmap, error) {
+	switch options.Operator {
+	case models.OperatorContains, models.OperatorEndsWith, models.OperatorRegex:
+		patterns := make([]stringPattern, len(options.Value))
+		for i, v := range options.Value {
+			pattern, err := newStringPattern(v, options.Operator, inv.params.CaseSensitive)
+			if err != nil {
+				return nil, err
+			}
+			patterns[i] = pattern
+		}
+		return searchPatterns(inv.inner.inner, inv.ngrams, patterns)
+	case models.OperatorSizeEquals, models.OperatorSizeGreaterThan, models.OperatorSizeGreaterOrEq, models.OperatorSizeLessThan, models.OperatorSizeLessOrEq:
+		return inv.inner.SearchSize(options.Size, options.Operator)
+	}
 	query := options.Value
 	if !inv.params.CaseSensitive {
 		for i := range query {
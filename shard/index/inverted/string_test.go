@@ -46,7 +46,7 @@ func Test_InvertedStringIndex(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := diskstore.NewMemBucket(false)
-			inv := inverted.NewIndexInvertedString(b, models.IndexStringParameters{
+			inv := inverted.NewIndexInvertedString(b, nil, models.IndexStringParameters{
 				CaseSensitive: tt.caseSensitive,
 			})
 			in := make(chan inverted.IndexChange[string])
@@ -69,6 +69,27 @@ func Test_InvertedStringIndex(t *testing.T) {
 				require.NoError(t, err)
 				require.True(t, res.Equals(roaring64.BitmapOf(expected...)))
 			}
+			// Set membership follows the case sensitivity too
+			res, err := inv.Search(models.SearchStringOptions{
+				Values:   []string{"HELLO", "foo"},
+				Operator: models.OperatorIn,
+			})
+			require.NoError(t, err)
+			if tt.caseSensitive {
+				require.True(t, res.Equals(roaring64.BitmapOf(2, 5)))
+			} else {
+				require.True(t, res.Equals(roaring64.BitmapOf(0, 2, 5)))
+			}
+			res, err = inv.Search(models.SearchStringOptions{
+				Values:   []string{"HELLO", "world", "bar"},
+				Operator: models.OperatorNotIn,
+			})
+			require.NoError(t, err)
+			if tt.caseSensitive {
+				require.True(t, res.Equals(roaring64.BitmapOf(0, 4, 5, 6)))
+			} else {
+				require.True(t, res.Equals(roaring64.BitmapOf(5)))
+			}
 		})
 	}
 }
@@ -120,7 +141,7 @@ func Test_InvertedArrayStringIndex(t *testing.T) {
 				{"bar", "bar"},
 			}
 			b := diskstore.NewMemBucket(false)
-			inv := inverted.NewIndexInvertedArrayString(b, models.IndexStringArrayParameters{
+			inv := inverted.NewIndexInvertedArrayString(b, nil, diskstore.NewMemBucket(false), models.IndexStringArrayParameters{
 				IndexStringParameters: models.IndexStringParameters{
 					CaseSensitive: tt.caseSensitive,
 				},
@@ -1,9 +1,16 @@
 package index
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/semafind/semadb/conversion"
 	"github.com/semafind/semadb/diskstore"
 	"github.com/semafind/semadb/models"
 	"github.com/semafind/semadb/shard/cache"
+	"github.com/semafind/semadb/shard/index/inverted"
+	"github.com/semafind/semadb/shard/pointstore"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 type indexManager struct {
@@ -26,3 +33,107 @@ func NewIndexManager(
 		indexSchema: indexSchema,
 	}
 }
+
+// Returns the n-gram bucket of a string index, kept apart from the index
+// bucket as e.g. ngram/index/string/sku, or nil if n-grams are not enabled.
+func (im indexManager) ngramBucket(bucketName string, params models.IndexStringParameters) (diskstore.Bucket, error) {
+	if params.NGramSize == 0 {
+		return nil, nil
+	}
+	ngramBucketName := "ngram/" + bucketName
+	b, err := im.bm.Get(ngramBucketName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get bucket %s: %w", ngramBucketName, err)
+	}
+	return b, nil
+}
+
+// Returns the array lengths bucket of a string array index, kept apart from
+// the index bucket as e.g. length/index/stringArray/tags.
+func (im indexManager) lengthBucket(bucketName string) (diskstore.Bucket, error) {
+	lengthBucketName := "length/" + bucketName
+	b, err := im.bm.Get(lengthBucketName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get bucket %s: %w", lengthBucketName, err)
+	}
+	return b, nil
+}
+
+/* Indices created before the array lengths were recorded only have the values,
+ * so the lengths are backfilled once from the points. This bucket marks the
+ * string array indices whose lengths are complete by their bucket name. An
+ * index can't tell on its own as all its arrays may be empty. */
+const arrayLengthsCompleteBucketName = "length/complete"
+
+// ArrayLengthsMissing reports whether any string array index needs its array
+// lengths backfilled with BackfillArrayLengths.
+func (im indexManager) ArrayLengthsMissing() (bool, error) {
+	completeBucket, err := im.bm.Get(arrayLengthsCompleteBucketName)
+	if err != nil {
+		return false, fmt.Errorf("could not get bucket %s: %w", arrayLengthsCompleteBucketName, err)
+	}
+	for propName, params := range im.indexSchema {
+		if params.Type != models.IndexTypeStringArray {
+			continue
+		}
+		bucketName := fmt.Sprintf("index/%s/%s", params.Type, propName)
+		if completeBucket.Get([]byte(bucketName)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BackfillArrayLengths stores the array lengths of the string array indices
+// missing them by scanning the points. It must run in a write transaction
+// before any points are changed.
+func (im indexManager) BackfillArrayLengths(ctx context.Context) error {
+	completeBucket, err := im.bm.Get(arrayLengthsCompleteBucketName)
+	if err != nil {
+		return fmt.Errorf("could not get bucket %s: %w", arrayLengthsCompleteBucketName, err)
+	}
+	pointsBucket, err := im.bm.Get(pointstore.POINTSBUCKETNAME)
+	if err != nil {
+		return fmt.Errorf("could not get bucket %s: %w", pointstore.POINTSBUCKETNAME, err)
+	}
+	for propName, params := range im.indexSchema {
+		if params.Type != models.IndexTypeStringArray {
+			continue
+		}
+		bucketName := fmt.Sprintf("index/%s/%s", params.Type, propName)
+		if completeBucket.Get([]byte(bucketName)) != nil {
+			continue
+		}
+		// ---------------------------
+		lengths := make(map[uint64]int64)
+		dec := msgpack.NewDecoder(nil)
+		err := pointsBucket.PrefixScan([]byte("n"), func(k, v []byte) error {
+			nodeId, ok := conversion.NodeIdFromKey(k, 'd')
+			if !ok {
+				return nil
+			}
+			value, err := getPropertyFromBytes(dec, v, propName)
+			if err != nil {
+				return fmt.Errorf("could not get property %s of node %d: %w", propName, nodeId, err)
+			}
+			if array, ok := value.([]any); ok {
+				lengths[nodeId] = int64(len(array))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not scan points for array lengths: %w", err)
+		}
+		lb, err := im.lengthBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		if err := inverted.BackfillArrayLengths(ctx, lb, lengths); err != nil {
+			return err
+		}
+		if err := completeBucket.Put([]byte(bucketName), []byte{1}); err != nil {
+			return fmt.Errorf("could not mark array lengths of %s complete: %w", bucketName, err)
+		}
+	}
+	return nil
+}
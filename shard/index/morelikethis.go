@@ -0,0 +1,66 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/index/text"
+	"github.com/semafind/semadb/shard/pointstore"
+)
+
+// MoreLikeThisTerms returns the weighted terms that best describe the text of
+// the point, nil if the point is not in this shard.
+func (im indexManager) MoreLikeThisTerms(property string, pointId uuid.UUID, maxTerms int) ([]models.WeightedTerm, error) {
+	iparams, ok := im.indexSchema[property]
+	if !ok {
+		return nil, fmt.Errorf("property %s not found in index schema", property)
+	}
+	if iparams.Type != models.IndexTypeText {
+		return nil, fmt.Errorf("moreLikeThis not supported for property %s of type %s", property, iparams.Type)
+	}
+	nodeId, err := im.nodeIdByUUID(pointId)
+	if errors.Is(err, pointstore.ErrPointDoesNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// ---------------------------
+	bucketName := fmt.Sprintf("index/%s/%s", iparams.Type, property)
+	bucket, err := im.bm.Get(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bucket %s: %w", bucketName, err)
+	}
+	textIndex, err := text.NewIndexText(bucket, *iparams.Text)
+	if err != nil {
+		return nil, fmt.Errorf("could not create text index %s: %w", bucketName, err)
+	}
+	return textIndex.MoreLikeThisTerms(nodeId, maxTerms)
+}
+
+// sourceNodeId finds the node id of the moreLikeThis source point, nil if the
+// point is not in this shard.
+func (im indexManager) sourceNodeId(value string) (*uint64, error) {
+	pointId, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse point id %s: %w", value, err)
+	}
+	nodeId, err := im.nodeIdByUUID(pointId)
+	if errors.Is(err, pointstore.ErrPointDoesNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nodeId, nil
+}
+
+func (im indexManager) nodeIdByUUID(pointId uuid.UUID) (uint64, error) {
+	pointsBucket, err := im.bm.Get(pointstore.POINTSBUCKETNAME)
+	if err != nil {
+		return 0, fmt.Errorf("could not read bucket %s: %w", pointstore.POINTSBUCKETNAME, err)
+	}
+	return pointstore.GetPointNodeIdByUUID(pointsBucket, pointId)
+}
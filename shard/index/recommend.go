@@ -0,0 +1,153 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/google/uuid"
+	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/cache"
+	"github.com/semafind/semadb/shard/index/flat"
+	"github.com/semafind/semadb/shard/index/vamana"
+	"github.com/semafind/semadb/shard/pointstore"
+)
+
+// Both vamana and flat indices can give back the stored vectors of points
+type vectorIndex interface {
+	UpdateBucket(bucket diskstore.Bucket)
+	GetVector(id uint64) ([]float32, error)
+}
+
+// RecommendVectors returns the stored vectors of the example points of a
+// vector property. Points that are not in this shard or do not have the
+// vector are left out.
+func (im indexManager) RecommendVectors(property string, pointIds []uuid.UUID) (map[uuid.UUID][]float32, error) {
+	iparams, ok := im.indexSchema[property]
+	if !ok {
+		return nil, fmt.Errorf("property %s not found in index schema", property)
+	}
+	bucketName := fmt.Sprintf("index/%s/%s", iparams.Type, property)
+	bucket, err := im.bm.Get(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bucket %s: %w", bucketName, err)
+	}
+	cacheName := im.cacheRoot + "/" + bucketName
+	// ---------------------------
+	var newIndexFn func() (cache.Cachable, error)
+	switch iparams.Type {
+	case models.IndexTypeVectorVamana:
+		newIndexFn = func() (cache.Cachable, error) {
+			return vamana.NewIndexVamana(cacheName, *iparams.VectorVamana, bucket)
+		}
+	case models.IndexTypeVectorFlat:
+		newIndexFn = func() (cache.Cachable, error) {
+			return flat.NewIndexFlat(*iparams.VectorFlat, bucket)
+		}
+	default:
+		return nil, fmt.Errorf("recommend not supported for property %s of type %s", property, iparams.Type)
+	}
+	// ---------------------------
+	vectors := make(map[uuid.UUID][]float32, len(pointIds))
+	err = im.cx.With(cacheName, true, newIndexFn, func(cached cache.Cachable) error {
+		vIndex := cached.(vectorIndex)
+		vIndex.UpdateBucket(bucket)
+		for _, pointId := range pointIds {
+			nodeId, err := im.nodeIdByUUID(pointId)
+			if errors.Is(err, pointstore.ErrPointDoesNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			vector, err := vIndex.GetVector(nodeId)
+			if errors.Is(err, cache.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("could not get vector of point %s: %w", pointId, err)
+			}
+			vectors[pointId] = vector
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read vectors from %s: %w", bucketName, err)
+	}
+	return vectors, nil
+}
+
+// recommendExamples finds the node ids of the example points in this shard so
+// they can be left out of the results.
+func (im indexManager) recommendExamples(options *models.SearchRecommendOptions) (*roaring64.Bitmap, error) {
+	examples := roaring64.New()
+	if options == nil {
+		return examples, nil
+	}
+	for _, value := range slices.Concat(options.Positive, options.Negative) {
+		nodeId, err := im.sourceNodeId(value)
+		if err != nil {
+			return nil, err
+		}
+		if nodeId != nil {
+			examples.Add(*nodeId)
+		}
+	}
+	return examples, nil
+}
+
+// localRecommendVectors fills in the example vectors from this shard. The
+// cluster looks them up beforehand because the examples may be in other
+// shards, if they are missing the examples are expected to be here.
+func (im indexManager) localRecommendVectors(property string, options *models.SearchRecommendOptions) (*models.SearchRecommendOptions, error) {
+	if options == nil || options.PositiveVectors != nil {
+		return options, nil
+	}
+	pointIds := make([]uuid.UUID, 0, len(options.Positive)+len(options.Negative))
+	for _, value := range slices.Concat(options.Positive, options.Negative) {
+		pointId, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse point id %s: %w", value, err)
+		}
+		pointIds = append(pointIds, pointId)
+	}
+	vectors, err := im.RecommendVectors(property, pointIds)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *options
+	resolved.PositiveVectors = make([][]float32, 0, len(options.Positive))
+	resolved.NegativeVectors = make([][]float32, 0, len(options.Negative))
+	for i, pointId := range pointIds {
+		vector, ok := vectors[pointId]
+		if !ok {
+			continue
+		}
+		if i < len(options.Positive) {
+			resolved.PositiveVectors = append(resolved.PositiveVectors, vector)
+		} else {
+			resolved.NegativeVectors = append(resolved.NegativeVectors, vector)
+		}
+	}
+	return &resolved, nil
+}
+
+// excludeExamples removes the examples from the recommendation results which
+// are searched with a limit that accounts for them.
+func excludeExamples(examples *roaring64.Bitmap, results []models.SearchResult, limit int) (*roaring64.Bitmap, []models.SearchResult) {
+	rSet := roaring64.New()
+	filtered := make([]models.SearchResult, 0, min(len(results), limit))
+	for _, r := range results {
+		if len(filtered) >= limit {
+			break
+		}
+		if examples.Contains(r.NodeId) {
+			continue
+		}
+		filtered = append(filtered, r)
+		rSet.Add(r.NodeId)
+	}
+	return rSet, filtered
+}
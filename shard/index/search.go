@@ -4,8 +4,11 @@ import (
 	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"math"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/RoaringBitmap/roaring/roaring64"
 	"github.com/google/uuid"
@@ -16,11 +19,101 @@ import (
 	"github.com/semafind/semadb/shard/index/text"
 	"github.com/semafind/semadb/shard/index/vamana"
 	"github.com/semafind/semadb/shard/pointstore"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
+type synonymsContextKey struct{}
+
+// WithSynonyms attaches the synonym sets of the collection to the search
+// context, text searches then also match the synonyms of query terms.
+func WithSynonyms(ctx context.Context, synonyms [][]string) context.Context {
+	return context.WithValue(ctx, synonymsContextKey{}, synonyms)
+}
+
+type explainContextKey struct{}
+
+// WithExplain marks the search context so that the results of vector and text
+// queries carry an explanation of their scores.
+func WithExplain(ctx context.Context) context.Context {
+	return context.WithValue(ctx, explainContextKey{}, true)
+}
+
+// explain attaches the query that found each result. At this point the hybrid
+// score of a result is entirely the contribution of the query.
+func explain(ctx context.Context, property string, weight *float32, results []models.SearchResult) {
+	if explaining, _ := ctx.Value(explainContextKey{}).(bool); !explaining {
+		return
+	}
+	w := float32(1)
+	if weight != nil {
+		w = *weight
+	}
+	for i, r := range results {
+		results[i].Explanation = &models.SearchExplanation{
+			Queries: []models.QueryExplanation{{
+				Property:     property,
+				Distance:     r.Distance,
+				Score:        r.Score,
+				Weight:       w,
+				Contribution: r.HybridScore,
+			}},
+		}
+	}
+}
+
+type countContextKey struct{}
+
+// WithCount marks the search context as finding all the matching points, for
+// example to count or delete them. Text queries then match all their points
+// instead of the top limit.
+func WithCount(ctx context.Context) context.Context {
+	return context.WithValue(ctx, countContextKey{}, true)
+}
+
+type profileContextKey struct{}
+
+// WithProfile makes the search record the work done for the query into the
+// profile. Sub queries and filters are recorded under it, mirroring the query.
+func WithProfile(ctx context.Context, profile *models.QueryProfile) context.Context {
+	return context.WithValue(ctx, profileContextKey{}, profile)
+}
+
+// filterContext returns the context to search the filter of the query being
+// profiled with, so that the filter is recorded as part of the query.
+func filterContext(ctx context.Context) context.Context {
+	profile, ok := ctx.Value(profileContextKey{}).(*models.QueryProfile)
+	if !ok {
+		return ctx
+	}
+	profile.Filter = &models.QueryProfile{}
+	return WithProfile(ctx, profile.Filter)
+}
+
 func (im indexManager) Search(
 	ctx context.Context,
 	q models.Query,
+) (*roaring64.Bitmap, []models.SearchResult, error) {
+	profile, ok := ctx.Value(profileContextKey{}).(*models.QueryProfile)
+	if !ok {
+		return im.search(ctx, q)
+	}
+	startTime := time.Now()
+	profile.Property = q.Property
+	if q.VectorVamana != nil {
+		ctx = vamana.WithNodesVisited(ctx, &profile.NodesVisited)
+	}
+	rSet, results, err := im.search(ctx, q)
+	profile.DurationMs = time.Since(startTime).Seconds() * 1000
+	if rSet != nil {
+		profile.Cardinality = rSet.GetCardinality()
+	}
+	profile.Results = len(results)
+	return rSet, results, err
+}
+
+func (im indexManager) search(
+	ctx context.Context,
+	q models.Query,
 ) (*roaring64.Bitmap, []models.SearchResult, error) {
 	// ---------------------------
 	// We will dispatch each query to the appropriate index in parallel
@@ -58,12 +151,28 @@ func (im indexManager) Search(
 		// This has to be computed prior to the search so cannot be done in parallel
 		var filter *roaring64.Bitmap
 		if q.VectorVamana.Filter != nil {
-			filter, _, err = im.Search(ctx, *q.VectorVamana.Filter)
+			filter, _, err = im.Search(filterContext(ctx), *q.VectorVamana.Filter)
 			if err != nil {
 				return nil, nil, fmt.Errorf("could not search filter: %w", err)
 			}
 		}
 		// ---------------------------
+		/* The examples of a recommendation are closest to themselves, so we
+		 * search for more points to make room for excluding them. */
+		vamanaQuery := *q.VectorVamana
+		var examples *roaring64.Bitmap
+		if vamanaQuery.Operator == models.OperatorRecommend {
+			examples, err = im.recommendExamples(vamanaQuery.Recommend)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not find recommend examples: %w", err)
+			}
+			vamanaQuery.Limit += int(examples.GetCardinality())
+			vamanaQuery.Recommend, err = im.localRecommendVectors(q.Property, vamanaQuery.Recommend)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not get recommend vectors: %w", err)
+			}
+		}
+		// ---------------------------
 		var vamanaSet *roaring64.Bitmap
 		var vamanaRes []models.SearchResult
 		newVamanaFn := func() (cache.Cachable, error) {
@@ -72,7 +181,7 @@ func (im indexManager) Search(
 		err := im.cx.With(cacheName, true, newVamanaFn, func(cached cache.Cachable) error {
 			vamanaIndex := cached.(*vamana.IndexVamana)
 			vamanaIndex.UpdateBucket(bucket)
-			resSet, res, err := vamanaIndex.Search(ctx, *q.VectorVamana, filter)
+			resSet, res, err := vamanaIndex.Search(ctx, vamanaQuery, filter)
 			if err != nil {
 				return fmt.Errorf("could not perform vamana search %s: %w", bucketName, err)
 			}
@@ -83,7 +192,11 @@ func (im indexManager) Search(
 		if err != nil {
 			return nil, nil, fmt.Errorf("could not search %s: %w", bucketName, err)
 		}
+		if examples != nil {
+			vamanaSet, vamanaRes = excludeExamples(examples, vamanaRes, q.VectorVamana.Limit)
+		}
 		// ---------------------------
+		explain(ctx, q.Property, q.VectorVamana.Weight, vamanaRes)
 		return vamanaSet, vamanaRes, nil
 	case models.IndexTypeVectorFlat:
 		if q.VectorFlat == nil {
@@ -92,12 +205,28 @@ func (im indexManager) Search(
 		// ---------------------------
 		var filter *roaring64.Bitmap
 		if q.VectorFlat.Filter != nil {
-			filter, _, err = im.Search(ctx, *q.VectorFlat.Filter)
+			filter, _, err = im.Search(filterContext(ctx), *q.VectorFlat.Filter)
 			if err != nil {
 				return nil, nil, fmt.Errorf("could not search filter: %w", err)
 			}
 		}
 		// ---------------------------
+		/* The examples of a recommendation are closest to themselves, so we
+		 * search for more points to make room for excluding them. */
+		flatQuery := *q.VectorFlat
+		var examples *roaring64.Bitmap
+		if flatQuery.Operator == models.OperatorRecommend {
+			examples, err = im.recommendExamples(flatQuery.Recommend)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not find recommend examples: %w", err)
+			}
+			flatQuery.Limit += int(examples.GetCardinality())
+			flatQuery.Recommend, err = im.localRecommendVectors(q.Property, flatQuery.Recommend)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not get recommend vectors: %w", err)
+			}
+		}
+		// ---------------------------
 		var flatSet *roaring64.Bitmap
 		var flatRes []models.SearchResult
 		newFlatFn := func() (cache.Cachable, error) {
@@ -106,7 +235,7 @@ func (im indexManager) Search(
 		err := im.cx.With(cacheName, true, newFlatFn, func(cached cache.Cachable) error {
 			flatIndex := cached.(flat.IndexFlat)
 			flatIndex.UpdateBucket(bucket)
-			resSet, res, err := flatIndex.Search(ctx, *q.VectorFlat, filter)
+			resSet, res, err := flatIndex.Search(ctx, flatQuery, filter)
 			if err != nil {
 				return fmt.Errorf("could not perform flat search %s: %w", bucketName, err)
 			}
@@ -117,7 +246,11 @@ func (im indexManager) Search(
 		if err != nil {
 			return nil, nil, fmt.Errorf("could not search %s: %w", bucketName, err)
 		}
+		if examples != nil {
+			flatSet, flatRes = excludeExamples(examples, flatRes, q.VectorFlat.Limit)
+		}
 		// ---------------------------
+		explain(ctx, q.Property, q.VectorFlat.Weight, flatRes)
 		return flatSet, flatRes, nil
 	case models.IndexTypeText:
 		if q.Text == nil {
@@ -125,7 +258,7 @@ func (im indexManager) Search(
 		}
 		var filter *roaring64.Bitmap
 		if q.Text.Filter != nil {
-			filter, _, err = im.Search(ctx, *q.Text.Filter)
+			filter, _, err = im.Search(filterContext(ctx), *q.Text.Filter)
 			if err != nil {
 				return nil, nil, fmt.Errorf("could not search filter: %w", err)
 			}
@@ -134,19 +267,75 @@ func (im indexManager) Search(
 		if err != nil {
 			return nil, nil, fmt.Errorf("could not create text index %s: %w", bucketName, err)
 		}
-		return textIndex.Search(*q.Text, filter)
+		if synonyms, ok := ctx.Value(synonymsContextKey{}).([][]string); ok && len(synonyms) > 0 {
+			if err := textIndex.SetSynonyms(synonyms); err != nil {
+				return nil, nil, fmt.Errorf("could not set synonyms %s: %w", bucketName, err)
+			}
+		}
+		textOptions := *q.Text
+		if counting, _ := ctx.Value(countContextKey{}).(bool); counting {
+			textOptions.Limit = math.MaxInt32
+			textOptions.Highlight = nil
+		}
+		var textSet *roaring64.Bitmap
+		var textRes []models.SearchResult
+		if q.Text.Operator == models.OperatorMoreLikeThis {
+			/* The cluster looks up the terms of the source point beforehand
+			 * because it may be in another shard. If they are missing, the
+			 * point is expected to be here. */
+			sourceId, err := im.sourceNodeId(q.Text.Value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not find moreLikeThis point: %w", err)
+			}
+			terms := q.Text.Terms
+			if terms == nil && sourceId != nil {
+				terms, err = textIndex.MoreLikeThisTerms(*sourceId, q.Text.MaxTerms)
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not get moreLikeThis terms %s: %w", bucketName, err)
+				}
+			}
+			textSet, textRes, err = textIndex.MoreLikeThis(terms, sourceId, textOptions, filter)
+		} else {
+			textSet, textRes, err = textIndex.Search(textOptions, filter)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not perform text search %s: %w", bucketName, err)
+		}
+		if textOptions.Highlight != nil {
+			highlighter, err := textIndex.NewHighlighter(textOptions)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not create highlighter %s: %w", bucketName, err)
+			}
+			if err := im.highlight(q.Property, highlighter, textRes); err != nil {
+				return nil, nil, fmt.Errorf("could not highlight %s: %w", bucketName, err)
+			}
+		}
+		explain(ctx, q.Property, q.Text.Weight, textRes)
+		return textSet, textRes, nil
 	case models.IndexTypeString:
 		if q.String == nil {
 			return nil, nil, fmt.Errorf("no string query options for property %s", q.Property)
 		}
-		stringIndex := inverted.NewIndexInvertedString(bucket, *iparams.String)
+		ngramBucket, err := im.ngramBucket(bucketName, *iparams.String)
+		if err != nil {
+			return nil, nil, err
+		}
+		stringIndex := inverted.NewIndexInvertedString(bucket, ngramBucket, *iparams.String)
 		rSet, err := stringIndex.Search(*q.String)
 		return rSet, nil, err
 	case models.IndexTypeStringArray:
 		if q.StringArray == nil {
 			return nil, nil, fmt.Errorf("no stringArray query options for property %s", q.Property)
 		}
-		stringArrayIndex := inverted.NewIndexInvertedArrayString(bucket, *iparams.StringArray)
+		ngramBucket, err := im.ngramBucket(bucketName, iparams.StringArray.IndexStringParameters)
+		if err != nil {
+			return nil, nil, err
+		}
+		lengthBucket, err := im.lengthBucket(bucketName)
+		if err != nil {
+			return nil, nil, err
+		}
+		stringArrayIndex := inverted.NewIndexInvertedArrayString(bucket, ngramBucket, lengthBucket, *iparams.StringArray)
 		rSet, err := stringArrayIndex.Search(*q.StringArray)
 		return rSet, nil, err
 	case models.IndexTypeInteger:
@@ -154,6 +343,10 @@ func (im indexManager) Search(
 			return nil, nil, fmt.Errorf("no integer query options for property %s", q.Property)
 		}
 		integerIndex := inverted.NewIndexInverted[int64](bucket)
+		if q.Integer.Operator == models.OperatorIn || q.Integer.Operator == models.OperatorNotIn {
+			rSet, err := integerIndex.SearchSet(q.Integer.Values, q.Integer.Operator)
+			return rSet, nil, err
+		}
 		rSet, err := integerIndex.Search(q.Integer.Value, q.Integer.EndValue, q.Integer.Operator)
 		return rSet, nil, err
 	case models.IndexTypeFloat:
@@ -161,6 +354,10 @@ func (im indexManager) Search(
 			return nil, nil, fmt.Errorf("no float query options for property %s", q.Property)
 		}
 		floatIndex := inverted.NewIndexInverted[float64](bucket)
+		if q.Float.Operator == models.OperatorIn || q.Float.Operator == models.OperatorNotIn {
+			rSet, err := floatIndex.SearchSet(q.Float.Values, q.Float.Operator)
+			return rSet, nil, err
+		}
 		rSet, err := floatIndex.Search(q.Float.Value, q.Float.EndValue, q.Float.Operator)
 		return rSet, nil, err
 	default:
@@ -168,6 +365,36 @@ func (im indexManager) Search(
 	}
 }
 
+// highlight fills in the matched fragments of the property for each result. The
+// text index doesn't keep the original text so it is read from the points.
+func (im indexManager) highlight(property string, highlighter *text.Highlighter, results []models.SearchResult) error {
+	pointsBucket, err := im.bm.Get(pointstore.POINTSBUCKETNAME)
+	if err != nil {
+		return fmt.Errorf("could not read bucket %s for highlighting: %w", pointstore.POINTSBUCKETNAME, err)
+	}
+	dec := msgpack.NewDecoder(nil)
+	for i, r := range results {
+		sp, err := pointstore.GetPointByNodeId(pointsBucket, r.NodeId, true)
+		if err != nil {
+			return fmt.Errorf("could not get point by node id %d: %w", r.NodeId, err)
+		}
+		value, err := getPropertyFromBytes(dec, sp.Data, property)
+		if err != nil {
+			return fmt.Errorf("could not get property %s: %w", property, err)
+		}
+		textValue, ok := value.(string)
+		if !ok {
+			continue
+		}
+		fragments, err := highlighter.Highlight(textValue)
+		if err != nil {
+			return fmt.Errorf("could not highlight point %d: %w", r.NodeId, err)
+		}
+		results[i].Highlights = map[string][]string{property: fragments}
+	}
+	return nil
+}
+
 func (im indexManager) searchById(q models.Query) (*roaring64.Bitmap, []models.SearchResult, error) {
 	/* What we have here is a special case where we can directly return the node
 	 * id based on the passed _id query which is the UUID of the point(s). */
@@ -219,13 +446,22 @@ func (im indexManager) searchParallel(
 	sets := make([]*roaring64.Bitmap, len(queries))
 	results := make([][]models.SearchResult, len(queries))
 	var wg sync.WaitGroup
+	// Each query records into its own profile so there is no contention
+	profile, profiling := ctx.Value(profileContextKey{}).(*models.QueryProfile)
+	if profiling {
+		profile.Queries = make([]models.QueryProfile, len(queries))
+	}
 	// ---------------------------
 	// We will dispatch each query to the appropriate index in parallel
 	for i, q := range queries {
 		wg.Add(1)
 		go func(i int, q models.Query) {
 			defer wg.Done()
-			set, res, err := im.Search(ctx, q)
+			queryCtx := ctx
+			if profiling {
+				queryCtx = WithProfile(ctx, &profile.Queries[i])
+			}
+			set, res, err := im.Search(queryCtx, q)
 			if err != nil {
 				cancel(err)
 				return
@@ -278,13 +514,26 @@ func (im indexManager) searchParallel(
 				 * future we might need to keep track of where these scores come
 				 * from etc. For example if you did a hybrid search of more than
 				 * 2 items, the distance and the score will be from the first
-				 * search. */
+				 * search. Explained searches keep every query below. */
 				if finalResults[idx].Distance == nil && r.Distance != nil {
 					finalResults[idx].Distance = r.Distance
 				}
 				if finalResults[idx].Score == nil && r.Score != nil {
 					finalResults[idx].Score = r.Score
 				}
+				if r.Explanation != nil {
+					if finalResults[idx].Explanation == nil {
+						finalResults[idx].Explanation = &models.SearchExplanation{}
+					}
+					finalResults[idx].Explanation.Queries = append(finalResults[idx].Explanation.Queries, r.Explanation.Queries...)
+				}
+				// Highlights of different properties are kept together
+				if len(r.Highlights) > 0 {
+					if finalResults[idx].Highlights == nil {
+						finalResults[idx].Highlights = make(map[string][]string, len(r.Highlights))
+					}
+					maps.Copy(finalResults[idx].Highlights, r.Highlights)
+				}
 			}
 		}
 	}
@@ -13,6 +13,7 @@ import (
 	"github.com/semafind/semadb/shard/pointstore"
 	"github.com/semafind/semadb/utils"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 /*
@@ -143,6 +144,196 @@ func TestSearch_Single(t *testing.T) {
 	}
 }
 
+func TestSearch_StringPattern(t *testing.T) {
+	store, _ := diskstore.Open("")
+	cacheM := cache.NewManager(-1)
+	populateIndex(t, store, cacheM)
+	// ---------------------------
+	// The category index has n-grams, the labels are scanned
+	tests := []struct {
+		query    models.Query
+		expected []uint64
+	}{
+		{
+			query: models.Query{
+				Property: "category",
+				String: &models.SearchStringOptions{
+					Value:    "ORY 42",
+					Operator: models.OperatorContains,
+				},
+			},
+			expected: []uint64{42},
+		},
+		{
+			query: models.Query{
+				Property: "category",
+				String: &models.SearchStringOptions{
+					Value:    "^category 4[0-4]$",
+					Operator: models.OperatorRegex,
+				},
+			},
+			expected: []uint64{40, 41, 42, 43, 44},
+		},
+		{
+			query: models.Query{
+				Property: "labels",
+				StringArray: &models.SearchStringArrayOptions{
+					Value:    []string{" 43"},
+					Operator: models.OperatorEndsWith,
+				},
+			},
+			expected: []uint64{42, 43},
+		},
+	}
+	for _, tt := range tests {
+		rSet, _ := performSearch(t, store, cacheM, tt.query)
+		require.ElementsMatch(t, tt.expected, rSet.ToArray())
+	}
+}
+
+func TestSearch_Set(t *testing.T) {
+	store, _ := diskstore.Open("")
+	cacheM := cache.NewManager(-1)
+	populateIndex(t, store, cacheM)
+	// ---------------------------
+	rSet, _ := performSearch(t, store, cacheM, models.Query{
+		Property: "size",
+		Integer: &models.SearchIntegerOptions{
+			Values:   []int64{3, 42, 9000},
+			Operator: models.OperatorIn,
+		},
+	})
+	require.ElementsMatch(t, []uint64{3, 42}, rSet.ToArray())
+	rSet, _ = performSearch(t, store, cacheM, models.Query{
+		Property: "price",
+		Float: &models.SearchFloatOptions{
+			Values:   []float64{2.5, 3.5},
+			Operator: models.OperatorNotIn,
+		},
+	})
+	require.EqualValues(t, 98, rSet.GetCardinality())
+	require.False(t, rSet.Contains(2))
+	rSet, _ = performSearch(t, store, cacheM, models.Query{
+		Property: "category",
+		String: &models.SearchStringOptions{
+			Values:   []string{"Category 7", "category 8"},
+			Operator: models.OperatorIn,
+		},
+	})
+	require.ElementsMatch(t, []uint64{7, 8}, rSet.ToArray())
+}
+
+func TestSearch_ArrayNoneSize(t *testing.T) {
+	store, _ := diskstore.Open("")
+	cacheM := cache.NewManager(-1)
+	populateIndex(t, store, cacheM)
+	// ---------------------------
+	rSet, _ := performSearch(t, store, cacheM, models.Query{
+		Property: "labels",
+		StringArray: &models.SearchStringArrayOptions{
+			Value:    []string{"label1 42", "label2 42"},
+			Operator: models.OperatorContainsNone,
+		},
+	})
+	require.EqualValues(t, 98, rSet.GetCardinality())
+	require.False(t, rSet.Contains(41))
+	require.False(t, rSet.Contains(42))
+	// Every point has two labels
+	rSet, _ = performSearch(t, store, cacheM, models.Query{
+		Property: "labels",
+		StringArray: &models.SearchStringArrayOptions{
+			Size:     2,
+			Operator: models.OperatorSizeEquals,
+		},
+	})
+	require.EqualValues(t, 100, rSet.GetCardinality())
+	rSet, _ = performSearch(t, store, cacheM, models.Query{
+		Property: "labels",
+		StringArray: &models.SearchStringArrayOptions{
+			Size:     2,
+			Operator: models.OperatorSizeGreaterThan,
+		},
+	})
+	require.True(t, rSet.IsEmpty())
+}
+
+func TestSearch_ArrayLengthBackfill(t *testing.T) {
+	store, _ := diskstore.Open("")
+	cacheM := cache.NewManager(-1)
+	populateIndex(t, store, cacheM)
+	// Drop the lengths as if the index predates them and store the points
+	// they are backfilled from, including one with an empty array which
+	// leaves no values in the index
+	points := randPoints(100, 0)
+	emptyData, err := msgpack.Marshal(models.PointAsMap{"labels": []string{}})
+	require.NoError(t, err)
+	points = append(points, index.IndexPointChange{NodeId: 500, NewData: emptyData})
+	err = store.Write(func(bm diskstore.BucketManager) error {
+		if err := bm.Delete("length/index/stringArray/labels"); err != nil {
+			return err
+		}
+		pointsBucket, err := bm.Get(pointstore.POINTSBUCKETNAME)
+		if err != nil {
+			return err
+		}
+		for _, p := range points {
+			sp := pointstore.ShardPoint{
+				Point:  models.Point{Id: uuid.New(), Data: p.NewData},
+				NodeId: p.NodeId,
+			}
+			if err := pointstore.SetPoint(pointsBucket, sp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	// ---------------------------
+	checkMissing := func(expected bool) {
+		err := store.Read(func(bm diskstore.BucketManager) error {
+			im := index.NewIndexManager(bm, nil, "cache", sampleIndexSchema)
+			missing, err := im.ArrayLengthsMissing()
+			require.Equal(t, expected, missing)
+			return err
+		})
+		require.NoError(t, err)
+	}
+	checkMissing(true)
+	err = store.Write(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, nil, "cache", sampleIndexSchema)
+		return im.BackfillArrayLengths(context.Background())
+	})
+	require.NoError(t, err)
+	checkMissing(false)
+	// ---------------------------
+	rSet, _ := performSearch(t, store, cacheM, models.Query{
+		Property: "labels",
+		StringArray: &models.SearchStringArrayOptions{
+			Value:    []string{"label1 42"},
+			Operator: models.OperatorContainsNone,
+		},
+	})
+	require.EqualValues(t, 100, rSet.GetCardinality())
+	require.False(t, rSet.Contains(42))
+	require.True(t, rSet.Contains(500))
+	rSet, _ = performSearch(t, store, cacheM, models.Query{
+		Property: "labels",
+		StringArray: &models.SearchStringArrayOptions{
+			Size:     2,
+			Operator: models.OperatorSizeEquals,
+		},
+	})
+	require.EqualValues(t, 100, rSet.GetCardinality())
+	rSet, _ = performSearch(t, store, cacheM, models.Query{
+		Property: "labels",
+		StringArray: &models.SearchStringArrayOptions{
+			Size:     0,
+			Operator: models.OperatorSizeEquals,
+		},
+	})
+	require.Equal(t, []uint64{500}, rSet.ToArray())
+}
+
 func TestSearch_ById(t *testing.T) {
 	store, _ := diskstore.Open("")
 	cacheM := cache.NewManager(-1)
@@ -455,3 +646,55 @@ func TestSearch_OrVector(t *testing.T) {
 	}
 	require.Equal(t, uint64(42), results[0].NodeId)
 }
+
+func TestSearch_Highlight(t *testing.T) {
+	store, _ := diskstore.Open("")
+	cacheM := cache.NewManager(-1)
+	populateIndex(t, store, cacheM)
+	// ---------------------------
+	// The original text is read from the points bucket
+	err := store.Write(func(bm diskstore.BucketManager) error {
+		b, err := bm.Get(pointstore.POINTSBUCKETNAME)
+		require.NoError(t, err)
+		for _, p := range randPoints(100, 0) {
+			err = pointstore.SetPoint(b, pointstore.ShardPoint{
+				Point: models.Point{
+					Id:   uuid.New(),
+					Data: p.NewData,
+				},
+				NodeId: p.NodeId,
+			})
+			require.NoError(t, err)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	// ---------------------------
+	q := models.Query{
+		Property: "_and",
+		And: []models.Query{
+			{
+				Property: "description",
+				Text: &models.SearchTextOptions{
+					Value:     "description 42",
+					Operator:  models.OperatorContainsAll,
+					Limit:     5,
+					Highlight: &models.SearchTextHighlight{},
+				},
+			},
+			{
+				Property: "size",
+				Integer: &models.SearchIntegerOptions{
+					Value:    42,
+					Operator: models.OperatorEquals,
+				},
+			},
+		},
+	}
+	rSet, results := performSearch(t, store, cacheM, q)
+	require.EqualValues(t, 1, rSet.GetCardinality())
+	require.Len(t, results, 1)
+	require.Equal(t, map[string][]string{
+		"description": {"This is a <em>description</em> <em>42</em>"},
+	}, results[0].Highlights)
+}
@@ -0,0 +1,44 @@
+package index
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/index/text"
+)
+
+// Suggest completes the prefix with the most frequent terms of a text index,
+// optionally counting only the points that match the filter query.
+func (im indexManager) Suggest(ctx context.Context, req models.SuggestRequest) ([]models.Suggestion, error) {
+	iparams, ok := im.indexSchema[req.Property]
+	if !ok {
+		return nil, fmt.Errorf("property %s not found in index schema", req.Property)
+	}
+	if iparams.Type != models.IndexTypeText {
+		return nil, fmt.Errorf("suggest not supported for property %s of type %s", req.Property, iparams.Type)
+	}
+	// ---------------------------
+	bucketName := fmt.Sprintf("index/%s/%s", iparams.Type, req.Property)
+	bucket, err := im.bm.Get(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bucket %s: %w", bucketName, err)
+	}
+	var filter *roaring64.Bitmap
+	if req.Filter != nil {
+		filter, _, err = im.Search(ctx, *req.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("could not search filter: %w", err)
+		}
+	}
+	textIndex, err := text.NewIndexText(bucket, *iparams.Text)
+	if err != nil {
+		return nil, fmt.Errorf("could not create text index %s: %w", bucketName, err)
+	}
+	suggestions, err := textIndex.Suggest(req.Prefix, filter, req.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not suggest %s: %w", bucketName, err)
+	}
+	return suggestions, nil
+}
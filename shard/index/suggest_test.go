@@ -0,0 +1,70 @@
+package index_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/cache"
+	"github.com/semafind/semadb/shard/index"
+	"github.com/stretchr/testify/require"
+)
+
+func performSuggest(t *testing.T, ds diskstore.DiskStore, cacheM *cache.Manager, req models.SuggestRequest) ([]models.Suggestion, error) {
+	t.Helper()
+	var suggestions []models.Suggestion
+	err := ds.Read(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, cacheM.NewTransaction(), "cache", sampleIndexSchema)
+		var err error
+		suggestions, err = im.Suggest(context.Background(), req)
+		return err
+	})
+	return suggestions, err
+}
+
+func TestSuggest(t *testing.T) {
+	store, _ := diskstore.Open("")
+	cacheM := cache.NewManager(-1)
+	populateIndex(t, store, cacheM)
+	// ---------------------------
+	// Only the last word is completed and case doesn't matter
+	req := models.SuggestRequest{
+		Property: "description",
+		Prefix:   "This is a DESC",
+		Limit:    5,
+	}
+	suggestions, err := performSuggest(t, store, cacheM, req)
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{{Term: "description", DocumentCount: 100}}, suggestions)
+	// ---------------------------
+	req.Prefix = "4"
+	req.Filter = &models.Query{
+		Property: "size",
+		Integer: &models.SearchIntegerOptions{
+			Value:    45,
+			EndValue: 100,
+			Operator: models.OperatorInRange,
+		},
+	}
+	suggestions, err = performSuggest(t, store, cacheM, req)
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{
+		{Term: "45", DocumentCount: 1},
+		{Term: "46", DocumentCount: 1},
+		{Term: "47", DocumentCount: 1},
+		{Term: "48", DocumentCount: 1},
+		{Term: "49", DocumentCount: 1},
+	}, suggestions)
+	// ---------------------------
+	// Stopwords can't be completed
+	req.Prefix = "the"
+	req.Filter = nil
+	suggestions, err = performSuggest(t, store, cacheM, req)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 0)
+	// ---------------------------
+	req.Property = "size"
+	_, err = performSuggest(t, store, cacheM, req)
+	require.Error(t, err)
+}
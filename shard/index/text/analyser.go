@@ -0,0 +1,191 @@
+package text
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ar"
+	"github.com/blevesearch/bleve/v2/analysis/lang/da"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/no"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	"github.com/blevesearch/bleve/v2/analysis/lang/tr"
+	"github.com/blevesearch/bleve/v2/analysis/token/camelcase"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	"github.com/blevesearch/bleve/v2/analysis/token/stop"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/letter"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/whitespace"
+	"github.com/blevesearch/bleve/v2/analysis/tokenmap"
+	"github.com/semafind/semadb/models"
+)
+
+// The bleve components of each supported language, the analyser names are the
+// language codes themselves.
+type languageComponents struct {
+	analyser string
+	stop     string
+	stemmer  string
+}
+
+var languages = map[string]languageComponents{
+	"ar": {ar.AnalyzerName, ar.StopName, ar.StemmerName},
+	"da": {da.AnalyzerName, da.StopName, da.SnowballStemmerName},
+	"de": {de.AnalyzerName, de.StopName, de.SnowballStemmerName},
+	"en": {en.AnalyzerName, en.StopName, en.SnowballStemmerName},
+	"es": {es.AnalyzerName, es.StopName, es.SnowballStemmerName},
+	"fi": {fi.AnalyzerName, fi.StopName, fi.SnowballStemmerName},
+	"fr": {fr.AnalyzerName, fr.StopName, fr.SnowballStemmerName},
+	"hi": {hi.AnalyzerName, hi.StopName, hi.StemmerName},
+	"hu": {hu.AnalyzerName, hu.StopName, hu.SnowballStemmerName},
+	"it": {it.AnalyzerName, it.StopName, it.SnowballStemmerName},
+	"nl": {nl.AnalyzerName, nl.StopName, nl.SnowballStemmerName},
+	"no": {no.AnalyzerName, no.StopName, no.SnowballStemmerName},
+	"pt": {pt.AnalyzerName, pt.StopName, pt.LightStemmerName},
+	"ro": {ro.AnalyzerName, ro.StopName, ro.SnowballStemmerName},
+	"ru": {ru.AnalyzerName, ru.StopName, ru.SnowballStemmerName},
+	"sv": {sv.AnalyzerName, sv.StopName, sv.SnowballStemmerName},
+	"tr": {tr.AnalyzerName, tr.StopName, tr.SnowballStemmerName},
+}
+
+var tokenizers = map[string]string{
+	"unicode":    unicode.Name,
+	"whitespace": whitespace.Name,
+	"letter":     letter.Name,
+	"single":     single.Name,
+}
+
+// analyserName returns the name of the analyser in the analyserCache registry,
+// custom analysers are defined on first use.
+func analyserName(params models.IndexTextParameters) (string, error) {
+	switch params.Analyser {
+	case models.TextAnalyserStandard:
+		return params.Analyser, nil
+	case models.TextAnalyserCustom:
+		if params.Custom == nil {
+			return "", fmt.Errorf("custom analyser parameters are required")
+		}
+		return defineCustomAnalyser(*params.Custom)
+	}
+	lang, ok := languages[params.Analyser]
+	if !ok {
+		return "", fmt.Errorf("unknown analyser %s", params.Analyser)
+	}
+	return lang.analyser, nil
+}
+
+// ---------------------------
+
+// Defining components in the registry is check then set, so we serialise it to
+// avoid two indices with the same custom analyser racing each other.
+var customAnalyserMu sync.Mutex
+
+/* defineCustomAnalyser registers the custom analyser in the analyserCache.
+ * The name is derived from the parameters so that indices with the same
+ * pipeline share the analyser and it is only defined once per process. */
+func defineCustomAnalyser(params models.TextAnalyserParameters) (string, error) {
+	paramBytes, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("error encoding custom analyser parameters: %w", err)
+	}
+	hash := sha256.Sum256(paramBytes)
+	name := "custom_" + hex.EncodeToString(hash[:8])
+	// ---------------------------
+	customAnalyserMu.Lock()
+	defer customAnalyserMu.Unlock()
+	if _, err := analyserCache.AnalyzerNamed(name); err == nil {
+		return name, nil
+	}
+	// ---------------------------
+	tokenizer, ok := tokenizers[params.Tokenizer]
+	if !ok {
+		return "", fmt.Errorf("unknown tokenizer %s", params.Tokenizer)
+	}
+	filters := make([]string, 0)
+	if params.SplitCamelCase {
+		filters = append(filters, camelcase.Name)
+	}
+	if params.Lowercase {
+		filters = append(filters, lowercase.Name)
+	}
+	if params.StopwordsLanguage != "" {
+		lang, ok := languages[params.StopwordsLanguage]
+		if !ok {
+			return "", fmt.Errorf("unknown stopwords language %s", params.StopwordsLanguage)
+		}
+		filters = append(filters, lang.stop)
+	}
+	if len(params.Stopwords) > 0 {
+		tokens := make([]interface{}, len(params.Stopwords))
+		for i, word := range params.Stopwords {
+			tokens[i] = word
+		}
+		mapName := name + "_stopwords"
+		_, err := analyserCache.DefineTokenMap(mapName, map[string]interface{}{
+			"type":   tokenmap.Name,
+			"tokens": tokens,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error defining stopwords: %w", err)
+		}
+		_, err = analyserCache.DefineTokenFilter(name+"_stop", map[string]interface{}{
+			"type":           stop.Name,
+			"stop_token_map": mapName,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error defining stopwords filter: %w", err)
+		}
+		filters = append(filters, name+"_stop")
+	}
+	if params.StemmerLanguage != "" {
+		lang, ok := languages[params.StemmerLanguage]
+		if !ok {
+			return "", fmt.Errorf("unknown stemmer language %s", params.StemmerLanguage)
+		}
+		filters = append(filters, lang.stemmer)
+	}
+	if params.NGram != nil {
+		ngramType := ngram.Name
+		if params.NGram.Edge {
+			ngramType = edgengram.Name
+		}
+		// The numbers are float64 as if they came from a JSON config
+		_, err := analyserCache.DefineTokenFilter(name+"_ngram", map[string]interface{}{
+			"type": ngramType,
+			"min":  float64(params.NGram.Min),
+			"max":  float64(params.NGram.Max),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error defining ngram filter: %w", err)
+		}
+		filters = append(filters, name+"_ngram")
+	}
+	// ---------------------------
+	_, err = analyserCache.DefineAnalyzer(name, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     tokenizer,
+		"token_filters": filters,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error defining custom analyser: %w", err)
+	}
+	return name, nil
+}
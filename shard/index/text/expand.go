@@ -0,0 +1,145 @@
+package text
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/semafind/semadb/models"
+)
+
+// The maximum number of index terms a single query term can expand to with
+// prefix or fuzzy matching. Short prefixes could otherwise match a large part
+// of the term dictionary.
+const maxExpansions = 50
+
+// expandedTerm is an index term that a query term matched, the weight
+// reduces the score of terms that are not an exact match.
+type expandedTerm struct {
+	term     string
+	distance int
+	weight   float32
+	synonym  bool
+	docFreq  uint64
+}
+
+// expandTerm finds the index terms that match the query term. The term keys in
+// the bucket act as the term dictionary so we don't need a separate structure,
+// they are scanned by prefix which is ordered on disk. Synonyms of the query
+// term are added at the end as exact matches, except for phrase and proximity
+// queries which need the actual words in order.
+func (index *indexText) expandTerm(queryTerm string, options models.SearchTextOptions) ([]expandedTerm, error) {
+	var terms []expandedTerm
+	switch options.Match {
+	case models.TextMatchPrefix:
+		scanned, err := index.scanTerms(queryTerm, func(term string) (int, bool) {
+			return 0, true
+		})
+		if err != nil {
+			return nil, err
+		}
+		terms = scanned
+	case models.TextMatchFuzzy:
+		/* We assume the first character is correct, this is a common
+		 * heuristic that avoids having to compare against every term in the
+		 * index. It also means very short terms don't match everything. */
+		first, _ := utf8.DecodeRuneInString(queryTerm)
+		scanned, err := index.scanTerms(string(first), func(term string) (int, bool) {
+			d := editDistance(queryTerm, term, options.Fuzziness)
+			return d, d <= options.Fuzziness
+		})
+		if err != nil {
+			return nil, err
+		}
+		terms = scanned
+	default:
+		terms = []expandedTerm{{term: queryTerm, weight: 1}}
+	}
+	// ---------------------------
+	if options.Operator == models.OperatorPhrase || options.Operator == models.OperatorProximity {
+		return terms, nil
+	}
+	for _, synonym := range index.synonyms[queryTerm] {
+		matched := slices.ContainsFunc(terms, func(et expandedTerm) bool {
+			return et.term == synonym
+		})
+		if !matched {
+			terms = append(terms, expandedTerm{term: synonym, weight: synonymWeight, synonym: true})
+		}
+	}
+	return terms, nil
+}
+
+// scanTerms goes through the terms in the index starting with the prefix and
+// keeps the ones accepted by the match function, closest ones first.
+func (index *indexText) scanTerms(prefix string, match func(term string) (int, bool)) ([]expandedTerm, error) {
+	terms := make([]expandedTerm, 0)
+	err := index.bucket.PrefixScan([]byte("t"+prefix), func(k, v []byte) error {
+		var si *setCacheItem
+		term, ok := si.IdFromKey(k)
+		if !ok {
+			return nil
+		}
+		if distance, ok := match(term); ok {
+			terms = append(terms, expandedTerm{term: term, distance: distance, weight: 1 / float32(1+distance)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error scanning terms: %w", err)
+	}
+	// The memory bucket is not ordered so we sort to be consistent
+	slices.SortFunc(terms, func(a, b expandedTerm) int {
+		if c := cmp.Compare(a.distance, b.distance); c != 0 {
+			return c
+		}
+		return strings.Compare(a.term, b.term)
+	})
+	if len(terms) > maxExpansions {
+		terms = terms[:maxExpansions]
+	}
+	return terms, nil
+}
+
+// editDistance computes the Levenshtein distance between two strings. It
+// gives up early and returns maxDistance+1 once the distance is known to
+// exceed maxDistance.
+func editDistance(a, b string, maxDistance int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > maxDistance {
+		return maxDistance + 1
+	}
+	// ---------------------------
+	// Standard dynamic programming with two rows
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > maxDistance {
+			return maxDistance + 1
+		}
+		prev, curr = curr, prev
+	}
+	return min(prev[len(rb)], maxDistance+1)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,166 @@
+package text
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/semafind/semadb/models"
+)
+
+// Default highlighting options, the tags are the same as many other search
+// engines so clients can style them with CSS.
+const (
+	defaultHighlightPreTag       = "<em>"
+	defaultHighlightPostTag      = "</em>"
+	defaultHighlightFragmentSize = 100
+	defaultHighlightMaxFragments = 3
+)
+
+// Highlighter marks the terms of a query in texts indexed by the same index.
+// It is created once per query so that the query is analysed and expanded only
+// once for all the results.
+type Highlighter struct {
+	analyser     analyser
+	terms        map[string]struct{}
+	preTag       string
+	postTag      string
+	fragmentSize int
+	maxFragments int
+}
+
+// NewHighlighter creates a highlighter for the query. The terms to highlight
+// are the index terms the query matches, so prefix and fuzzy matches are also
+// highlighted.
+func (index *indexText) NewHighlighter(options models.SearchTextOptions) (*Highlighter, error) {
+	h := &Highlighter{
+		analyser:     index.analyser,
+		terms:        make(map[string]struct{}),
+		preTag:       defaultHighlightPreTag,
+		postTag:      defaultHighlightPostTag,
+		fragmentSize: defaultHighlightFragmentSize,
+		maxFragments: defaultHighlightMaxFragments,
+	}
+	if hl := options.Highlight; hl != nil {
+		if hl.PreTag != "" {
+			h.preTag = hl.PreTag
+		}
+		if hl.PostTag != "" {
+			h.postTag = hl.PostTag
+		}
+		if hl.FragmentSize != 0 {
+			h.fragmentSize = hl.FragmentSize
+		}
+		if hl.MaxFragments != 0 {
+			h.maxFragments = hl.MaxFragments
+		}
+	}
+	// ---------------------------
+	tokens, err := index.analyser.Analyse(options.Value)
+	if err != nil {
+		return nil, fmt.Errorf("error analysing text: %w", err)
+	}
+	for _, token := range tokens {
+		expanded, err := index.expandTerm(token.Term, options)
+		if err != nil {
+			return nil, fmt.Errorf("error expanding term %s: %w", token.Term, err)
+		}
+		for _, et := range expanded {
+			h.terms[et.term] = struct{}{}
+		}
+	}
+	return h, nil
+}
+
+// span is a byte range of the original text that matched a term
+type span struct {
+	start int
+	end   int
+}
+
+// Highlight returns up to max fragments of the text in the order they appear.
+// Each fragment is around fragment size characters long, cut at word
+// boundaries where possible, with the matched terms wrapped in the tags. The
+// analyser token offsets are used so the fragments contain the original text.
+func (h *Highlighter) Highlight(text string) ([]string, error) {
+	tokens, err := h.analyser.Analyse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error analysing text: %w", err)
+	}
+	// ---------------------------
+	// Find the matching parts, n-gram filters may give overlapping offsets so
+	// we merge them.
+	spans := make([]span, 0)
+	for _, token := range tokens {
+		if _, ok := h.terms[token.Term]; ok {
+			spans = append(spans, span{start: token.Start, end: token.End})
+		}
+	}
+	slices.SortFunc(spans, func(a, b span) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	merged := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if last := len(merged) - 1; last >= 0 && s.start <= merged[last].end {
+			merged[last].end = max(merged[last].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	// ---------------------------
+	fragments := make([]string, 0, h.maxFragments)
+	for i := 0; i < len(merged) && len(fragments) < h.maxFragments; {
+		// Centre the fragment on the first span that isn't in a fragment yet
+		first := merged[i]
+		start := max(first.start-max(h.fragmentSize-(first.end-first.start), 0)/2, 0)
+		end := min(max(start+h.fragmentSize, first.end), len(text))
+		start = max(min(start, end-h.fragmentSize), 0)
+		// Include any other spans that fit
+		j := i + 1
+		for j < len(merged) && merged[j].end <= end {
+			j++
+		}
+		start, end = snapToWords(text, start, end, first.start, merged[j-1].end)
+		// ---------------------------
+		var sb strings.Builder
+		prev := start
+		for _, s := range merged[i:j] {
+			sb.WriteString(text[prev:s.start])
+			sb.WriteString(h.preTag)
+			sb.WriteString(text[s.start:s.end])
+			sb.WriteString(h.postTag)
+			prev = s.end
+		}
+		sb.WriteString(text[prev:end])
+		fragments = append(fragments, sb.String())
+		i = j
+	}
+	return fragments, nil
+}
+
+// snapToWords moves the fragment boundaries so that they don't cut words or
+// runes in half, without moving past the matched range given by from and to.
+func snapToWords(text string, start, end, from, to int) (int, int) {
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	if start > 0 {
+		if idx := strings.IndexFunc(text[start:from], unicode.IsSpace); idx != -1 {
+			start += idx
+		}
+		start += len(text[start:from]) - len(strings.TrimLeftFunc(text[start:from], unicode.IsSpace))
+	}
+	if end < len(text) {
+		if idx := strings.LastIndexFunc(text[to:end], unicode.IsSpace); idx != -1 {
+			end = to + idx
+		}
+		end = to + len(strings.TrimRightFunc(text[to:end], unicode.IsSpace))
+	}
+	return start, end
+}
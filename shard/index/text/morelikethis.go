@@ -0,0 +1,117 @@
+package text
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/cache"
+)
+
+// The default number of terms taken from the source document
+const defaultMoreLikeThisMaxTerms = 25
+
+// MoreLikeThisTerms picks the terms that best describe a document, i.e. those
+// frequent in it but rare in the index, scored the same way as search results.
+// The weights are relative to the best term which has weight 1. A document
+// without text in the index has no terms.
+func (index *indexText) MoreLikeThisTerms(nodeId uint64, maxTerms int) ([]models.WeightedTerm, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	// ---------------------------
+	docItem, err := index.docCache.Get(nodeId)
+	if errors.Is(err, cache.ErrNotFound) {
+		return []models.WeightedTerm{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting doc cache item: %w", err)
+	}
+	terms := make([]models.WeightedTerm, 0, len(docItem.Terms))
+	for term, termItem := range docItem.Terms {
+		item, err := index.setCache.Get(term)
+		if err != nil {
+			return nil, fmt.Errorf("error getting set cache item: %w", err)
+		}
+		// Terms only in the source can't find other documents
+		docFreq := item.set.GetCardinality()
+		if docFreq < 2 {
+			continue
+		}
+		var score float32
+		if index.scoring == models.TextScoringBM25 {
+			score = index.bm25(termItem.Frequency, docItem.Length, docFreq)
+		} else {
+			score = index.tfidf(termItem.Frequency, docItem.Length, docFreq)
+		}
+		// Terms in most documents don't tell the source apart
+		if score <= 0 {
+			continue
+		}
+		terms = append(terms, models.WeightedTerm{Term: term, Weight: score})
+	}
+	slices.SortFunc(terms, func(a, b models.WeightedTerm) int {
+		if c := cmp.Compare(b.Weight, a.Weight); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Term, b.Term)
+	})
+	if maxTerms == 0 {
+		maxTerms = defaultMoreLikeThisMaxTerms
+	}
+	if len(terms) > maxTerms {
+		terms = terms[:maxTerms]
+	}
+	if len(terms) > 0 {
+		best := terms[0].Weight
+		for i := range terms {
+			terms[i].Weight /= best
+		}
+	}
+	return terms, nil
+}
+
+// MoreLikeThis runs a containsAny search for the weighted terms of a source
+// document, each term's score is scaled by its weight. The source, if given,
+// is not part of the results.
+func (index *indexText) MoreLikeThis(terms []models.WeightedTerm, sourceId *uint64, options models.SearchTextOptions, filter *roaring64.Bitmap) (*roaring64.Bitmap, []models.SearchResult, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	// ---------------------------
+	if len(terms) == 0 {
+		return roaring64.New(), []models.SearchResult{}, nil
+	}
+	expansions := make(map[string][]expandedTerm, len(terms))
+	boosts := make(map[string]float32, len(terms))
+	for _, wt := range terms {
+		expansions[wt.Term] = []expandedTerm{{term: wt.Term, weight: 1}}
+		boosts[wt.Term] = wt.Weight
+	}
+	options.Operator = models.OperatorContainsAny
+	if sourceId == nil {
+		return index.search(options, nil, expansions, boosts, filter)
+	}
+	// ---------------------------
+	// The source contains all the terms so we make room for it in the results
+	limit := options.Limit
+	options.Limit++
+	finalSet, results, err := index.search(options, nil, expansions, boosts, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	finalSet.Remove(*sourceId)
+	results = slices.DeleteFunc(results, func(r models.SearchResult) bool {
+		return r.NodeId == *sourceId
+	})
+	if len(results) > limit {
+		finalSet.Clear()
+		results = results[:limit]
+		for _, r := range results {
+			finalSet.Add(r.NodeId)
+		}
+	}
+	return finalSet, results, nil
+}
@@ -0,0 +1,81 @@
+package text
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/models"
+)
+
+// The maximum number of index terms a single suggestion considers. Short
+// prefixes could otherwise go through a large part of the term dictionary.
+const maxSuggestTerms = 1000
+
+var errSuggestTermLimit = errors.New("suggest term limit reached")
+
+// Suggest completes the last word of the prefix with the most frequent index
+// terms that start with it. The prefix goes through the analyser so the terms
+// are found regardless of case, but the suggestions are the index terms
+// themselves, e.g. stemmed for language analysers. If a filter is given only
+// the documents in it are counted. The document sets are read straight from
+// the bucket and are not cached, and at most maxSuggestTerms terms are
+// considered.
+func (index *indexText) Suggest(prefix string, filter *roaring64.Bitmap, limit int) ([]models.Suggestion, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	// ---------------------------
+	tokens, err := index.analyser.Analyse(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error analysing prefix: %w", err)
+	}
+	// E.g. the prefix was only stopwords
+	if len(tokens) == 0 {
+		return []models.Suggestion{}, nil
+	}
+	termPrefix := tokens[len(tokens)-1].Term
+	// ---------------------------
+	suggestions := make([]models.Suggestion, 0)
+	scanned := 0
+	err = index.bucket.PrefixScan([]byte("t"+termPrefix), func(k, v []byte) error {
+		var si *setCacheItem
+		term, ok := si.IdFromKey(k)
+		if !ok {
+			return nil
+		}
+		if scanned >= maxSuggestTerms {
+			return errSuggestTermLimit
+		}
+		scanned++
+		rSet := roaring64.New()
+		if _, err := rSet.ReadFrom(bytes.NewReader(v)); err != nil {
+			return fmt.Errorf("error reading set of term %s: %w", term, err)
+		}
+		count := rSet.GetCardinality()
+		if filter != nil {
+			count = rSet.AndCardinality(filter)
+		}
+		if count > 0 {
+			suggestions = append(suggestions, models.Suggestion{Term: term, DocumentCount: count})
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSuggestTermLimit) {
+		return nil, fmt.Errorf("error scanning terms: %w", err)
+	}
+	// ---------------------------
+	slices.SortFunc(suggestions, func(a, b models.Suggestion) int {
+		if c := cmp.Compare(b.DocumentCount, a.DocumentCount); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Term, b.Term)
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
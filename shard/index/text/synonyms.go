@@ -0,0 +1,45 @@
+package text
+
+import (
+	"fmt"
+	"slices"
+)
+
+// The weight of a synonym match relative to an exact match of the query term,
+// it keeps documents with the actual query words above those that only have
+// a synonym.
+const synonymWeight = 0.8
+
+// SetSynonyms sets the synonym sets used to expand query terms. The words are
+// analysed the same way as the documents so that, for example, stemmed index
+// terms still match. Words that analyse to more than one term can't be matched
+// as a single term and are skipped.
+func (index *indexText) SetSynonyms(synonyms [][]string) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	// ---------------------------
+	index.synonyms = make(map[string][]string)
+	for _, set := range synonyms {
+		terms := make([]string, 0, len(set))
+		for _, word := range set {
+			tokens, err := index.analyser.Analyse(word)
+			if err != nil {
+				return fmt.Errorf("error analysing synonym %s: %w", word, err)
+			}
+			if len(tokens) != 1 || slices.Contains(terms, tokens[0].Term) {
+				continue
+			}
+			terms = append(terms, tokens[0].Term)
+		}
+		// A word can appear in multiple sets, it is then a synonym of the
+		// words in all of them.
+		for _, term := range terms {
+			for _, other := range terms {
+				if other != term && !slices.Contains(index.synonyms[term], other) {
+					index.synonyms[term] = append(index.synonyms[term], other)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -1,7 +1,8 @@
 /*
 Package text provides a text index. The text index is used to index and
 search text fields in documents. The scoring is based on the term frequency and
-inverse document frequency of the terms in the index, aka tf-idf.
+inverse document frequency of the terms in the index, either plain tf-idf or
+BM25 which also takes the document length into account.
 
 We use the jargon document to refer to a single piece of text (string).
 
@@ -15,6 +16,7 @@ is a map of terms to a set of document ids.
 
 Storage in bucket:
 NUMDOCUMENTSKEY: The number of documents in the index. This is used to calculate tf-idf score.
+TOTALLENGTHKEY: The total length of all documents, average document length for BM25 is total / number of documents.
 t<TERM>s: roaring set of document ids where the term occurs.
 d<DOCID>: document cache item containing the terms and their frequencies in the document.
 */
@@ -50,13 +52,26 @@ import (
 // calculate the IDF for the terms in the index.
 const numDocumentsKey = "_numDocuments"
 
+// Used to store the sum of the lengths of all documents in the index. We keep
+// the sum rather than the average so it can be updated exactly as documents
+// change. The average document length is used by BM25.
+const totalLengthKey = "_totalDocumentLength"
+
+// Default BM25 parameters, see https://en.wikipedia.org/wiki/Okapi_BM25
+const (
+	defaultBM25K1 = 1.2
+	defaultBM25B  = 0.75
+)
+
 // ---------------------------
 // Stores the term and the start and end position in the document. Usually
-// obtained after analysing / tokenising the document.
+// obtained after analysing / tokenising the document. The position is the
+// index of the token in the text, removed tokens such as stopwords leave a gap.
 type Token struct {
-	Term  string
-	Start int
-	End   int
+	Term     string
+	Start    int
+	End      int
+	Position int
 }
 
 // Common interface for analysers. The bleveAnalyser implements this interface.
@@ -87,9 +102,10 @@ func (a *bleeveAnalyser) Analyse(text string) ([]Token, error) {
 	tokens := make([]Token, len(tokenStream))
 	for i, token := range tokenStream {
 		tokens[i] = Token{
-			Term:  string(token.Term),
-			Start: token.Start,
-			End:   token.End,
+			Term:     string(token.Term),
+			Start:    token.Start,
+			End:      token.End,
+			Position: token.Position,
 		}
 	}
 	return tokens, nil
@@ -100,30 +116,56 @@ func (a *bleeveAnalyser) Analyse(text string) ([]Token, error) {
 // indexText is the main struct for the text index. The mutex is used to protect
 // both caches.
 type indexText struct {
-	analyser analyser
-	setCache *cache.ItemCache[string, *setCacheItem]
-	docCache *cache.ItemCache[uint64, docCacheItem]
-	numDocs  uint64
-	bucket   diskstore.Bucket
-	mu       sync.Mutex
-}
-
-// NewIndexText creates a new text index. The analyser parameter is the name of
-// the analyser to use. The analyser is used to convert a document into a list
-// of tokens.
+	analyser    analyser
+	scoring     string
+	k1          float64
+	b           float64
+	setCache    *cache.ItemCache[string, *setCacheItem]
+	docCache    *cache.ItemCache[uint64, docCacheItem]
+	numDocs     uint64
+	totalLength uint64
+	synonyms    map[string][]string
+	bucket      diskstore.Bucket
+	mu          sync.Mutex
+}
+
+// NewIndexText creates a new text index. The analyser parameter is either the
+// standard analyser, a language or a custom pipeline. The analyser is used to
+// convert a document into a list of tokens.
 func NewIndexText(b diskstore.Bucket, params models.IndexTextParameters) (*indexText, error) {
-	analyser, err := newBeleeveAnalyser(params.Analyser)
+	name, err := analyserName(params)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving analyser: %w", err)
+	}
+	analyser, err := newBeleeveAnalyser(name)
 	if err != nil {
 		return nil, fmt.Errorf("error getting analyser: %w", err)
 	}
 	it := &indexText{
 		analyser: analyser,
+		scoring:  params.Scoring,
+		k1:       defaultBM25K1,
+		b:        defaultBM25B,
 		setCache: cache.NewItemCache[string, *setCacheItem](b),
 		docCache: cache.NewItemCache[uint64, docCacheItem](b),
 		bucket:   b,
 	}
+	if it.scoring == "" {
+		it.scoring = models.TextScoringTfIdf
+	}
+	if params.K1 != nil {
+		it.k1 = float64(*params.K1)
+	}
+	if params.B != nil {
+		it.b = float64(*params.B)
+	}
 	// ---------------------------
 	it.numDocs = it.initSize()
+	totalLength, err := it.initTotalLength()
+	if err != nil {
+		return nil, fmt.Errorf("error getting total document length: %w", err)
+	}
+	it.totalLength = totalLength
 	// ---------------------------
 	return it, nil
 }
@@ -137,15 +179,32 @@ func (index *indexText) initSize() uint64 {
 	return vv
 }
 
+func (index *indexText) initTotalLength() (uint64, error) {
+	if v := index.bucket.Get([]byte(totalLengthKey)); v != nil {
+		return conversion.BytesToUint64(v), nil
+	}
+	if index.numDocs == 0 {
+		return 0, nil
+	}
+	/* Indices created before BM25 was added don't have the total length, so we
+	 * compute it once from the documents. It gets stored on the next flush. */
+	var totalLength uint64
+	err := index.docCache.ForEach(func(id uint64, item docCacheItem) error {
+		totalLength += uint64(item.Length)
+		return nil
+	})
+	return totalLength, err
+}
+
 type Document struct {
 	Id   uint64
 	Text string
 }
 
 type analysedDocument struct {
-	Id          uint64
-	Frequencies map[string]int
-	Length      int
+	Id     uint64
+	Terms  map[string]Term
+	Length int
 }
 
 func (index *indexText) InsertUpdateDelete(ctx context.Context, in <-chan Document) <-chan error {
@@ -187,11 +246,7 @@ func (index *indexText) processAnalysedDoc(ad analysedDocument) error {
 	// ---------------------------
 	case !exists && ad.Length > 0:
 		// Insert
-		terms := make(map[string]Term)
-		for term, frequency := range ad.Frequencies {
-			terms[term] = Term{
-				Frequency: frequency,
-			}
+		for term := range ad.Terms {
 			setItem, err := index.setCache.Get(term)
 			if err != nil {
 				return fmt.Errorf("error getting set cache item: %w", err)
@@ -199,11 +254,12 @@ func (index *indexText) processAnalysedDoc(ad analysedDocument) error {
 			setItem.isDirty = setItem.set.CheckedAdd(ad.Id) || setItem.isDirty
 		}
 		newDoc := docCacheItem{
-			Terms:  terms,
+			Terms:  ad.Terms,
 			Length: ad.Length,
 		}
 		index.docCache.Put(ad.Id, newDoc)
 		index.numDocs++
+		index.totalLength += uint64(ad.Length)
 	// ---------------------------
 	case exists && ad.Length == 0:
 		// Delete
@@ -218,13 +274,14 @@ func (index *indexText) processAnalysedDoc(ad analysedDocument) error {
 			return fmt.Errorf("error deleting doc cache item: %w", err)
 		}
 		index.numDocs--
+		index.totalLength -= uint64(docItem.Length)
 	// ---------------------------
 	case exists && ad.Length > 0:
 		// Update
 		// We need to remove the old terms from the set that are not in the new
 		// document and add the new terms to the set that are not in the old
 		for term := range docItem.Terms {
-			if _, ok := ad.Frequencies[term]; ok {
+			if _, ok := ad.Terms[term]; ok {
 				continue
 			}
 			setItem, err := index.setCache.Get(term)
@@ -233,11 +290,7 @@ func (index *indexText) processAnalysedDoc(ad analysedDocument) error {
 			}
 			setItem.isDirty = setItem.set.CheckedRemove(ad.Id) || setItem.isDirty
 		}
-		terms := make(map[string]Term)
-		for term, freq := range ad.Frequencies {
-			terms[term] = Term{
-				Frequency: freq,
-			}
+		for term := range ad.Terms {
 			if _, ok := docItem.Terms[term]; ok {
 				continue
 			}
@@ -247,7 +300,8 @@ func (index *indexText) processAnalysedDoc(ad analysedDocument) error {
 			}
 			setItem.isDirty = setItem.set.CheckedAdd(ad.Id) || setItem.isDirty
 		}
-		docItem.Terms = terms
+		index.totalLength = index.totalLength - uint64(docItem.Length) + uint64(ad.Length)
+		docItem.Terms = ad.Terms
 		docItem.Length = ad.Length
 		index.docCache.Put(ad.Id, docItem)
 	// ---------------------------
@@ -258,7 +312,7 @@ func (index *indexText) processAnalysedDoc(ad analysedDocument) error {
 }
 
 func (index *indexText) parallelAnalyse(ctx context.Context, in <-chan Document) (<-chan analysedDocument, <-chan error) {
-	numWorkers := runtime.NumCPU() - 1
+	numWorkers := max(runtime.NumCPU()-1, 1)
 	outs := make([]<-chan analysedDocument, numWorkers)
 	errCs := make([]<-chan error, numWorkers)
 	for i := 0; i < numWorkers; i++ {
@@ -268,13 +322,16 @@ func (index *indexText) parallelAnalyse(ctx context.Context, in <-chan Document)
 			if err != nil {
 				return
 			}
-			// Calculate term frequencies from tokens
-			freq := make(map[string]int)
+			// Calculate term frequencies and positions from tokens
+			terms := make(map[string]Term)
 			for _, t := range tokens {
-				freq[t.Term]++
+				term := terms[t.Term]
+				term.Frequency++
+				term.Positions = append(term.Positions, t.Position)
+				terms[t.Term] = term
 			}
 			ad.Id = doc.Id
-			ad.Frequencies = freq
+			ad.Terms = terms
 			ad.Length = len(tokens)
 			return
 		})
@@ -291,6 +348,9 @@ func (index *indexText) flush() error {
 	if err := index.bucket.Put([]byte(numDocumentsKey), conversion.Uint64ToBytes(numDocs)); err != nil {
 		return fmt.Errorf("error putting num documents to bucket: %w", err)
 	}
+	if err := index.bucket.Put([]byte(totalLengthKey), conversion.Uint64ToBytes(index.totalLength)); err != nil {
+		return fmt.Errorf("error putting total document length to bucket: %w", err)
+	}
 	// ---------------------------
 	if err := index.setCache.Flush(); err != nil {
 		return fmt.Errorf("error flushing set cache: %w", err)
@@ -311,21 +371,72 @@ func (index *indexText) Search(options models.SearchTextOptions, filter *roaring
 	if err != nil {
 		return nil, nil, fmt.Errorf("error analysing text: %w", err)
 	}
-	queryTerms := make(map[string]struct{})
+	/* Each query term matches one or more index terms depending on the match
+	 * mode, e.g. with prefix matching "gan" matches "gandalf" and "gang". */
+	expansions := make(map[string][]expandedTerm, len(tokens))
 	for _, token := range tokens {
-		queryTerms[token.Term] = struct{}{}
-	}
-	// ---------------------------
-	sets := make([]*roaring64.Bitmap, 0, len(queryTerms))
-	for term := range queryTerms {
-		item, err := index.setCache.Get(term)
+		if _, ok := expansions[token.Term]; ok {
+			continue
+		}
+		expanded, err := index.expandTerm(token.Term, options)
 		if err != nil {
-			return nil, nil, fmt.Errorf("error getting set cache item: %w", err)
+			return nil, nil, fmt.Errorf("error expanding term %s: %w", token.Term, err)
+		}
+		expansions[token.Term] = expanded
+	}
+	return index.search(options, tokens, expansions, nil, filter)
+}
+
+// search finds and ranks the documents that match the expanded query terms. The
+// tokens are only used to check phrases and the boosts, if given, scale the
+// score of each query term.
+func (index *indexText) search(
+	options models.SearchTextOptions,
+	tokens []Token,
+	expansions map[string][]expandedTerm,
+	boosts map[string]float32,
+	filter *roaring64.Bitmap,
+) (*roaring64.Bitmap, []models.SearchResult, error) {
+	// A query term is present in a document if any of its index terms are
+	queryTerms := make(map[string]struct{}, len(expansions))
+	sets := make([]*roaring64.Bitmap, 0, len(expansions))
+	for queryTerm, expanded := range expansions {
+		queryTerms[queryTerm] = struct{}{}
+		termSets := make([]*roaring64.Bitmap, 0, len(expanded))
+		var queryDocFreq uint64
+		for i, et := range expanded {
+			item, err := index.setCache.Get(et.term)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error getting set cache item: %w", err)
+			}
+			termSets = append(termSets, item.set)
+			// The document frequencies of the terms are the same for all
+			// documents
+			expanded[i].docFreq = item.set.GetCardinality()
+			if !et.synonym {
+				queryDocFreq = max(queryDocFreq, expanded[i].docFreq)
+			}
+		}
+		/* A rare synonym would otherwise get a larger inverse document
+		 * frequency than the query term itself and outrank documents that
+		 * have the actual query words. So synonyms are scored as if they were
+		 * at least as common as the query term. */
+		for i, et := range expanded {
+			if et.synonym {
+				expanded[i].docFreq = max(et.docFreq, queryDocFreq)
+			}
+		}
+		if len(termSets) == 1 {
+			sets = append(sets, termSets[0])
+		} else {
+			sets = append(sets, roaring64.FastOr(termSets...))
 		}
-		sets = append(sets, item.set)
 	}
+	/* Phrase and proximity queries need all the terms to be present, the
+	 * positions are checked later on per document. */
+	positional := options.Operator == models.OperatorPhrase || options.Operator == models.OperatorProximity
 	var finalSet *roaring64.Bitmap
-	if options.Operator == models.OperatorContainsAll {
+	if options.Operator == models.OperatorContainsAll || positional {
 		finalSet = roaring64.FastAnd(sets...)
 	} else {
 		finalSet = roaring64.FastOr(sets...)
@@ -340,8 +451,9 @@ func (index *indexText) Search(options models.SearchTextOptions, filter *roaring
 		weight = *options.Weight
 	}
 	// ---------------------------
-	// Get the documents and rank them using tf-idf
+	// Get the documents and rank them
 	results := make([]models.SearchResult, 0, finalSet.GetCardinality())
+	unmatched := make([]uint64, 0)
 	it := finalSet.Iterator()
 	for it.HasNext() {
 		docId := it.Next()
@@ -350,27 +462,60 @@ func (index *indexText) Search(options models.SearchTextOptions, filter *roaring
 			return nil, nil, fmt.Errorf("error getting doc cache item: %w", err)
 		}
 		// ---------------------------
-		// TF-IDF scoring
-		// https://en.wikipedia.org/wiki/Tf%E2%80%93idf
+		// How far apart are the query terms from an exact match? Tighter
+		// matches get a larger share of the score.
+		proximity := float32(1)
+		if positional {
+			var slack int
+			var ok bool
+			if options.Operator == models.OperatorPhrase {
+				ok = matchPhrase(tokens, docItem)
+			} else {
+				slack, ok = matchProximity(queryTerms, docItem, options.Distance)
+			}
+			if !ok {
+				unmatched = append(unmatched, docId)
+				continue
+			}
+			proximity = 1 / float32(1+slack)
+		}
+		// ---------------------------
 		score := float32(0)
 		// E.g. queryTerms = ["gandalf", "wizard"]
-		for term := range queryTerms {
-			freq := 0
-			// How many times the term occurs in the document? Is gandalf
-			// mentioned a lot?
-			if termItem, ok := docItem.Terms[term]; ok {
-				freq = termItem.Frequency
+		for queryTerm := range queryTerms {
+			// The best matching index term counts for the query term, for
+			// exact matching this is just the query term itself.
+			var termScore float32
+			found := false
+			for _, et := range expansions[queryTerm] {
+				// How many times the term occurs in the document? Is gandalf
+				// mentioned a lot?
+				termItem, ok := docItem.Terms[et.term]
+				if !ok {
+					continue
+				}
+				var s float32
+				if index.scoring == models.TextScoringBM25 {
+					s = index.bm25(termItem.Frequency, docItem.Length, et.docFreq)
+				} else {
+					s = index.tfidf(termItem.Frequency, docItem.Length, et.docFreq)
+				}
+				// Inexact and synonym matches lose a share of the score, as
+				// with proximity
+				s -= float32(math.Abs(float64(s))) * (1 - et.weight)
+				if !found || s > termScore {
+					termScore = s
+					found = true
+				}
+			}
+			if boost, ok := boosts[queryTerm]; ok {
+				termScore *= boost
 			}
-			// Calculate the tf-idf score
-			// term frequency tf = how often does the term occur in the document
-			// with respect to the length of the document
-			tf := float32(freq) / float32(docItem.Length)
-			termSetItem, _ := index.setCache.Get(term)
-			// inverse document frequency idf = how rare is the term in the
-			// index
-			idf := math.Log10(float64(index.numDocs) / float64(termSetItem.set.GetCardinality()+1))
-			score += tf * float32(idf)
+			score += termScore
 		}
+		// Loose matches lose a share of the score. tf-idf scores can be negative
+		// for common terms so we subtract rather than scale.
+		score -= float32(math.Abs(float64(score))) * (1 - proximity)
 		// ---------------------------
 		sr := models.SearchResult{
 			NodeId:      docId,
@@ -379,6 +524,9 @@ func (index *indexText) Search(options models.SearchTextOptions, filter *roaring
 		}
 		results = append(results, sr)
 	}
+	for _, docId := range unmatched {
+		finalSet.Remove(docId)
+	}
 	// ---------------------------
 	slices.SortFunc(results, func(a, b models.SearchResult) int {
 		return cmp.Compare(*b.Score, *a.Score)
@@ -395,6 +543,109 @@ func (index *indexText) Search(options models.SearchTextOptions, filter *roaring
 	return finalSet, results, nil
 }
 
+// TF-IDF scoring
+// https://en.wikipedia.org/wiki/Tf%E2%80%93idf
+func (index *indexText) tfidf(freq, docLength int, docFreq uint64) float32 {
+	// term frequency tf = how often does the term occur in the document
+	// with respect to the length of the document
+	tf := float32(freq) / float32(docLength)
+	// inverse document frequency idf = how rare is the term in the
+	// index
+	idf := math.Log10(float64(index.numDocs) / float64(docFreq+1))
+	return tf * float32(idf)
+}
+
+// BM25 scoring
+// https://en.wikipedia.org/wiki/Okapi_BM25
+func (index *indexText) bm25(freq, docLength int, docFreq uint64) float32 {
+	if freq == 0 {
+		return 0
+	}
+	// This variant of idf is always positive, unlike the one above it doesn't
+	// penalise terms that occur in more than half of the documents.
+	n := float64(index.numDocs)
+	df := float64(docFreq)
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	// The term frequency saturates with k1 and is normalised by how long the
+	// document is compared to the average document with b.
+	avgLength := float64(index.totalLength) / max(n, 1)
+	tf := float64(freq)
+	norm := tf + index.k1*(1-index.b+index.b*float64(docLength)/avgLength)
+	return float32(idf * tf * (index.k1 + 1) / norm)
+}
+
+// ---------------------------
+
+// matchPhrase checks if the query tokens occur in the document in the same
+// order and with the same gaps as in the query.
+func matchPhrase(tokens []Token, docItem docCacheItem) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	first := docItem.Terms[tokens[0].Term].Positions
+	for _, start := range first {
+		found := true
+		for _, token := range tokens[1:] {
+			// The expected position of this token relative to the first one
+			expected := start + token.Position - tokens[0].Position
+			if _, ok := slices.BinarySearch(docItem.Terms[token.Term].Positions, expected); !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// matchProximity finds the smallest window in the document that contains all
+// the query terms in any order. It returns how many extra positions the window
+// has compared to the terms being next to each other and whether the window
+// spans at most distance positions.
+func matchProximity(queryTerms map[string]struct{}, docItem docCacheItem, distance int) (int, bool) {
+	// ---------------------------
+	// Merge the positions of all the terms into a single sorted list
+	type termPosition struct {
+		position int
+		term     string
+	}
+	positions := make([]termPosition, 0)
+	for term := range queryTerms {
+		for _, p := range docItem.Terms[term].Positions {
+			positions = append(positions, termPosition{position: p, term: term})
+		}
+	}
+	slices.SortFunc(positions, func(a, b termPosition) int {
+		return cmp.Compare(a.position, b.position)
+	})
+	// ---------------------------
+	// Slide a window over the positions keeping all the terms inside
+	bestSpan := -1
+	counts := make(map[string]int, len(queryTerms))
+	left := 0
+	for _, tp := range positions {
+		counts[tp.term]++
+		for len(counts) == len(queryTerms) {
+			span := tp.position - positions[left].position
+			if bestSpan == -1 || span < bestSpan {
+				bestSpan = span
+			}
+			lt := positions[left].term
+			counts[lt]--
+			if counts[lt] == 0 {
+				delete(counts, lt)
+			}
+			left++
+		}
+	}
+	if bestSpan == -1 || bestSpan > distance {
+		return 0, false
+	}
+	return max(bestSpan-(len(queryTerms)-1), 0), true
+}
+
 // ---------------------------
 
 // setCacheItem is used to store the roaring set and a flag to indicate if the
@@ -469,6 +720,10 @@ func (si *setCacheItem) DeleteFrom(term string, bucket diskstore.Bucket) error {
 
 type Term struct {
 	Frequency int `msgpack:"frequency"`
+	// Token positions of the term in the document in ascending order. Documents
+	// indexed before positions were stored don't have them and won't match
+	// phrase or proximity queries until they are updated.
+	Positions []int `msgpack:"positions,omitempty"`
 }
 
 type docCacheItem struct {
@@ -494,8 +749,12 @@ func (dc docCacheItem) IdFromKey(key []byte) (uint64, bool) {
 }
 
 func (dc docCacheItem) SizeInMemory() int64 {
-	// Just counting terms and their frequencies
-	return int64(len(dc.Terms) * 4)
+	// Just counting terms, their frequencies and positions
+	size := len(dc.Terms) * 4
+	for _, term := range dc.Terms {
+		size += len(term.Positions) * 8
+	}
+	return int64(size)
 }
 
 func (dc docCacheItem) CheckAndClearDirty() bool {
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/conversion"
 	"github.com/semafind/semadb/diskstore"
 	"github.com/semafind/semadb/models"
 	"github.com/semafind/semadb/shard/index/text"
@@ -131,6 +133,545 @@ func Test_Search(t *testing.T) {
 	require.Greater(t, *results[0].Score, *results[1].Score)
 }
 
+func Test_SearchBM25(t *testing.T) {
+	// ---------------------------
+	b := diskstore.NewMemBucket(false)
+	params := models.IndexTextParameters{
+		Analyser: "standard",
+		Scoring:  models.TextScoringBM25,
+	}
+	index, err := text.NewIndexText(b, params)
+	require.NoError(t, err)
+	docs := []text.Document{
+		{Id: 1, Text: "gandalf wizard"},
+		{Id: 2, Text: strings.Repeat("gandalf ", 4) + strings.Repeat("hobbit ", 30)},
+		{Id: 3, Text: "frodo hobbit"},
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	for _, doc := range docs {
+		out <- doc
+	}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	so := models.SearchTextOptions{
+		Value:    "gandalf",
+		Operator: models.OperatorContainsAny,
+		Limit:    10,
+	}
+	// The short document wins despite fewer mentions
+	_, results, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, uint64(1), results[0].NodeId)
+	require.Greater(t, *results[1].Score, float32(0))
+	// Without length normalisation the repetitive document wins
+	noNorm := float32(0)
+	params.B = &noNorm
+	index, err = text.NewIndexText(b, params)
+	require.NoError(t, err)
+	_, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), results[0].NodeId)
+	// ---------------------------
+	// Total length is maintained across deletes
+	require.Equal(t, uint64(38), conversion.BytesToUint64(b.Get([]byte("_totalDocumentLength"))))
+	out = make(chan text.Document)
+	errC = index.InsertUpdateDelete(context.Background(), out)
+	out <- text.Document{Id: 2, Text: ""}
+	out <- text.Document{Id: 3, Text: "frodo"}
+	close(out)
+	require.NoError(t, <-errC)
+	require.Equal(t, uint64(3), conversion.BytesToUint64(b.Get([]byte("_totalDocumentLength"))))
+}
+
+func Test_SearchPhraseProximity(t *testing.T) {
+	// ---------------------------
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "standard",
+	})
+	require.NoError(t, err)
+	docs := []text.Document{
+		{Id: 1, Text: "the lord of the rings"},
+		{Id: 2, Text: "the rings of the lord"},
+		{Id: 3, Text: "lord voldemort never wore rings"},
+		{Id: 4, Text: "lord who was fond of golden rings"},
+		{Id: 5, Text: "frodo and sam"},
+		{Id: 6, Text: "gandalf the grey"},
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	for _, doc := range docs {
+		out <- doc
+	}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	// Stopwords leave gaps so the phrase matches only the exact order
+	so := models.SearchTextOptions{
+		Value:    "lord of the rings",
+		Operator: models.OperatorPhrase,
+		Limit:    10,
+	}
+	rSet, results, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, uint64(1), results[0].NodeId)
+	require.Equal(t, uint64(1), rSet.GetCardinality())
+	// ---------------------------
+	// Proximity ignores order and favours tighter matches
+	so.Value = "lord rings"
+	so.Operator = models.OperatorProximity
+	so.Distance = 4
+	rSet, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.ElementsMatch(t, []uint64{1, 2, 3}, rSet.ToArray())
+	require.Equal(t, uint64(3), results[2].NodeId)
+	require.Greater(t, *results[1].Score, *results[2].Score)
+}
+
+func Test_SearchPrefixFuzzy(t *testing.T) {
+	// ---------------------------
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "standard",
+	})
+	require.NoError(t, err)
+	docs := []text.Document{
+		{Id: 1, Text: "gandalf the grey"},
+		{Id: 2, Text: "gang of hobbits"},
+		{Id: 3, Text: "frodo and sam"},
+		{Id: 4, Text: "samwise gamgee"},
+		{Id: 5, Text: "saruman the white"},
+		{Id: 6, Text: "spam and eggs"},
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	for _, doc := range docs {
+		out <- doc
+	}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	so := models.SearchTextOptions{
+		Value:    "gan",
+		Operator: models.OperatorContainsAny,
+		Limit:    10,
+	}
+	_, results, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+	so.Match = models.TextMatchPrefix
+	rSet, results, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.ElementsMatch(t, []uint64{1, 2}, rSet.ToArray())
+	// ---------------------------
+	// The exact match scores higher than the one with a typo
+	so.Value = "sam gandalv"
+	so.Match = models.TextMatchFuzzy
+	so.Fuzziness = 1
+	rSet, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1, 3, 6}, rSet.ToArray())
+	so.Value = "samwise"
+	_, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, uint64(4), results[0].NodeId)
+	so.Value = "sam"
+	_, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, uint64(3), results[0].NodeId)
+	require.Equal(t, uint64(6), results[1].NodeId)
+	require.Greater(t, *results[0].Score, *results[1].Score)
+	// The first character has to match even if it is within fuzziness
+	so.Value = "randalf"
+	_, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+}
+
+func Test_SearchSynonyms(t *testing.T) {
+	// ---------------------------
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "en",
+	})
+	require.NoError(t, err)
+	docs := []text.Document{
+		{Id: 1, Text: "cheap laptop deals"},
+		{Id: 2, Text: "cheap notebooks"},
+		{Id: 3, Text: "cheap netbook deals"},
+		{Id: 4, Text: "desktop computer"},
+		{Id: 5, Text: "laptop bag"},
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	for _, doc := range docs {
+		out <- doc
+	}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	so := models.SearchTextOptions{
+		Value:    "laptops",
+		Operator: models.OperatorContainsAny,
+		Limit:    10,
+	}
+	rSet, _, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1, 5}, rSet.ToArray())
+	// ---------------------------
+	// Synonyms are analysed like the documents so plurals match, the
+	// hyphenated word is two terms and can't be a synonym
+	err = index.SetSynonyms([][]string{{"laptop", "Notebooks", "netbook"}, {"computer", "lap-top"}})
+	require.NoError(t, err)
+	rSet, results, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1, 2, 3, 5}, rSet.ToArray())
+	// With the same evidence, the exact match scores higher even though the
+	// synonym is rarer
+	scores := make(map[uint64]float32)
+	for _, r := range results {
+		scores[r.NodeId] = *r.Score
+	}
+	require.Greater(t, scores[1], scores[3])
+	require.Greater(t, scores[5], scores[2])
+	// Synonyms work in both directions
+	so.Value = "netbook"
+	rSet, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1, 2, 3, 5}, rSet.ToArray())
+	for _, r := range results {
+		scores[r.NodeId] = *r.Score
+	}
+	require.Greater(t, scores[3], scores[1])
+	so.Value = "computer"
+	rSet, _, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{4}, rSet.ToArray())
+	// ---------------------------
+	// Phrases need the actual words
+	so.Value = "cheap laptop"
+	so.Operator = models.OperatorPhrase
+	rSet, _, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1}, rSet.ToArray())
+	// ---------------------------
+	// Synonyms are highlighted as well
+	so.Operator = models.OperatorContainsAny
+	so.Value = "laptop"
+	so.Highlight = &models.SearchTextHighlight{}
+	h, err := index.NewHighlighter(so)
+	require.NoError(t, err)
+	fragments, err := h.Highlight("cheap notebooks")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cheap <em>notebooks</em>"}, fragments)
+}
+
+func Test_MoreLikeThis(t *testing.T) {
+	// ---------------------------
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "standard",
+	})
+	require.NoError(t, err)
+	docs := []text.Document{
+		{Id: 1, Text: "gandalf the wizard rides to rivendell"},
+		{Id: 2, Text: "gandalf the wizard"},
+		{Id: 3, Text: "the wizard saruman"},
+		{Id: 4, Text: "frodo rides to rivendell"},
+		{Id: 5, Text: "samwise cooks potatoes"},
+		{Id: 6, Text: "sauron watches"},
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	for _, doc := range docs {
+		out <- doc
+	}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	// The rarest terms describe the document best, terms only in the
+	// document itself can't find anything
+	terms, err := index.MoreLikeThisTerms(1, 0)
+	require.NoError(t, err)
+	require.Len(t, terms, 4)
+	require.Equal(t, float32(1), terms[0].Weight)
+	require.ElementsMatch(t, []string{"gandalf", "rides", "rivendell", "wizard"}, []string{terms[0].Term, terms[1].Term, terms[2].Term, terms[3].Term})
+	require.Equal(t, "wizard", terms[3].Term)
+	require.Less(t, terms[3].Weight, terms[0].Weight)
+	require.Greater(t, terms[3].Weight, float32(0.5))
+	terms, err = index.MoreLikeThisTerms(1, 2)
+	require.NoError(t, err)
+	require.Len(t, terms, 2)
+	terms, err = index.MoreLikeThisTerms(42, 0)
+	require.NoError(t, err)
+	require.Len(t, terms, 0)
+	// ---------------------------
+	terms, err = index.MoreLikeThisTerms(1, 0)
+	require.NoError(t, err)
+	so := models.SearchTextOptions{
+		Operator: models.OperatorMoreLikeThis,
+		Limit:    2,
+	}
+	sourceId := uint64(1)
+	rSet, results, err := index.MoreLikeThis(terms, &sourceId, so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.ElementsMatch(t, []uint64{2, 4}, rSet.ToArray())
+	so.Limit = 10
+	rSet, _, err = index.MoreLikeThis(terms, &sourceId, so, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{2, 3, 4}, rSet.ToArray())
+	// Without a source, e.g. the point is in another shard, nothing is excluded
+	rSet, _, err = index.MoreLikeThis(terms, nil, so, roaring64.BitmapOf(1, 3))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uint64{1, 3}, rSet.ToArray())
+}
+
+func Test_Analysers(t *testing.T) {
+	// Every language accepted by the schema must have an analyser
+	for _, lang := range models.TextAnalyserLanguages {
+		_, err := text.NewIndexText(diskstore.NewMemBucket(false), models.IndexTextParameters{
+			Analyser: lang,
+		})
+		require.NoError(t, err, lang)
+		_, err = text.NewIndexText(diskstore.NewMemBucket(false), models.IndexTextParameters{
+			Analyser: models.TextAnalyserCustom,
+			Custom: &models.TextAnalyserParameters{
+				Tokenizer:         "unicode",
+				StopwordsLanguage: lang,
+				StemmerLanguage:   lang,
+			},
+		})
+		require.NoError(t, err, lang)
+	}
+}
+
+func Test_SearchAnalysers(t *testing.T) {
+	tests := []struct {
+		name   string
+		params models.IndexTextParameters
+		text   string
+		query  string
+	}{
+		{
+			name:   "German stemming",
+			params: models.IndexTextParameters{Analyser: "de"},
+			text:   "Die Häuser sind alt",
+			query:  "Haus",
+		},
+		{
+			name:   "French elision",
+			params: models.IndexTextParameters{Analyser: "fr"},
+			text:   "l'avion est arrivé",
+			query:  "avion",
+		},
+		{
+			name: "Custom camel case",
+			params: models.IndexTextParameters{
+				Analyser: models.TextAnalyserCustom,
+				Custom: &models.TextAnalyserParameters{
+					Tokenizer:      "letter",
+					SplitCamelCase: true,
+					Lowercase:      true,
+				},
+			},
+			text:  "func parseHttpRequest(req_body string)",
+			query: "request",
+		},
+		{
+			name: "Custom edge ngram",
+			params: models.IndexTextParameters{
+				Analyser: models.TextAnalyserCustom,
+				Custom: &models.TextAnalyserParameters{
+					Tokenizer: "whitespace",
+					Lowercase: true,
+					NGram:     &models.TextNGramParameters{Min: 2, Max: 5, Edge: true},
+				},
+			},
+			text:  "Gandalf",
+			query: "gan",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := diskstore.NewMemBucket(false)
+			index, err := text.NewIndexText(b, tt.params)
+			require.NoError(t, err)
+			out := make(chan text.Document)
+			errC := index.InsertUpdateDelete(context.Background(), out)
+			out <- text.Document{Id: 1, Text: tt.text}
+			out <- text.Document{Id: 2, Text: "frodo"}
+			close(out)
+			require.NoError(t, <-errC)
+			so := models.SearchTextOptions{
+				Value:    tt.query,
+				Operator: models.OperatorContainsAll,
+				Limit:    10,
+			}
+			_, results, err := index.Search(so, nil)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			require.Equal(t, uint64(1), results[0].NodeId)
+		})
+	}
+}
+
+func Test_CustomStopwords(t *testing.T) {
+	params := models.IndexTextParameters{
+		Analyser: models.TextAnalyserCustom,
+		Custom: &models.TextAnalyserParameters{
+			Tokenizer: "unicode",
+			Lowercase: true,
+			Stopwords: []string{"mordor"},
+		},
+	}
+	// Defining the same pipeline twice reuses the analyser
+	_, err := text.NewIndexText(diskstore.NewMemBucket(false), params)
+	require.NoError(t, err)
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, params)
+	require.NoError(t, err)
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	out <- text.Document{Id: 1, Text: "One does not simply walk into Mordor"}
+	close(out)
+	require.NoError(t, <-errC)
+	so := models.SearchTextOptions{
+		Value:    "mordor",
+		Operator: models.OperatorContainsAny,
+		Limit:    10,
+	}
+	_, results, err := index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+	so.Value = "walk"
+	_, results, err = index.Search(so, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+}
+
+func Test_Highlight(t *testing.T) {
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "standard",
+	})
+	require.NoError(t, err)
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	out <- text.Document{Id: 1, Text: "Gandalf"}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	so := models.SearchTextOptions{
+		Value:     "wizard gandalf",
+		Operator:  models.OperatorContainsAny,
+		Limit:     10,
+		Highlight: &models.SearchTextHighlight{},
+	}
+	h, err := index.NewHighlighter(so)
+	require.NoError(t, err)
+	fragments, err := h.Highlight("Gandalf the Grey is a wizard.")
+	require.NoError(t, err)
+	require.Equal(t, []string{"<em>Gandalf</em> the Grey is a <em>wizard</em>."}, fragments)
+	// ---------------------------
+	// Long texts are cut into fragments around the matches at word boundaries
+	so.Highlight = &models.SearchTextHighlight{
+		PreTag:       "[",
+		PostTag:      "]",
+		FragmentSize: 20,
+		MaxFragments: 2,
+	}
+	h, err = index.NewHighlighter(so)
+	require.NoError(t, err)
+	long := "Gandalf " + strings.Repeat("walks ", 10) + "with the wizard named Radagast " + strings.Repeat("again ", 10) + "wizard"
+	fragments, err = h.Highlight(long)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[Gandalf] walks walks", "the [wizard] named"}, fragments)
+	// ---------------------------
+	// Prefix matches are highlighted as well
+	so.Value = "gan"
+	so.Match = models.TextMatchPrefix
+	h, err = index.NewHighlighter(so)
+	require.NoError(t, err)
+	fragments, err = h.Highlight("Gandalf the Grey")
+	require.NoError(t, err)
+	require.Equal(t, []string{"[Gandalf] the Grey"}, fragments)
+}
+
+func Test_Suggest(t *testing.T) {
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "standard",
+	})
+	require.NoError(t, err)
+	docs := []text.Document{
+		{Id: 1, Text: "gandalf the grey"},
+		{Id: 2, Text: "gandalf the white"},
+		{Id: 3, Text: "gang of hobbits"},
+		{Id: 4, Text: "galadriel"},
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	for _, doc := range docs {
+		out <- doc
+	}
+	close(out)
+	require.NoError(t, <-errC)
+	// ---------------------------
+	// Most frequent first, then alphabetical
+	suggestions, err := index.Suggest("Ga", nil, 10)
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{
+		{Term: "gandalf", DocumentCount: 2},
+		{Term: "galadriel", DocumentCount: 1},
+		{Term: "gang", DocumentCount: 1},
+	}, suggestions)
+	suggestions, err = index.Suggest("gan", nil, 1)
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{{Term: "gandalf", DocumentCount: 2}}, suggestions)
+	// ---------------------------
+	// Only the filtered documents are counted
+	filter := roaring64.BitmapOf(1, 3)
+	suggestions, err = index.Suggest("gan", filter, 10)
+	require.NoError(t, err)
+	require.Equal(t, []models.Suggestion{
+		{Term: "gandalf", DocumentCount: 1},
+		{Term: "gang", DocumentCount: 1},
+	}, suggestions)
+	suggestions, err = index.Suggest("gal", filter, 10)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 0)
+}
+
+func Test_SuggestTermLimit(t *testing.T) {
+	b := diskstore.NewMemBucket(false)
+	index, err := text.NewIndexText(b, models.IndexTextParameters{
+		Analyser: "standard",
+	})
+	require.NoError(t, err)
+	words := make([]string, 1200)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%04d", i)
+	}
+	out := make(chan text.Document)
+	errC := index.InsertUpdateDelete(context.Background(), out)
+	out <- text.Document{Id: 1, Text: strings.Join(words, " ")}
+	close(out)
+	require.NoError(t, <-errC)
+	// A short prefix does not go through the whole term dictionary
+	suggestions, err := index.Suggest("w", nil, 2000)
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1000)
+}
+
 func Test_SearchFilter(t *testing.T) {
 	// ---------------------------
 	b := diskstore.NewMemBucket(false)
@@ -0,0 +1,182 @@
+package vamana
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"time"
+
+	"github.com/semafind/semadb/shard/vectorstore"
+	"github.com/semafind/semadb/utils"
+)
+
+/* The bulk build follows the original Vamana construction from the DiskANN
+ * paper. Instead of inserting points one by one into a graph that is in
+ * constant flux, we start from a random graph and then make two passes over all
+ * points. In each pass we greedy search for the point and robust prune its
+ * neighbours from the visited set. The first pass uses alpha = 1 which yields a
+ * sparse graph with mostly short edges, the second pass uses the configured
+ * alpha which adds the long range edges that make search navigable. This is
+ * much faster for large initial loads because the random graph is already
+ * connected and every greedy search is over a graph of the final size. */
+
+// Bulk building below this many points gives little benefit over incremental
+// inserts and the random initial graph is not meaningful, so we fall back.
+const BULKBUILDMINPOINTS = 1000
+
+type bulkBuildContextKey struct{}
+
+// WithBulkBuild marks the context so that new points inserted in the same
+// operation are indexed using the bulk build instead of incremental inserts.
+// Updates and deletes are unaffected.
+func WithBulkBuild(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bulkBuildContextKey{}, true)
+}
+
+func isBulkBuild(ctx context.Context) bool {
+	bulk, ok := ctx.Value(bulkBuildContextKey{}).(bool)
+	return ok && bulk
+}
+
+// ---------------------------
+
+func (v *IndexVamana) bulkInsert(ctx context.Context, points []IndexVectorChange) error {
+	if len(points) < BULKBUILDMINPOINTS {
+		numWorkers := runtime.NumCPU()
+		insertQ := utils.ProduceWithContext(ctx, points)
+		errCs := make([]<-chan error, numWorkers)
+		for i := 0; i < numWorkers; i++ {
+			errCs[i] = v.insertWorker(ctx, insertQ)
+		}
+		return <-utils.MergeErrorsWithContext(ctx, errCs...)
+	}
+	return v.bulkBuild(ctx, points)
+}
+
+func (v *IndexVamana) bulkBuild(ctx context.Context, points []IndexVectorChange) error {
+	startTime := time.Now()
+	// ---------------------------
+	vecs := make([]vectorstore.VectorStorePoint, len(points))
+	for i, point := range points {
+		vec, err := v.vecStore.Set(point.Id, point.Vector)
+		if err != nil {
+			return fmt.Errorf("could not set point: %w", err)
+		}
+		vecs[i] = vec
+	}
+	// ---------------------------
+	/* Random graph initialisation, each new node gets degree bound many random
+	 * out edges to other new points. The start node is also connected to random
+	 * points so that the first greedy searches have somewhere to go. */
+	startNode, err := v.nodeStore.Get(STARTID)
+	if err != nil {
+		return fmt.Errorf("could not get start node: %w", err)
+	}
+	if err := startNode.LoadNeighbours(v.vecStore); err != nil {
+		return fmt.Errorf("could not load start node neighbours: %w", err)
+	}
+	/* Existing nodes, if any, are only reachable through the start node which
+	 * gets pruned heavily as new points add reverse edges to it. So each new
+	 * node also gets an edge to one of the existing neighbours of the start
+	 * node to help link the two graphs. These edges may be pruned as well, so
+	 * the start node edges to the existing graph are restored after each pass,
+	 * see below. */
+	existing := make([]vectorstore.VectorStorePoint, len(startNode.neighbours))
+	copy(existing, startNode.neighbours)
+	numEdges := min(v.parameters.DegreeBound, len(vecs)-1)
+	for i, point := range points {
+		node := &graphNode{Id: point.Id}
+		node.ClearNeighbours()
+		if len(existing) > 0 {
+			node.AddNeighbour(existing[rand.IntN(len(existing))])
+		}
+		for len(node.edges) < numEdges {
+			node.AddNeighbourIfNotExists(vecs[randOtherIndex(len(vecs), i)])
+		}
+		v.nodeStore.Put(point.Id, node)
+	}
+	startNode.edgesMu.Lock()
+	for _, i := range rand.Perm(len(vecs)) {
+		if len(startNode.edges) >= v.parameters.DegreeBound {
+			break
+		}
+		startNode.AddNeighbourIfNotExists(vecs[i])
+	}
+	startNode.edgesMu.Unlock()
+	v.logger.Debug().Int("count", len(points)).Str("duration", time.Since(startTime).String()).Msg("BulkBuild - RandomInit")
+	// ---------------------------
+	for _, alpha := range []float32{1, v.parameters.Alpha} {
+		passTime := time.Now()
+		// Visiting points in random order avoids biasing the graph towards the
+		// order in which the points arrived.
+		order := rand.Perm(len(points))
+		orderQ := utils.ProduceWithContext(ctx, order)
+		numWorkers := runtime.NumCPU()
+		errCs := make([]<-chan error, numWorkers)
+		for i := 0; i < numWorkers; i++ {
+			errCs[i] = utils.SinkWithContext(ctx, orderQ, func(i int) error {
+				return v.bulkPrunePoint(vecs[i], points[i].Vector, alpha)
+			})
+		}
+		if err := <-utils.MergeErrorsWithContext(ctx, errCs...); err != nil {
+			return fmt.Errorf("could not complete bulk build pass with alpha %f: %w", alpha, err)
+		}
+		/* The start node keeps its edges to the existing graph even if it goes
+		 * over the degree bound, similar to how removeInboundEdges saves
+		 * nodes. Otherwise the existing graph could only be reached through
+		 * edges from new points which the pruning does not guarantee. */
+		startNode.edgesMu.Lock()
+		for _, point := range existing {
+			startNode.AddNeighbourIfNotExists(point)
+		}
+		startNode.edgesMu.Unlock()
+		v.logger.Debug().Float32("alpha", alpha).Str("duration", time.Since(passTime).String()).Msg("BulkBuild - Pass")
+	}
+	// ---------------------------
+	v.logger.Debug().Int("count", len(points)).Str("duration", time.Since(startTime).String()).Msg("BulkBuild")
+	return nil
+}
+
+// Picks a random index in [0, n) that is not i, assumes n > 1.
+func randOtherIndex(n, i int) int {
+	j := rand.IntN(n - 1)
+	if j >= i {
+		j++
+	}
+	return j
+}
+
+// A single step of a bulk build pass, the point is searched for in the current
+// graph and its edges are pruned from the visited set and existing neighbours.
+func (v *IndexVamana) bulkPrunePoint(pointA vectorstore.VectorStorePoint, vector []float32, alpha float32) error {
+	_, visitedSet, err := v.greedySearch(vector, 1, v.parameters.SearchSize, nil)
+	if err != nil {
+		return fmt.Errorf("could not greedy search: %w", err)
+	}
+	nodeA, err := v.nodeStore.Get(pointA.Id())
+	if err != nil {
+		return fmt.Errorf("could not get node: %w", err)
+	}
+	if err := nodeA.LoadNeighbours(v.vecStore); err != nil {
+		return fmt.Errorf("could not load node neighbours: %w", err)
+	}
+	// ---------------------------
+	/* We only hold the lock of A while pruning and take a copy of the new
+	 * neighbours. Unlike a fresh insert, A is already reachable so other
+	 * workers may want to lock A whilst adding their reverse edges. Holding
+	 * onto A while locking B could then deadlock. */
+	nodeA.edgesMu.Lock()
+	candidateSet := NewDistSet(visitedSet.Len()+len(nodeA.neighbours), 0, v.vecStore.DistanceFromPoint(pointA))
+	for _, elem := range visitedSet.items {
+		candidateSet.Add(elem.Point)
+	}
+	candidateSet.Add(nodeA.neighbours...)
+	candidateSet.Sort()
+	v.robustPruneWithAlpha(nodeA, candidateSet, alpha)
+	neighbours := make([]vectorstore.VectorStorePoint, len(nodeA.neighbours))
+	copy(neighbours, nodeA.neighbours)
+	nodeA.edgesMu.Unlock()
+	// ---------------------------
+	return v.addReverseEdges(pointA, neighbours, alpha)
+}
@@ -0,0 +1,292 @@
+package vamana
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/semafind/semadb/conversion"
+	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/models"
+	"github.com/semafind/semadb/shard/vectorstore"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/* Picking a search size is a trade-off between speed and recall that depends
+ * on the data. The calibration samples query vectors, computes their exact
+ * nearest neighbours with a flat scan over the vector store and measures the
+ * recall of greedy search at different search sizes. The resulting curve lets
+ * queries ask for a target recall instead of a search size. Calibration is
+ * repeated as the index changes, i.e. after a number of changes relative to the
+ * size of the index when it was last calibrated.
+ *
+ * The query vectors belong to points in the index, so each point is excluded
+ * from its own exact and searched neighbours. Otherwise every query would find
+ * itself and recall would look better than it is. Calibration happens inside a
+ * write, so the number of query vectors is reduced for large indices to keep
+ * the cost of the exact scan bounded. */
+
+const (
+	CALIBRATIONKEY        = "_vamanaCalibration"
+	CALIBRATIONCHANGESKEY = "_vamanaCalibrationChanges"
+)
+
+const (
+	// Minimum number of points and changes before we attempt calibrating
+	CALIBRATIONMINPOINTS = 1000
+	// Number of query vectors sampled from the index
+	CALIBRATIONSAMPLESIZE = 100
+	// Minimum number of samples to produce a meaningful curve
+	CALIBRATIONMINSAMPLES = 10
+	// Recall is measured as recall@k with this k
+	CALIBRATIONLIMIT = 10
+	// Maximum number of distance computations of the exact scan, i.e. query
+	// vectors times points, above which fewer query vectors are used
+	CALIBRATIONSCANBUDGET = 10_000_000
+)
+
+// The search sizes at which recall is measured, these span the valid range of
+// search sizes in the search options.
+var calibrationSearchSizes = []int{25, 30, 35, 40, 45, 50, 55, 60, 65, 70, 75}
+
+// ReadCalibration reads the calibration of a vamana index from its bucket, it
+// returns nil if the index has not been calibrated yet.
+func ReadCalibration(bucket diskstore.ReadOnlyBucket) (*models.VamanaCalibration, error) {
+	calibBytes := bucket.Get([]byte(CALIBRATIONKEY))
+	if calibBytes == nil {
+		return nil, nil
+	}
+	var calib models.VamanaCalibration
+	if err := msgpack.Unmarshal(calibBytes, &calib); err != nil {
+		return nil, fmt.Errorf("could not decode calibration: %w", err)
+	}
+	return &calib, nil
+}
+
+func (v *IndexVamana) writeCalibration() error {
+	if v.calibration != nil {
+		calibBytes, err := msgpack.Marshal(v.calibration)
+		if err != nil {
+			return fmt.Errorf("could not encode calibration: %w", err)
+		}
+		if err := v.bucket.Put([]byte(CALIBRATIONKEY), calibBytes); err != nil {
+			return fmt.Errorf("could not set calibration: %w", err)
+		}
+	}
+	if err := v.bucket.Put([]byte(CALIBRATIONCHANGESKEY), conversion.Uint64ToBytes(v.calibrationChanges)); err != nil {
+		return fmt.Errorf("could not set calibration changes: %w", err)
+	}
+	return nil
+}
+
+// ---------------------------
+
+// Picks a search size for the given target recall using linear interpolation
+// on the calibration curve. Without a calibration the index search size is
+// used.
+func (v *IndexVamana) searchSizeForRecall(targetRecall float32, limit int) int {
+	if v.calibration == nil || len(v.calibration.Curve) == 0 {
+		return max(v.parameters.SearchSize, limit)
+	}
+	curve := v.calibration.Curve
+	searchSize := curve[len(curve)-1].SearchSize
+	for i, cp := range curve {
+		if cp.Recall < targetRecall {
+			continue
+		}
+		searchSize = cp.SearchSize
+		if i > 0 {
+			prev := curve[i-1]
+			ratio := float64(targetRecall-prev.Recall) / float64(cp.Recall-prev.Recall)
+			searchSize = prev.SearchSize + int(math.Ceil(ratio*float64(cp.SearchSize-prev.SearchSize)))
+		}
+		break
+	}
+	return max(searchSize, limit)
+}
+
+// Keeps a uniform sample of the vectors it is given, e.g. to be used as
+// calibration queries and for picking entry points. The point ids are kept
+// alongside so calibration can exclude the points themselves.
+type vectorSampler struct {
+	size    int
+	seen    int
+	ids     []uint64
+	vectors [][]float32
+}
+
+func newVectorSampler(size int) *vectorSampler {
+	return &vectorSampler{size: size, ids: make([]uint64, 0, size), vectors: make([][]float32, 0, size)}
+}
+
+func (vs *vectorSampler) Add(id uint64, vector []float32) {
+	vs.seen++
+	if len(vs.vectors) < vs.size {
+		vs.ids = append(vs.ids, id)
+		vs.vectors = append(vs.vectors, vector)
+		return
+	}
+	if i := rand.IntN(vs.seen); i < vs.size {
+		vs.ids[i] = id
+		vs.vectors[i] = vector
+	}
+}
+
+// Returns a random subset of at most n of the sampled ids and vectors.
+func (vs *vectorSampler) Subset(n int) ([]uint64, [][]float32) {
+	perm := rand.Perm(len(vs.vectors))[:min(n, len(vs.vectors))]
+	ids := make([]uint64, len(perm))
+	vectors := make([][]float32, len(perm))
+	for i, j := range perm {
+		ids[i] = vs.ids[j]
+		vectors[i] = vs.vectors[j]
+	}
+	return ids, vectors
+}
+
+// Samples points uniformly from the whole index rather than the changes of a
+// write, so that what is derived from the sample describes the entire index.
+// The ids are sampled with a scan and only the sampled vectors are read.
+func (v *IndexVamana) sampleIndex(size int) (*vectorSampler, error) {
+	sampler := newVectorSampler(size)
+	err := v.vecStore.ForEach(func(point vectorstore.VectorStorePoint) error {
+		if point.Id() != STARTID {
+			sampler.Add(point.Id(), nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not scan vector store: %w", err)
+	}
+	for i, id := range sampler.ids {
+		vector, err := v.vecStore.GetVector(id)
+		if err != nil {
+			return nil, fmt.Errorf("could not get vector %d: %w", id, err)
+		}
+		sampler.vectors[i] = vector
+	}
+	return sampler, nil
+}
+
+// Number of query vectors to calibrate with such that the exact scan stays
+// within the budget, but not fewer than the minimum needed for a curve.
+func (v *IndexVamana) calibrationSampleSize() int {
+	// The max node id is an upper bound on the number of points
+	pointCount := max(v.maxNodeId.Load(), 1)
+	return max(min(CALIBRATIONSAMPLESIZE, int(CALIBRATIONSCANBUDGET/pointCount)), CALIBRATIONMINSAMPLES)
+}
+
+func (v *IndexVamana) needsCalibration() bool {
+	threshold := uint64(CALIBRATIONMINPOINTS)
+	if v.calibration != nil {
+		threshold = max(threshold, uint64(v.calibration.PointCount/10))
+	}
+	return v.calibrationChanges >= threshold
+}
+
+// Refreshes the entry points and the calibration from a sample of the whole
+// index, not just the changes of the current write.
+func (v *IndexVamana) recalibrate() error {
+	sampler, err := v.sampleIndex(ENTRYPOINTSAMPLESIZE)
+	if err != nil {
+		return fmt.Errorf("could not sample index: %w", err)
+	}
+	if len(sampler.vectors) < CALIBRATIONMINSAMPLES {
+		return nil
+	}
+	/* Entry points are refreshed on the same schedule as the calibration so
+	 * that the measured recall reflects them. */
+	if err := v.refreshEntryPoints(sampler.vectors); err != nil {
+		return fmt.Errorf("could not refresh entry points: %w", err)
+	}
+	calib, err := v.calibrate(sampler.Subset(v.calibrationSampleSize()))
+	if err != nil {
+		return fmt.Errorf("could not calibrate: %w", err)
+	}
+	// Small indices are not worth calibrating, we wait for more points
+	if calib.PointCount >= CALIBRATIONMINPOINTS {
+		v.calibration = calib
+		v.calibrationChanges = 0
+	}
+	return nil
+}
+
+// ---------------------------
+
+// Measures the recall of greedy search at different search sizes using the
+// given query vectors and the ids of the points they belong to.
+func (v *IndexVamana) calibrate(queryIds []uint64, queries [][]float32) (*models.VamanaCalibration, error) {
+	startTime := time.Now()
+	// ---------------------------
+	// Compute exact nearest neighbours with a single flat scan for all queries
+	distFns := make([]vectorstore.PointIdDistFn, len(queries))
+	truths := make([]DistSet, len(queries))
+	for i, q := range queries {
+		distFns[i] = v.vecStore.DistanceFromFloat(q)
+		truths[i] = NewDistSet(CALIBRATIONLIMIT, 0, distFns[i])
+	}
+	pointCount := 0
+	err := v.vecStore.ForEach(func(point vectorstore.VectorStorePoint) error {
+		if point.Id() == STARTID {
+			return nil
+		}
+		pointCount++
+		for i := range truths {
+			if point.Id() == queryIds[i] {
+				continue
+			}
+			truths[i].AddWithLimit(point)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not scan vector store: %w", err)
+	}
+	// ---------------------------
+	calib := &models.VamanaCalibration{
+		PointCount: pointCount,
+		SampleSize: len(queries),
+		Limit:      CALIBRATIONLIMIT,
+		Curve:      make([]models.VamanaCalibrationPoint, 0, len(calibrationSearchSizes)),
+	}
+	var bestRecall float32
+	for _, searchSize := range calibrationSearchSizes {
+		found, total := 0, 0
+		for i, q := range queries {
+			searchSet, _, err := v.greedySearch(q, CALIBRATIONLIMIT, searchSize, nil)
+			if err != nil {
+				return nil, fmt.Errorf("could not greedy search: %w", err)
+			}
+			results := make(map[uint64]struct{}, CALIBRATIONLIMIT)
+			for _, elem := range searchSet.items {
+				if len(results) >= CALIBRATIONLIMIT {
+					break
+				}
+				if elem.Point.Id() != STARTID && elem.Point.Id() != queryIds[i] {
+					results[elem.Point.Id()] = struct{}{}
+				}
+			}
+			for _, elem := range truths[i].items {
+				if _, ok := results[elem.Point.Id()]; ok {
+					found++
+				}
+			}
+			total += len(truths[i].items)
+		}
+		recall := float32(1)
+		if total > 0 {
+			recall = float32(found) / float32(total)
+		}
+		// Larger search sizes visit a superset of nodes, so we keep the curve
+		// monotonic against the noise of approximate search.
+		bestRecall = max(bestRecall, recall)
+		calib.Curve = append(calib.Curve, models.VamanaCalibrationPoint{SearchSize: searchSize, Recall: bestRecall})
+	}
+	for i := range truths {
+		truths[i].Release()
+	}
+	// ---------------------------
+	v.logger.Debug().Int("pointCount", pointCount).Int("sampleSize", len(queries)).Str("duration", time.Since(startTime).String()).Msg("IndexVamana- Calibrate")
+	return calib, nil
+}
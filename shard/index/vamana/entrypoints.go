@@ -0,0 +1,82 @@
+package vamana
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/semafind/semadb/utils"
+)
+
+/* The start node is a random unit vector that is connected to the graph like
+ * any other node. Starting every search from it means search quality depends
+ * on where it happened to end up in the graph. Instead, we cluster a sample of
+ * the vectors and use the nodes closest to the cluster centroids as additional
+ * entry points. With a single cluster this is an approximation of the medoid.
+ * Each search then starts from whichever entry point, including the start
+ * node, is closest to the query. */
+
+const (
+	// Maximum number of entry points, we expect a small number to be enough
+	// because greedy search converges quickly once it is in the right area.
+	MAXENTRYPOINTS = 8
+	// Roughly how many sampled vectors we require per cluster
+	ENTRYPOINTCLUSTERSIZE = 100
+	// Number of vectors sampled from the index to cluster
+	ENTRYPOINTSAMPLESIZE = MAXENTRYPOINTS * ENTRYPOINTCLUSTERSIZE
+)
+
+// EntryPoints returns the node ids that are used as entry points to the graph
+// in addition to the start node.
+func (v *IndexVamana) EntryPoints() []uint64 {
+	return v.entryPoints
+}
+
+func (v *IndexVamana) refreshEntryPoints(samples [][]float32) error {
+	startTime := time.Now()
+	// ---------------------------
+	k := min(MAXENTRYPOINTS, max(len(samples)/ENTRYPOINTCLUSTERSIZE, 1))
+	kmeans := utils.KMeans{
+		K:         k,
+		MaxIter:   50,
+		VectorLen: int(v.parameters.VectorSize),
+	}
+	// KMeans initialises centroids as slices of the data and updates them in
+	// place, so we give it copies to not modify the point vectors.
+	X := make([][]float32, len(samples))
+	for i, sample := range samples {
+		X[i] = slices.Clone(sample)
+	}
+	kmeans.Fit(X)
+	// ---------------------------
+	// Centroids are not nodes in the graph, so we pick the closest node
+	entryPoints := make([]uint64, 0, k)
+	for _, centroid := range kmeans.Centroids {
+		searchSet, _, err := v.greedySearch(centroid, 1, v.parameters.SearchSize, nil)
+		if err != nil {
+			return fmt.Errorf("could not search for entry point: %w", err)
+		}
+		for _, elem := range searchSet.items {
+			if elem.Point.Id() == STARTID {
+				continue
+			}
+			if !slices.Contains(entryPoints, elem.Point.Id()) {
+				entryPoints = append(entryPoints, elem.Point.Id())
+			}
+			break
+		}
+	}
+	v.entryPoints = entryPoints
+	// ---------------------------
+	v.logger.Debug().Int("count", len(entryPoints)).Str("duration", time.Since(startTime).String()).Msg("IndexVamana- RefreshEntryPoints")
+	return nil
+}
+
+// Removes deleted nodes from the entry points, the start node is always a valid
+// fallback until the next refresh.
+func (v *IndexVamana) removeEntryPoints(deleteSet map[uint64]struct{}) {
+	v.entryPoints = slices.DeleteFunc(v.entryPoints, func(id uint64) bool {
+		_, ok := deleteSet[id]
+		return ok
+	})
+}
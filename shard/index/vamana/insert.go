@@ -3,7 +3,9 @@ package vamana
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	"github.com/semafind/semadb/shard/vectorstore"
 	"github.com/semafind/semadb/utils"
 )
 
@@ -34,7 +36,11 @@ func (v *IndexVamana) insertSinglePoint(change IndexVectorChange) error {
 	// A -> C. Then we attempt to add edges from B and C back to A.
 	nodeA.edgesMu.RLock()
 	defer nodeA.edgesMu.RUnlock()
-	for _, nB := range nodeA.neighbours {
+	return v.addReverseEdges(vecA, nodeA.neighbours, v.parameters.Alpha)
+}
+
+func (v *IndexVamana) addReverseEdges(vecA vectorstore.VectorStorePoint, neighbours []vectorstore.VectorStorePoint, alpha float32) error {
+	for _, nB := range neighbours {
 		// So here n = B or C as the example goes
 		nodeB, err := v.nodeStore.Get(nB.Id())
 		if err != nil {
@@ -45,6 +51,11 @@ func (v *IndexVamana) insertSinglePoint(change IndexVectorChange) error {
 		// access to ensure other goroutines don't modify the edges while we
 		// are dealing with them. That is what the locks are for.
 		nodeB.edgesMu.Lock()
+		if slices.Contains(nodeB.edges, vecA.Id()) {
+			// B already points back to A, can happen during bulk build passes
+			nodeB.edgesMu.Unlock()
+			continue
+		}
 		if len(nodeB.edges)+1 > v.parameters.DegreeBound {
 			// We need to prune the neighbour as well to keep the degree bound
 			distFn := v.vecStore.DistanceFromPoint(nB)
@@ -56,7 +67,7 @@ func (v *IndexVamana) insertSinglePoint(change IndexVectorChange) error {
 			candidateSet.Add(nodeB.neighbours...)
 			candidateSet.Add(vecA) // Here we are asking B or C to add A
 			candidateSet.Sort()
-			v.robustPrune(nodeB, candidateSet)
+			v.robustPruneWithAlpha(nodeB, candidateSet, alpha)
 		} else {
 			// ---------------------------
 			// Add the edge
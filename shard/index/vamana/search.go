@@ -4,11 +4,17 @@ import (
 	"fmt"
 
 	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/shard/vectorstore"
 )
 
 func (v *IndexVamana) greedySearch(query []float32, k int, searchSize int, filter *roaring64.Bitmap) (DistSet, DistSet, error) {
+	return v.greedySearchWith(v.vecStore.DistanceFromFloat(query), k, searchSize, filter)
+}
+
+// Performs the greedy search with a custom distance function such as the one
+// used for recommendations which combines multiple example vectors.
+func (v *IndexVamana) greedySearchWith(distFn vectorstore.PointIdDistFn, k int, searchSize int, filter *roaring64.Bitmap) (DistSet, DistSet, error) {
 	// ---------------------------
-	distFn := v.vecStore.DistanceFromFloat(query)
 	// Initialise distance set
 	searchSet := NewDistSet(searchSize, v.maxNodeId.Load(), distFn)
 	/* The faster visited set based on bitmaps is only used for the search and we
@@ -58,6 +64,21 @@ func (v *IndexVamana) greedySearch(query []float32, k int, searchSize int, filte
 	if err != nil {
 		return searchSet, visitedSet, fmt.Errorf("failed to get start point: %w", err)
 	}
+	/* If there are other entry points, we start from the one closest to the
+	 * query. Only the closest is added to avoid spending the search size on
+	 * entry points in unrelated parts of the graph. */
+	if len(v.entryPoints) > 0 {
+		entryPoints, err := v.vecStore.GetMany(v.entryPoints...)
+		if err != nil {
+			return searchSet, visitedSet, fmt.Errorf("failed to get entry points: %w", err)
+		}
+		minDist := distFn(sn)
+		for _, ep := range entryPoints {
+			if dist := distFn(ep); dist < minDist {
+				sn, minDist = ep, dist
+			}
+		}
+	}
 	searchSet.AddWithLimit(sn)
 	// ---------------------------
 	/* This loop looks to curate the closest nodes to the query vector along the
@@ -104,6 +125,12 @@ func (v *IndexVamana) greedySearch(query []float32, k int, searchSize int, filte
 // Update the edges of the node optimistically based on the candidateSet.
 // NOTE: requires node edges to be locked.
 func (iv *IndexVamana) robustPrune(node *graphNode, candidateSet DistSet) {
+	iv.robustPruneWithAlpha(node, candidateSet, iv.parameters.Alpha)
+}
+
+// Same as robustPrune but with a custom alpha, the bulk build first prunes with
+// alpha 1 to obtain a sparse graph before relaxing it to the configured alpha.
+func (iv *IndexVamana) robustPruneWithAlpha(node *graphNode, candidateSet DistSet, alpha float32) {
 	// ---------------------------
 	node.ClearNeighbours() // Reset edges / neighbours
 	// ---------------------------
@@ -129,7 +156,7 @@ func (iv *IndexVamana) robustPrune(node *graphNode, candidateSet DistSet) {
 				continue
 			}
 			// ---------------------------
-			if iv.parameters.Alpha*distFn(nextElem.Point) < nextElem.Distance {
+			if alpha*distFn(nextElem.Point) < nextElem.Distance {
 				candidateSet.items[j].pruneRemoved = true
 			}
 		}
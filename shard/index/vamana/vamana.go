@@ -28,7 +28,8 @@ import (
 const STARTID = 1
 
 const (
-	MAXNODEIDKEY = "_vamanaMaxNodeId"
+	MAXNODEIDKEY   = "_vamanaMaxNodeId"
+	ENTRYPOINTSKEY = "_vamanaEntryPoints"
 )
 
 // ---------------------------
@@ -46,6 +47,14 @@ type IndexVamana struct {
 	 * either, bitsets can resize if we get it wrong but we try to keep it in
 	 * sync anyway. */
 	maxNodeId atomic.Uint64
+	// Additional entry points to the graph besides the start node, see
+	// entrypoints.go
+	entryPoints []uint64
+	// ---------------------------
+	// Measured search size to recall curve and the number of changes since
+	// it was computed, see calibrate.go
+	calibration        *models.VamanaCalibration
+	calibrationChanges uint64
 	// ---------------------------
 	bucket diskstore.Bucket
 	logger zerolog.Logger
@@ -75,11 +84,29 @@ func NewIndexVamana(name string, params models.IndexVectorVamanaParameters, buck
 	if maxNodeIdVal := bucket.Get([]byte(MAXNODEIDKEY)); maxNodeIdVal != nil {
 		index.maxNodeId.Store(conversion.BytesToUint64(maxNodeIdVal))
 	}
+	if entryPointsVal := bucket.Get([]byte(ENTRYPOINTSKEY)); entryPointsVal != nil {
+		index.entryPoints = conversion.BytesToEdgeList(entryPointsVal)
+	}
+	// ---------------------------
+	calib, err := ReadCalibration(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("could not read calibration: %w", err)
+	}
+	index.calibration = calib
+	if changesVal := bucket.Get([]byte(CALIBRATIONCHANGESKEY)); changesVal != nil {
+		index.calibrationChanges = conversion.BytesToUint64(changesVal)
+	}
 	logger.Debug().Uint64("maxNodeId", index.maxNodeId.Load()).Msg("IndexVamana- New")
 	// ---------------------------
 	return index, nil
 }
 
+// Calibration returns the current search size to recall curve of the index,
+// nil if the index has not been calibrated yet.
+func (v *IndexVamana) Calibration() *models.VamanaCalibration {
+	return v.calibration
+}
+
 func (v *IndexVamana) SizeInMemory() int64 {
 	return v.vecStore.SizeInMemory() + v.nodeStore.SizeInMemory()
 }
@@ -90,6 +117,12 @@ func (v *IndexVamana) UpdateBucket(bucket diskstore.Bucket) {
 	v.nodeStore.UpdateBucket(bucket)
 }
 
+// GetVector returns the stored vector of the point, used for example by
+// recommendations to search near existing points.
+func (v *IndexVamana) GetVector(id uint64) ([]float32, error) {
+	return v.vecStore.GetVector(id)
+}
+
 func (v *IndexVamana) setupStartNode() error {
 	// ---------------------------
 	if v.vecStore.Exists(STARTID) {
@@ -145,6 +178,12 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 	updatedPoints := make([]IndexVectorChange, 0)
 	deletedPointsIds := make([]uint64, 0)
 	toRemoveInBoundNodeIds := make(map[uint64]struct{})
+	/* In bulk mode new points are collected instead of being streamed to the
+	 * insert workers so the graph can be built in one go once we know all of
+	 * them. */
+	bulk := isBulkBuild(ctx)
+	bulkPoints := make([]IndexVectorChange, 0)
+	numChanges := 0
 	// ---------------------------
 	insertQ, distributeErrC := utils.TransformWithContext(ctx, pointQueue, func(point IndexVectorChange) (out IndexVectorChange, skip bool, err error) {
 		if point.Id == STARTID {
@@ -157,6 +196,9 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 		}
 		// What operation is this?
 		exists := v.vecStore.Exists(point.Id)
+		if exists || point.Vector != nil {
+			numChanges++
+		}
 		switch {
 		case !exists && point.Vector == nil:
 			// Skip, nothing to do
@@ -166,6 +208,11 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 			if point.Id > v.maxNodeId.Load() {
 				v.maxNodeId.Store(point.Id)
 			}
+			if bulk {
+				bulkPoints = append(bulkPoints, point)
+				skip = true
+				return
+			}
 			skip = false
 			out = point
 		case exists && point.Vector != nil:
@@ -187,7 +234,8 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 	 * the same cache. As opposed to multiple requests queuing to get access
 	 * to the shared cache. Internal concurrency (workers) vs external
 	 * concurrency (user requests). */
-	numWorkers := runtime.NumCPU() - 1 // We leave 1 core for the main thread
+	// We leave 1 core for the main thread but need at least one worker
+	numWorkers := max(runtime.NumCPU()-1, 1)
 	errCs := make([]<-chan error, numWorkers+1)
 	// ---------------------------
 	for i := 0; i < numWorkers; i++ {
@@ -199,6 +247,11 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 	if err := <-utils.MergeErrorsWithContext(ctx, errCs...); err != nil {
 		return fmt.Errorf("could not distribute or insert points: %w", err)
 	}
+	if len(bulkPoints) > 0 {
+		if err := v.bulkInsert(ctx, bulkPoints); err != nil {
+			return fmt.Errorf("could not bulk insert points: %w", err)
+		}
+	}
 	// ---------------------------
 	/* Initially we doubled downed on the assumption that more often than not
 	 * there would be bidirectional edges between points. This is, however,
@@ -224,6 +277,7 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 		if err := v.removeInboundEdges(toRemoveInBoundNodeIds); err != nil {
 			return fmt.Errorf("could not remove inbound edges: %w", err)
 		}
+		v.removeEntryPoints(toRemoveInBoundNodeIds)
 	}
 	/* Mark as deleted. We do this here after the inbound edges have been
 	 * removed because we don't want to remove nodes while insertion is
@@ -259,6 +313,13 @@ func (v *IndexVamana) insertUpdateDelete(ctx context.Context, pointQueue <-chan
 		return fmt.Errorf("could not fit vector store: %w", err)
 	}
 	// ---------------------------
+	v.calibrationChanges += uint64(numChanges)
+	if v.needsCalibration() {
+		if err := v.recalibrate(); err != nil {
+			return err
+		}
+	}
+	// ---------------------------
 	return v.flush()
 }
 
@@ -272,15 +333,55 @@ func (v *IndexVamana) flush() error {
 	if err := v.bucket.Put([]byte(MAXNODEIDKEY), conversion.Uint64ToBytes(v.maxNodeId.Load())); err != nil {
 		return fmt.Errorf("could not set max node id: %w", err)
 	}
+	if len(v.entryPoints) == 0 {
+		if err := v.bucket.Delete([]byte(ENTRYPOINTSKEY)); err != nil {
+			return fmt.Errorf("could not delete entry points: %w", err)
+		}
+	} else if err := v.bucket.Put([]byte(ENTRYPOINTSKEY), conversion.EdgeListToBytes(v.entryPoints)); err != nil {
+		return fmt.Errorf("could not set entry points: %w", err)
+	}
+	if err := v.writeCalibration(); err != nil {
+		return fmt.Errorf("could not write calibration: %w", err)
+	}
 	return nil
 }
 
+type nodesVisitedContextKey struct{}
+
+// WithNodesVisited makes Search store the number of graph nodes it visited in
+// the counter, used for profiling searches.
+func WithNodesVisited(ctx context.Context, counter *int) context.Context {
+	return context.WithValue(ctx, nodesVisitedContextKey{}, counter)
+}
+
 func (v *IndexVamana) Search(ctx context.Context, query models.SearchVectorVamanaOptions, filter *roaring64.Bitmap) (*roaring64.Bitmap, []models.SearchResult, error) {
 	startTime := time.Now()
-	searchSet, _, err := v.greedySearch(query.Vector, query.Limit, query.SearchSize, filter)
+	searchSize := query.SearchSize
+	if query.TargetRecall > 0 {
+		searchSize = v.searchSizeForRecall(query.TargetRecall, query.Limit)
+	}
+	distFn := v.vecStore.DistanceFromFloat(query.Vector)
+	if query.Operator == models.OperatorRecommend {
+		if query.Recommend == nil || len(query.Recommend.PositiveVectors) == 0 {
+			return roaring64.New(), nil, nil
+		}
+		distFn = vectorstore.RecommendDistance(v.vecStore, query.Recommend.Strategy, query.Recommend.PositiveVectors, query.Recommend.NegativeVectors)
+		// The limit may include the examples which are excluded upstream
+		searchSize = max(searchSize, query.Limit)
+	}
+	// Maximal marginal relevance picks the limit results from all the
+	// candidates, so a filtered search has to keep searchSize of them
+	k := query.Limit
+	if query.MMR != nil {
+		k = searchSize
+	}
+	searchSet, visitedSet, err := v.greedySearchWith(distFn, k, searchSize, filter)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not perform graph search: %w", err)
 	}
+	if counter, ok := ctx.Value(nodesVisitedContextKey{}).(*int); ok {
+		*counter = visitedSet.Len()
+	}
 	v.logger.Debug().Str("component", "shard").Str("duration", time.Since(startTime).String()).Msg("SearchPoints - GreedySearch")
 	results := make([]models.SearchResult, 0, min(len(searchSet.items), query.Limit))
 	resultSet := roaring64.New()
@@ -290,6 +391,14 @@ func (v *IndexVamana) Search(ctx context.Context, query models.SearchVectorVaman
 		weight = *query.Weight
 	}
 	// ---------------------------
+	if query.MMR != nil {
+		results = v.mmrResults(searchSet, query.Limit, query.MMR.Lambda, weight)
+		for _, r := range results {
+			resultSet.Add(r.NodeId)
+		}
+		return resultSet, results, nil
+	}
+	// ---------------------------
 	for _, elem := range searchSet.items {
 		if elem.Point.Id() == STARTID {
 			continue
@@ -308,3 +417,27 @@ func (v *IndexVamana) Search(ctx context.Context, query models.SearchVectorVaman
 	// ---------------------------
 	return resultSet, results, err
 }
+
+// Picks the limit results from all the search candidates with maximal marginal
+// relevance, the hybrid score is the weighted relevance score.
+func (v *IndexVamana) mmrResults(searchSet DistSet, limit int, lambda, weight float32) []models.SearchResult {
+	points := make([]vectorstore.VectorStorePoint, 0, len(searchSet.items))
+	queryDists := make([]float32, 0, len(searchSet.items))
+	for _, elem := range searchSet.items {
+		if elem.Point.Id() == STARTID {
+			continue
+		}
+		points = append(points, elem.Point)
+		queryDists = append(queryDists, elem.Distance)
+	}
+	order, scores := vectorstore.MaximalMarginalRelevance(v.vecStore, points, queryDists, lambda, limit)
+	results := make([]models.SearchResult, len(order))
+	for i, idx := range order {
+		results[i] = models.SearchResult{
+			NodeId:      points[idx].Id(),
+			Distance:    &queryDists[idx],
+			HybridScore: scores[i] * weight,
+		}
+	}
+	return results
+}
@@ -74,6 +74,71 @@ func Test_Insert(t *testing.T) {
 	}
 }
 
+func Test_BulkInsert(t *testing.T) {
+	for _, size := range []int{1, 100, 4242} {
+		t.Run(fmt.Sprintf("Size=%d", size), func(t *testing.T) {
+			inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+			require.NoError(t, err)
+			ctx := WithBulkBuild(context.Background())
+			rps := randPoints(size, 0)
+			in := utils.ProduceWithContext(ctx, rps)
+			errC := inv.InsertUpdateDelete(ctx, in)
+			require.NoError(t, <-errC)
+			checkConnectivity(t, inv.nodeStore, size)
+			// ---------------------------
+			// Each point should find itself
+			for _, rp := range rps[:min(size, 100)] {
+				s := models.SearchVectorVamanaOptions{
+					Vector:     rp.Vector,
+					SearchSize: 75,
+					Limit:      1,
+				}
+				_, res, err := inv.Search(ctx, s, nil)
+				require.NoError(t, err)
+				require.Len(t, res, 1)
+				require.Equal(t, rp.Id, res[0].NodeId)
+			}
+		})
+	}
+}
+
+func Test_BulkInsertExisting(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	ctx := context.Background()
+	in := utils.ProduceWithContext(ctx, randPoints(50, 0))
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	// ---------------------------
+	// Bulk insert on top of an existing graph with an update and a delete
+	ctx = WithBulkBuild(ctx)
+	changes := randPoints(2000, 50)
+	changes = append(changes, IndexVectorChange{Id: 2, Vector: []float32{0.5, 0.5}})
+	changes = append(changes, IndexVectorChange{Id: 3, Vector: nil})
+	in = utils.ProduceWithContext(ctx, changes)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	checkConnectivity(t, inv.nodeStore, 2049)
+}
+
+func Test_BulkInsertKeepsStartEdges(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	ctx := context.Background()
+	in := utils.ProduceWithContext(ctx, randPoints(50, 0))
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	startNode, err := inv.nodeStore.Get(STARTID)
+	require.NoError(t, err)
+	existingEdges := slices.Clone(startNode.edges)
+	// ---------------------------
+	ctx = WithBulkBuild(ctx)
+	in = utils.ProduceWithContext(ctx, randPoints(2000, 50))
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	checkConnectivity(t, inv.nodeStore, 2050)
+	// The existing graph is still reachable through the start node
+	for _, edge := range existingEdges {
+		require.Contains(t, startNode.edges, edge)
+	}
+}
+
 func Test_InvalidIdInsert(t *testing.T) {
 	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
 	require.NoError(t, err)
@@ -192,6 +257,10 @@ func Test_Flush(t *testing.T) {
 			maxId = int(conversion.BytesToUint64(value))
 			return nil
 		}
+		if bytes.Equal(key, []byte(CALIBRATIONCHANGESKEY)) {
+			require.EqualValues(t, 42, conversion.BytesToUint64(value))
+			return nil
+		}
 		suffix := key[len(key)-1]
 		switch suffix {
 		case 'v':
@@ -210,6 +279,191 @@ func Test_Flush(t *testing.T) {
 	require.Equal(t, 43, maxId)
 }
 
+func Test_Calibrate(t *testing.T) {
+	bucket := diskstore.NewMemBucket(false)
+	inv, err := NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	ctx := context.Background()
+	// Not enough points to calibrate
+	in := utils.ProduceWithContext(ctx, randPoints(500, 0))
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	require.Nil(t, inv.Calibration())
+	require.Equal(t, 75, inv.searchSizeForRecall(0.9, 10))
+	// ---------------------------
+	in = utils.ProduceWithContext(ctx, randPoints(1500, 500))
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	calib := inv.Calibration()
+	require.NotNil(t, calib)
+	require.Equal(t, 2000, calib.PointCount)
+	require.Equal(t, CALIBRATIONSAMPLESIZE, calib.SampleSize)
+	require.Len(t, calib.Curve, len(calibrationSearchSizes))
+	for i, cp := range calib.Curve {
+		require.Equal(t, calibrationSearchSizes[i], cp.SearchSize)
+		require.GreaterOrEqual(t, cp.Recall, float32(0))
+		require.LessOrEqual(t, cp.Recall, float32(1))
+		if i > 0 {
+			require.GreaterOrEqual(t, cp.Recall, calib.Curve[i-1].Recall)
+		}
+	}
+	require.Greater(t, calib.Curve[len(calib.Curve)-1].Recall, float32(0.9))
+	// ---------------------------
+	// Persisted with the index
+	inv, err = NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	require.Equal(t, calib, inv.Calibration())
+	// ---------------------------
+	// Search with target recall
+	rp := randPoints(1, 0)[0]
+	s := models.SearchVectorVamanaOptions{
+		Vector:       rp.Vector,
+		TargetRecall: 0.9,
+		Limit:        10,
+	}
+	_, res, err := inv.Search(ctx, s, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+}
+
+func Test_CalibrateExcludesQueryPoint(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	ctx := context.Background()
+	rps := randPoints(20, 0)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, rps)))
+	// Cut the graph so that search can only find the query point itself
+	queryPoint, err := inv.vecStore.Get(rps[0].Id)
+	require.NoError(t, err)
+	startNode, err := inv.nodeStore.Get(STARTID)
+	require.NoError(t, err)
+	startNode.ClearNeighbours()
+	startNode.AddNeighbour(queryPoint)
+	queryNode, err := inv.nodeStore.Get(rps[0].Id)
+	require.NoError(t, err)
+	queryNode.ClearNeighbours()
+	calib, err := inv.calibrate([]uint64{rps[0].Id}, [][]float32{rps[0].Vector})
+	require.NoError(t, err)
+	for _, cp := range calib.Curve {
+		require.Equal(t, float32(0), cp.Recall)
+	}
+}
+
+func Test_SampleIndex(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	ctx := context.Background()
+	rps := randPoints(300, 0)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, rps[:200])))
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, rps[200:])))
+	// Points of earlier writes are sampled as well
+	sampler, err := inv.sampleIndex(500)
+	require.NoError(t, err)
+	require.Len(t, sampler.ids, 300)
+	for _, rp := range rps {
+		i := slices.Index(sampler.ids, rp.Id)
+		require.GreaterOrEqual(t, i, 0)
+		require.Equal(t, rp.Vector, sampler.vectors[i])
+	}
+	require.NotContains(t, sampler.ids, uint64(STARTID))
+	sampler, err = inv.sampleIndex(50)
+	require.NoError(t, err)
+	require.Len(t, sampler.ids, 50)
+	require.Len(t, sampler.vectors, 50)
+}
+
+func Test_CalibrationSampleSize(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	require.Equal(t, CALIBRATIONSAMPLESIZE, inv.calibrationSampleSize())
+	inv.maxNodeId.Store(200_000)
+	require.Equal(t, 50, inv.calibrationSampleSize())
+	inv.maxNodeId.Store(100_000_000)
+	require.Equal(t, CALIBRATIONMINSAMPLES, inv.calibrationSampleSize())
+}
+
+func Test_EntryPoints(t *testing.T) {
+	bucket := diskstore.NewMemBucket(false)
+	inv, err := NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	ctx := context.Background()
+	rps := randPoints(1200, 0)
+	in := utils.ProduceWithContext(ctx, rps)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	entryPoints := inv.EntryPoints()
+	require.NotEmpty(t, entryPoints)
+	require.LessOrEqual(t, len(entryPoints), MAXENTRYPOINTS)
+	require.NotContains(t, entryPoints, uint64(STARTID))
+	// ---------------------------
+	// Persisted with the index
+	inv, err = NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	require.Equal(t, entryPoints, inv.EntryPoints())
+	// ---------------------------
+	// Points are still found from the entry points
+	for _, rp := range rps[:100] {
+		s := models.SearchVectorVamanaOptions{
+			Vector:     rp.Vector,
+			SearchSize: 75,
+			Limit:      1,
+		}
+		_, res, err := inv.Search(ctx, s, nil)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, float32(0), *res[0].Distance)
+	}
+	// ---------------------------
+	// Deleting an entry point removes it
+	in = utils.ProduceWithContext(ctx, []IndexVectorChange{{Id: entryPoints[0], Vector: nil}})
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	require.NotContains(t, inv.EntryPoints(), entryPoints[0])
+	inv, err = NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	require.Equal(t, entryPoints[1:], inv.EntryPoints())
+}
+
+func Test_EntryPointsCoverIndex(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, randPoints(1200, 0))))
+	// The next write is far away from the first
+	rps := randPoints(1200, 1200)
+	for _, rp := range rps {
+		rp.Vector[0] += 10
+		rp.Vector[1] += 10
+	}
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, rps)))
+	// The entry points are refreshed from both writes
+	near, far := 0, 0
+	for _, id := range inv.EntryPoints() {
+		vector, err := inv.vecStore.GetVector(id)
+		require.NoError(t, err)
+		if vector[0] < 5 {
+			near++
+		} else {
+			far++
+		}
+	}
+	require.Greater(t, near, 0)
+	require.Greater(t, far, 0)
+}
+
+func Test_SearchSizeForRecall(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	inv.calibration = &models.VamanaCalibration{
+		Curve: []models.VamanaCalibrationPoint{
+			{SearchSize: 25, Recall: 0.8},
+			{SearchSize: 50, Recall: 0.9},
+			{SearchSize: 75, Recall: 0.95},
+		},
+	}
+	require.Equal(t, 25, inv.searchSizeForRecall(0.5, 10))
+	require.Equal(t, 30, inv.searchSizeForRecall(0.5, 30))
+	require.Equal(t, 38, inv.searchSizeForRecall(0.85, 10))
+	require.Equal(t, 50, inv.searchSizeForRecall(0.9, 10))
+	require.Equal(t, 75, inv.searchSizeForRecall(0.99, 10))
+}
+
 func Test_EmptySearch(t *testing.T) {
 	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
 	require.NoError(t, err)
@@ -274,3 +528,186 @@ func Test_FilterSearch(t *testing.T) {
 	require.Len(t, res, 3)
 	require.Equal(t, rp.Id, res[0].NodeId)
 }
+
+func Test_VerifyRepair(t *testing.T) {
+	bucket := diskstore.NewMemBucket(false)
+	inv, err := NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	ctx := context.Background()
+	rps := randPoints(100, 0)
+	in := utils.ProduceWithContext(ctx, rps)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	pointNodeIds := roaring64.New()
+	for _, rp := range rps {
+		pointNodeIds.Add(rp.Id)
+	}
+	report, err := inv.Verify(pointNodeIds)
+	require.NoError(t, err)
+	require.True(t, report.IsValid())
+	require.Equal(t, 101, report.NodeCount)
+	require.Equal(t, 101, report.VectorCount)
+	require.LessOrEqual(t, report.MaxDegree, vamanaParams.DegreeBound)
+	// ---------------------------
+	// Break the graph behind the back of the index
+	unreachableId := uint64(1000)
+	_, err = inv.vecStore.Set(unreachableId, []float32{0.5, 0.5})
+	require.NoError(t, err)
+	neighbour, err := inv.vecStore.Get(rps[5].Id)
+	require.NoError(t, err)
+	node := &graphNode{Id: unreachableId}
+	node.ClearNeighbours()
+	node.AddNeighbour(neighbour)
+	inv.nodeStore.Put(unreachableId, node)
+	noVectorId := rps[0].Id
+	require.NoError(t, inv.vecStore.Delete(noVectorId))
+	noNodeId := rps[1].Id
+	require.NoError(t, inv.nodeStore.Delete(noNodeId))
+	require.NoError(t, inv.flush())
+	// ---------------------------
+	inv, err = NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	report, err = inv.Verify(pointNodeIds)
+	require.NoError(t, err)
+	require.False(t, report.IsValid())
+	require.Equal(t, []uint64{unreachableId}, report.Unreachable)
+	require.Equal(t, []uint64{unreachableId}, report.MissingPoints)
+	require.Equal(t, []uint64{noVectorId}, report.NodesWithoutVectors)
+	require.Equal(t, []uint64{noNodeId}, report.VectorsWithoutNodes)
+	require.Greater(t, report.DanglingEdges, 0)
+	require.NotEmpty(t, report.DanglingNodes)
+	// ---------------------------
+	require.NoError(t, inv.Repair(report))
+	inv, err = NewIndexVamana("test", vamanaParams, bucket)
+	require.NoError(t, err)
+	report, err = inv.Verify(pointNodeIds)
+	require.NoError(t, err)
+	require.Empty(t, report.Unreachable)
+	require.Empty(t, report.NodesWithoutVectors)
+	require.Empty(t, report.VectorsWithoutNodes)
+	require.Equal(t, 0, report.DanglingEdges)
+	require.LessOrEqual(t, report.MaxDegree, vamanaParams.DegreeBound)
+	// The point store is not repaired
+	require.Equal(t, []uint64{unreachableId}, report.MissingPoints)
+	// The saved node is found by search
+	s := models.SearchVectorVamanaOptions{
+		Vector:     []float32{0.5, 0.5},
+		SearchSize: 75,
+		Limit:      1,
+	}
+	_, res, err := inv.Search(ctx, s, nil)
+	require.NoError(t, err)
+	require.Equal(t, unreachableId, res[0].NodeId)
+}
+
+func Test_RepairThenInsert(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	ctx := context.Background()
+	rps := randPoints(100, 0)
+	in := utils.ProduceWithContext(ctx, rps)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	// ---------------------------
+	// More unreachable nodes than the start node has room for
+	unreachable := randPoints(100, 1000)
+	for _, rp := range unreachable {
+		_, err = inv.vecStore.Set(rp.Id, rp.Vector)
+		require.NoError(t, err)
+		node := &graphNode{Id: rp.Id}
+		node.ClearNeighbours()
+		inv.nodeStore.Put(rp.Id, node)
+	}
+	report, err := inv.Verify(nil)
+	require.NoError(t, err)
+	require.Len(t, report.Unreachable, len(unreachable))
+	require.NoError(t, inv.Repair(report))
+	report, err = inv.Verify(nil)
+	require.NoError(t, err)
+	require.True(t, report.IsValid())
+	require.LessOrEqual(t, report.MaxDegree, vamanaParams.DegreeBound)
+	// ---------------------------
+	// Later inserts prune nodes again which must not disconnect the repaired ones
+	rps = randPoints(200, 2000)
+	in = utils.ProduceWithContext(ctx, rps)
+	require.NoError(t, <-inv.InsertUpdateDelete(ctx, in))
+	report, err = inv.Verify(nil)
+	require.NoError(t, err)
+	require.True(t, report.IsValid())
+	require.Equal(t, 401, report.NodeCount)
+	require.LessOrEqual(t, report.MaxDegree, vamanaParams.DegreeBound)
+	checkConnectivity(t, inv.nodeStore, 400)
+}
+
+func Test_SearchMMR(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	// Near duplicates around the origin and points spread further away
+	points := make([]IndexVectorChange, 0, 20)
+	for i := 0; i < 10; i++ {
+		points = append(points, IndexVectorChange{
+			Id:     uint64(i + 2),
+			Vector: []float32{float32(i) * 0.001, 0},
+		})
+		points = append(points, IndexVectorChange{
+			Id:     uint64(i + 12),
+			Vector: []float32{0.5 + float32(i)*0.1, 1 - float32(i)*0.1},
+		})
+	}
+	ctx := context.Background()
+	errC := inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, points))
+	require.NoError(t, <-errC)
+	// ---------------------------
+	s := models.SearchVectorVamanaOptions{
+		Vector:     []float32{0, 0},
+		SearchSize: 75,
+		Limit:      5,
+		MMR:        &models.SearchMMROptions{Lambda: 0.3},
+	}
+	rSet, res, err := inv.Search(ctx, s, nil)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	require.EqualValues(t, 5, rSet.GetCardinality())
+	// The nearest point, then the farthest and the far end of the spread
+	require.Equal(t, uint64(2), res[0].NodeId)
+	require.Equal(t, uint64(21), res[1].NodeId)
+	require.Equal(t, uint64(12), res[2].NodeId)
+	for i := 1; i < len(res); i++ {
+		require.LessOrEqual(t, res[i].HybridScore, res[i-1].HybridScore)
+	}
+}
+
+func Test_SearchMMRFilter(t *testing.T) {
+	inv, err := NewIndexVamana("test", vamanaParams, diskstore.NewMemBucket(false))
+	require.NoError(t, err)
+	points := make([]IndexVectorChange, 0, 20)
+	for i := 0; i < 10; i++ {
+		points = append(points, IndexVectorChange{
+			Id:     uint64(i + 2),
+			Vector: []float32{float32(i) * 0.001, 0},
+		})
+		points = append(points, IndexVectorChange{
+			Id:     uint64(i + 12),
+			Vector: []float32{0.5 + float32(i)*0.1, 1 - float32(i)*0.1},
+		})
+	}
+	ctx := context.Background()
+	errC := inv.InsertUpdateDelete(ctx, utils.ProduceWithContext(ctx, points))
+	require.NoError(t, <-errC)
+	// ---------------------------
+	s := models.SearchVectorVamanaOptions{
+		Vector:     []float32{0, 0},
+		SearchSize: 75,
+		Limit:      5,
+		MMR:        &models.SearchMMROptions{Lambda: 0.3},
+	}
+	// Exclude the nearest point, the spread points must still be candidates
+	// rather than only the limit nearest duplicates
+	filter := roaring64.New()
+	filter.AddRange(3, 22)
+	rSet, res, err := inv.Search(ctx, s, filter)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	require.False(t, rSet.Contains(2))
+	require.Equal(t, uint64(3), res[0].NodeId)
+	require.Equal(t, uint64(21), res[1].NodeId)
+	require.Equal(t, uint64(12), res[2].NodeId)
+}
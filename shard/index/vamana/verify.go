@@ -0,0 +1,296 @@
+package vamana
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/semafind/semadb/shard/vectorstore"
+)
+
+/* The graph should never end up in an inconsistent state but crashes or bugs
+ * might leave it with edges to nodes that no longer exist, nodes without
+ * vectors or nodes that cannot be reached from the start node. The latter are
+ * the most problematic because they are silently never returned in search
+ * results. Verify walks the whole graph to find these and Repair fixes them up
+ * by linking unreachable nodes back in as if they were inserted, so that every
+ * node stays within the degree bound. */
+
+// VerifyReport summarises the state of the graph of a vamana index.
+type VerifyReport struct {
+	NodeCount   int `json:"nodeCount"`
+	VectorCount int `json:"vectorCount"`
+	// Number of nodes with a given out degree
+	DegreeCounts map[int]int `json:"degreeCounts"`
+	MinDegree    int         `json:"minDegree"`
+	MaxDegree    int         `json:"maxDegree"`
+	MeanDegree   float32     `json:"meanDegree"`
+	// Edges pointing to nodes that have no vector, and the nodes they start from
+	DanglingEdges int      `json:"danglingEdges"`
+	DanglingNodes []uint64 `json:"danglingNodes"`
+	// Nodes without vectors and vice versa
+	NodesWithoutVectors []uint64 `json:"nodesWithoutVectors"`
+	VectorsWithoutNodes []uint64 `json:"vectorsWithoutNodes"`
+	// Nodes that cannot be reached from the start node
+	Unreachable []uint64 `json:"unreachable"`
+	// Nodes that do not have a corresponding point in the point store, only
+	// checked if the point node ids are given
+	MissingPoints []uint64 `json:"missingPoints"`
+}
+
+// IsValid returns true if no problems were found with the graph.
+func (r *VerifyReport) IsValid() bool {
+	return r.DanglingEdges == 0 &&
+		len(r.NodesWithoutVectors) == 0 &&
+		len(r.VectorsWithoutNodes) == 0 &&
+		len(r.Unreachable) == 0 &&
+		len(r.MissingPoints) == 0
+}
+
+// Verify checks the consistency of the graph. If pointNodeIds is not nil, the
+// nodes are also checked against the node ids in the point store. Points
+// without a vector in this index are not reported because vectors are optional.
+// NOTE: This loads the entire graph into the cache.
+func (v *IndexVamana) Verify(pointNodeIds *roaring64.Bitmap) (*VerifyReport, error) {
+	startTime := time.Now()
+	report := &VerifyReport{
+		DegreeCounts:        make(map[int]int),
+		DanglingNodes:       make([]uint64, 0),
+		NodesWithoutVectors: make([]uint64, 0),
+		VectorsWithoutNodes: make([]uint64, 0),
+		Unreachable:         make([]uint64, 0),
+		MissingPoints:       make([]uint64, 0),
+	}
+	// ---------------------------
+	vectorIds := make(map[uint64]struct{})
+	err := v.vecStore.ForEach(func(point vectorstore.VectorStorePoint) error {
+		vectorIds[point.Id()] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not scan vector store: %w", err)
+	}
+	report.VectorCount = len(vectorIds)
+	// ---------------------------
+	graph := make(map[uint64][]uint64)
+	totalDegree := 0
+	report.MinDegree = -1
+	err = v.nodeStore.ForEach(func(id uint64, node *graphNode) error {
+		node.edgesMu.RLock()
+		edges := slices.Clone(node.edges)
+		node.edgesMu.RUnlock()
+		graph[id] = edges
+		// ---------------------------
+		degree := len(edges)
+		report.DegreeCounts[degree]++
+		totalDegree += degree
+		if report.MinDegree == -1 || degree < report.MinDegree {
+			report.MinDegree = degree
+		}
+		report.MaxDegree = max(report.MaxDegree, degree)
+		// ---------------------------
+		if _, ok := vectorIds[id]; !ok {
+			report.NodesWithoutVectors = append(report.NodesWithoutVectors, id)
+		}
+		dangling := 0
+		for _, edgeId := range edges {
+			if _, ok := vectorIds[edgeId]; !ok {
+				dangling++
+			}
+		}
+		if dangling > 0 {
+			report.DanglingEdges += dangling
+			report.DanglingNodes = append(report.DanglingNodes, id)
+		}
+		// ---------------------------
+		if pointNodeIds != nil && id != STARTID && !pointNodeIds.Contains(id) {
+			report.MissingPoints = append(report.MissingPoints, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not scan node store: %w", err)
+	}
+	report.NodeCount = len(graph)
+	report.MinDegree = max(report.MinDegree, 0)
+	if report.NodeCount > 0 {
+		report.MeanDegree = float32(totalDegree) / float32(report.NodeCount)
+	}
+	for id := range vectorIds {
+		if _, ok := graph[id]; !ok {
+			report.VectorsWithoutNodes = append(report.VectorsWithoutNodes, id)
+		}
+	}
+	// ---------------------------
+	// Breadth first traversal from the start node
+	reachable := map[uint64]struct{}{STARTID: {}}
+	queue := []uint64{STARTID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, edgeId := range graph[id] {
+			if _, ok := reachable[edgeId]; ok {
+				continue
+			}
+			reachable[edgeId] = struct{}{}
+			queue = append(queue, edgeId)
+		}
+	}
+	for id := range graph {
+		if _, ok := reachable[id]; !ok {
+			report.Unreachable = append(report.Unreachable, id)
+		}
+	}
+	// ---------------------------
+	// Sorted for stable output
+	slices.Sort(report.DanglingNodes)
+	slices.Sort(report.NodesWithoutVectors)
+	slices.Sort(report.VectorsWithoutNodes)
+	slices.Sort(report.Unreachable)
+	slices.Sort(report.MissingPoints)
+	v.logger.Debug().Int("nodeCount", report.NodeCount).Bool("valid", report.IsValid()).Str("duration", time.Since(startTime).String()).Msg("IndexVamana- Verify")
+	return report, nil
+}
+
+// Repair fixes the problems found in the given report and flushes the changes
+// to the bucket. Nodes without vectors are deleted, dangling edges are removed
+// and vectors without nodes get an empty node. Nodes that end up unreachable
+// are then linked back into the graph like inserts. Missing points are not
+// repaired because the point store is not owned by the index.
+func (v *IndexVamana) Repair(report *VerifyReport) error {
+	startTime := time.Now()
+	// ---------------------------
+	if len(report.NodesWithoutVectors) > 0 {
+		if err := v.nodeStore.Delete(report.NodesWithoutVectors...); err != nil {
+			return fmt.Errorf("could not delete nodes without vectors: %w", err)
+		}
+		v.removeEntryPoints(toSet(report.NodesWithoutVectors))
+	}
+	for _, id := range report.DanglingNodes {
+		if slices.Contains(report.NodesWithoutVectors, id) {
+			continue
+		}
+		node, err := v.nodeStore.Get(id)
+		if err != nil {
+			return fmt.Errorf("could not get dangling node %d: %w", id, err)
+		}
+		node.edgesMu.Lock()
+		neighbours, err := v.vecStore.GetMany(node.edges...)
+		if err != nil {
+			node.edgesMu.Unlock()
+			return fmt.Errorf("could not get neighbours of dangling node %d: %w", id, err)
+		}
+		// GetMany skips the missing vectors, so we rebuild the edges from it
+		node.ClearNeighbours()
+		for _, n := range neighbours {
+			node.AddNeighbour(n)
+		}
+		node.edgesMu.Unlock()
+	}
+	for _, id := range report.VectorsWithoutNodes {
+		node := &graphNode{Id: id}
+		node.ClearNeighbours()
+		v.nodeStore.Put(id, node)
+	}
+	// ---------------------------
+	/* Removing nodes and edges may have disconnected further nodes, so we
+	 * verify again to find out what is unreachable now. Linking them all from
+	 * the start node would exceed its degree bound and the next prune of the
+	 * start node would drop them again. */
+	afterReport, err := v.Verify(nil)
+	if err != nil {
+		return fmt.Errorf("could not verify after repair: %w", err)
+	}
+	stillUnreachable := 0
+	for _, id := range afterReport.Unreachable {
+		if id == STARTID {
+			continue
+		}
+		linked, err := v.relinkNode(id)
+		if err != nil {
+			return fmt.Errorf("could not relink node %d: %w", id, err)
+		}
+		if !linked {
+			stillUnreachable++
+		}
+	}
+	// ---------------------------
+	v.logger.Debug().Int("relinked", len(afterReport.Unreachable)-stillUnreachable).Int("unreachable", stillUnreachable).Str("duration", time.Since(startTime).String()).Msg("IndexVamana- Repair")
+	return v.flush()
+}
+
+// Links an unreachable node back into the graph the way insertSinglePoint does
+// so that its edges and the reverse edges respect the degree bound. If pruning
+// drops every reverse edge, the nearest visited node with room links to it
+// instead. It returns false if the node could not be linked.
+func (v *IndexVamana) relinkNode(id uint64) (bool, error) {
+	vector, err := v.vecStore.GetVector(id)
+	if err != nil {
+		return false, fmt.Errorf("could not get vector: %w", err)
+	}
+	point, err := v.vecStore.Get(id)
+	if err != nil {
+		return false, fmt.Errorf("could not get point: %w", err)
+	}
+	node, err := v.nodeStore.Get(id)
+	if err != nil {
+		return false, fmt.Errorf("could not get node: %w", err)
+	}
+	// Search only visits reachable nodes as the node has no inbound edges
+	_, visitedSet, err := v.greedySearch(vector, 1, v.parameters.SearchSize, nil)
+	if err != nil {
+		return false, fmt.Errorf("could not greedy search: %w", err)
+	}
+	node.edgesMu.Lock()
+	v.robustPrune(node, visitedSet)
+	neighbours := slices.Clone(node.neighbours)
+	node.edgesMu.Unlock()
+	if err := v.addReverseEdges(point, neighbours, v.parameters.Alpha); err != nil {
+		return false, fmt.Errorf("could not add reverse edges: %w", err)
+	}
+	// ---------------------------
+	for _, n := range neighbours {
+		nodeB, err := v.nodeStore.Get(n.Id())
+		if err != nil {
+			return false, fmt.Errorf("could not get neighbour: %w", err)
+		}
+		nodeB.edgesMu.RLock()
+		linked := slices.Contains(nodeB.edges, id)
+		nodeB.edgesMu.RUnlock()
+		if linked {
+			return true, nil
+		}
+	}
+	for _, elem := range visitedSet.items {
+		if elem.Point.Id() == id {
+			continue
+		}
+		nodeB, err := v.nodeStore.Get(elem.Point.Id())
+		if err != nil {
+			return false, fmt.Errorf("could not get visited node: %w", err)
+		}
+		nodeB.edgesMu.Lock()
+		hasRoom := len(nodeB.edges) < v.parameters.DegreeBound
+		if hasRoom {
+			if err := nodeB.LoadNeighbours(v.vecStore); err != nil {
+				nodeB.edgesMu.Unlock()
+				return false, fmt.Errorf("could not load visited node neighbours: %w", err)
+			}
+			nodeB.AddNeighbourIfNotExists(point)
+		}
+		nodeB.edgesMu.Unlock()
+		if hasRoom {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func toSet(ids []uint64) map[uint64]struct{} {
+	set := make(map[uint64]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,178 @@
+package shard
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/semafind/semadb/expression"
+	"github.com/semafind/semadb/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Computes the value of a decay function, 1 within offset of the origin and
+// the decay value at scale further away. The formulas follow the decay
+// functions of Elasticsearch.
+func decayValue(d models.ScoreDecay, origin, value float64) float64 {
+	decay := d.Decay
+	if decay == 0 {
+		decay = 0.5
+	}
+	dist := max(0, math.Abs(value-origin)-d.Offset)
+	switch d.Function {
+	case models.ScoreDecayGauss:
+		sigmaSquared := -d.Scale * d.Scale / (2 * math.Log(decay))
+		return math.Exp(-dist * dist / (2 * sigmaSquared))
+	case models.ScoreDecayExponential:
+		return math.Exp(math.Log(decay) / d.Scale * dist)
+	case models.ScoreDecayLinear:
+		s := d.Scale / (1 - decay)
+		return max(0, (s-dist)/s)
+	}
+	return 1
+}
+
+// Computes the value of a field value factor, negative values are treated as
+// 0 for the modifiers.
+func fieldValueFactorValue(f models.ScoreFieldValueFactor, value float64) float64 {
+	factor := f.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	value *= factor
+	switch f.Modifier {
+	case models.ScoreModifierLog1p:
+		return math.Log1p(max(0, value))
+	case models.ScoreModifierSqrt:
+		return math.Sqrt(max(0, value))
+	}
+	return value
+}
+
+// Converts a decoded msgpack number to float64.
+func numberToFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// Adjusts the hybrid scores of the results using the score functions on their
+// point data. Functions on points without the property are skipped unless a
+// missing value is given.
+func applyScoreFunctions(dec *msgpack.Decoder, results []models.SearchResult, sf models.ScoreFunctions) error {
+	// The origin is resolved once so every point is compared to the same time
+	now := float64(time.Now().Unix())
+	for i, r := range results {
+		for _, f := range sf.Functions {
+			var value float64
+			var found bool
+			if len(r.Point.Data) > 0 {
+				dec.Reset(bytes.NewReader(r.Point.Data))
+				res, err := dec.Query(f.Property)
+				if err != nil {
+					return fmt.Errorf("could not query point data, %s: %w", f.Property, err)
+				}
+				if len(res) > 0 {
+					value, found = numberToFloat(res[0])
+				}
+			}
+			// ---------------------------
+			var fValue float64
+			switch {
+			case f.Decay != nil:
+				if !found {
+					continue
+				}
+				origin := f.Decay.Origin
+				if f.Decay.OriginNow {
+					origin = now
+				}
+				fValue = decayValue(*f.Decay, origin, value)
+			case f.FieldValueFactor != nil:
+				if !found {
+					if f.FieldValueFactor.Missing == nil {
+						continue
+					}
+					value = *f.FieldValueFactor.Missing
+				}
+				fValue = fieldValueFactorValue(*f.FieldValueFactor, value)
+			}
+			if f.Weight != nil {
+				fValue *= float64(*f.Weight)
+			}
+			// ---------------------------
+			if sf.Mode == models.ScoreModeMultiply {
+				results[i].HybridScore *= float32(fValue)
+			} else {
+				results[i].HybridScore += float32(fValue)
+			}
+		}
+	}
+	return nil
+}
+
+// Computes the custom score of each result from the score expression. The
+// score is left nil if a value the expression uses is missing or the result is
+// not a finite number.
+func computeCustomScores(dec *msgpack.Decoder, results []models.SearchResult, expr *expression.Expr) error {
+	vars := make(map[string]float64, len(expr.Variables()))
+	for i, r := range results {
+		clear(vars)
+		for _, name := range expr.Variables() {
+			switch name {
+			case "_distance":
+				if r.Distance != nil {
+					vars[name] = float64(*r.Distance)
+				}
+			case "_score":
+				if r.Score != nil {
+					vars[name] = float64(*r.Score)
+				}
+			case "_hybridScore":
+				vars[name] = float64(r.HybridScore)
+			default:
+				if len(r.Point.Data) == 0 {
+					continue
+				}
+				dec.Reset(bytes.NewReader(r.Point.Data))
+				res, err := dec.Query(name)
+				if err != nil {
+					return fmt.Errorf("could not query point data, %s: %w", name, err)
+				}
+				if len(res) == 0 {
+					continue
+				}
+				if value, ok := numberToFloat(res[0]); ok {
+					vars[name] = value
+				}
+			}
+		}
+		// Values outside the float32 range would become infinite
+		if value, ok := expr.Eval(vars); ok && math.Abs(value) <= math.MaxFloat32 {
+			score := float32(value)
+			results[i].CustomScore = &score
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package shard
+
+import (
+	"testing"
+
+	"github.com/semafind/semadb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decayValue(t *testing.T) {
+	tests := []struct {
+		function string
+		value    float64
+		expected float64
+	}{
+		{models.ScoreDecayGauss, 10, 1},
+		{models.ScoreDecayGauss, 11, 1},
+		{models.ScoreDecayGauss, 15, 0.5},
+		{models.ScoreDecayGauss, 5, 0.5},
+		{models.ScoreDecayGauss, 19, 0.0625},
+		{models.ScoreDecayExponential, 15, 0.5},
+		{models.ScoreDecayExponential, 19, 0.25},
+		{models.ScoreDecayLinear, 15, 0.5},
+		{models.ScoreDecayLinear, 20, 0},
+		{models.ScoreDecayLinear, 30, 0},
+	}
+	for _, tt := range tests {
+		d := models.ScoreDecay{
+			Function: tt.function,
+			Scale:    4,
+			Offset:   1,
+		}
+		require.InDelta(t, tt.expected, decayValue(d, 10, tt.value), 1e-6, "%s %f", tt.function, tt.value)
+	}
+	// A custom decay is reached at scale from the offset
+	d := models.ScoreDecay{Function: models.ScoreDecayGauss, Scale: 4, Decay: 0.2}
+	require.InDelta(t, 0.2, decayValue(d, 0, -4), 1e-6)
+}
+
+func Test_fieldValueFactorValue(t *testing.T) {
+	require.Equal(t, 3.0, fieldValueFactorValue(models.ScoreFieldValueFactor{}, 3))
+	require.Equal(t, 6.0, fieldValueFactorValue(models.ScoreFieldValueFactor{Factor: 2}, 3))
+	require.Equal(t, 2.0, fieldValueFactorValue(models.ScoreFieldValueFactor{Modifier: models.ScoreModifierSqrt}, 4))
+	require.InDelta(t, 2.397895, fieldValueFactorValue(models.ScoreFieldValueFactor{Modifier: models.ScoreModifierLog1p}, 10), 1e-6)
+	require.Equal(t, 0.0, fieldValueFactorValue(models.ScoreFieldValueFactor{Modifier: models.ScoreModifierLog1p}, -5))
+}
@@ -2,8 +2,10 @@ package shard
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,9 +14,12 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/semafind/semadb/conversion"
 	"github.com/semafind/semadb/diskstore"
+	"github.com/semafind/semadb/distance"
+	"github.com/semafind/semadb/expression"
 	"github.com/semafind/semadb/models"
 	"github.com/semafind/semadb/shard/cache"
 	"github.com/semafind/semadb/shard/index"
+	"github.com/semafind/semadb/shard/index/vamana"
 	"github.com/semafind/semadb/shard/pointstore"
 	"github.com/semafind/semadb/utils"
 	"github.com/vmihailenco/msgpack/v5"
@@ -61,9 +66,33 @@ func NewShard(dbFile string, collection models.Collection, cacheManager *cache.M
 		cacheManager: cacheManager,
 		logger:       log.With().Str("component", "shard").Str("name", dbFile).Logger(),
 	}
+	if err := shard.backfillIndices(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not backfill indices: %w", err)
+	}
 	return shard, nil
 }
 
+// backfillIndices fills in what indices created by earlier versions did not
+// record. It runs once when the shard is opened, before any point changes, so
+// that neither searches nor writes have to.
+func (s *Shard) backfillIndices() error {
+	var missing bool
+	err := s.db.Read(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, nil, s.dbFile, s.collection.IndexSchema)
+		var err error
+		missing, err = im.ArrayLengthsMissing()
+		return err
+	})
+	if err != nil || !missing {
+		return err
+	}
+	return s.db.Write(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, nil, s.dbFile, s.collection.IndexSchema)
+		return im.BackfillArrayLengths(context.Background())
+	})
+}
+
 func (s *Shard) Close() error {
 	s.cacheManager.Release(s.dbFile)
 	return s.db.Close()
@@ -98,6 +127,9 @@ func changePointCount(bucket diskstore.Bucket, change int) error {
 type shardInfo struct {
 	PointCount uint64
 	Size       int64 // Size of the shard database file
+	// Calibration of vamana indices keyed by property name, only calibrated
+	// indices are included
+	VamanaCalibrations map[string]models.VamanaCalibration
 }
 
 func (s *Shard) Info() (si shardInfo, err error) {
@@ -123,6 +155,24 @@ func (s *Shard) Info() (si shardInfo, err error) {
 			si.PointCount = conversion.BytesToUint64(countBytes)
 		}
 		// ---------------------------
+		si.VamanaCalibrations = make(map[string]models.VamanaCalibration)
+		for propName, params := range s.collection.IndexSchema {
+			if params.Type != models.IndexTypeVectorVamana {
+				continue
+			}
+			ib, err := bm.Get(fmt.Sprintf("index/%s/%s", params.Type, propName))
+			if err != nil {
+				return fmt.Errorf("could not read index bucket for %s: %w", propName, err)
+			}
+			calib, err := vamana.ReadCalibration(ib)
+			if err != nil {
+				return fmt.Errorf("could not read calibration for %s: %w", propName, err)
+			}
+			if calib != nil {
+				si.VamanaCalibrations[propName] = *calib
+			}
+		}
+		// ---------------------------
 		return nil
 	})
 	return
@@ -131,8 +181,19 @@ func (s *Shard) Info() (si shardInfo, err error) {
 // ---------------------------
 
 func (s *Shard) InsertPoints(points []models.Point) error {
+	return s.insertPoints(points, false)
+}
+
+// BulkInsertPoints is the same as InsertPoints but hints the indices to build
+// in bulk, e.g. for an initial load. Inserts are also treated as bulk if the
+// batch is larger than the existing shard.
+func (s *Shard) BulkInsertPoints(points []models.Point) error {
+	return s.insertPoints(points, true)
+}
+
+func (s *Shard) insertPoints(points []models.Point, bulk bool) error {
 	// ---------------------------
-	s.logger.Debug().Int("count", len(points)).Msg("InsertPoints")
+	s.logger.Debug().Int("count", len(points)).Bool("bulk", bulk).Msg("InsertPoints")
 	// ---------------------------
 	// Check for duplicate ids
 	ids := make(map[uuid.UUID]struct{}, len(points))
@@ -166,6 +227,20 @@ func (s *Shard) InsertPoints(points []models.Point) error {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 		// ---------------------------
+		/* When the batch dominates the shard, e.g. an initial load into an
+		 * empty shard, building the indices in bulk is much faster than
+		 * inserting points one by one into an ever changing graph. */
+		if !bulk {
+			var pointCount uint64
+			if countBytes := bInternal.Get(POINTCOUNTKEY); countBytes != nil {
+				pointCount = conversion.BytesToUint64(countBytes)
+			}
+			bulk = uint64(len(points)) > pointCount
+		}
+		if bulk {
+			ctx = index.WithBulkInsert(ctx)
+		}
+		// ---------------------------
 		pointsQ := utils.ProduceWithContext(ctx, points)
 		indexQ, indexQErrC := utils.TransformWithContext(ctx, pointsQ, func(point models.Point) (ipc index.IndexPointChange, skip bool, err error) {
 			// ---------------------------
@@ -326,55 +401,301 @@ func (s *Shard) UpdatePoints(points []models.Point) ([]uuid.UUID, error) {
 
 // ---------------------------
 
-func (s *Shard) SearchPoints(searchRequest models.SearchRequest) ([]models.SearchResult, error) {
+// Returns the distance functions of sort options with distanceTo, nil if
+// there are none.
+func sortDistanceFns(schema models.IndexSchema, sortOpts []models.SortOption) ([]distance.FloatDistFunc, error) {
+	var distFns []distance.FloatDistFunc
+	for i, so := range sortOpts {
+		if so.DistanceTo == nil {
+			continue
+		}
+		vectorSize, metric, ok := schema.VectorParameters(so.Property)
+		if !ok {
+			return nil, fmt.Errorf("property %s is not a vector index", so.Property)
+		}
+		if len(so.DistanceTo) != int(vectorSize) {
+			return nil, fmt.Errorf("expected distanceTo vector of size %d for %s, got %d", vectorSize, so.Property, len(so.DistanceTo))
+		}
+		distFn, err := distance.GetVectorDistanceFn(metric)
+		if err != nil {
+			return nil, fmt.Errorf("could not get distance function for %s: %w", so.Property, err)
+		}
+		if distFns == nil {
+			distFns = make([]distance.FloatDistFunc, len(sortOpts))
+		}
+		distFns[i] = distFn
+	}
+	return distFns, nil
+}
+
+// Computes the distances of the vectors in the point data to the distanceTo
+// vectors of the sort options, leaving nil where the point has no vector.
+func computeSortDistances(dec *msgpack.Decoder, data []byte, sortOpts []models.SortOption, distFns []distance.FloatDistFunc) ([]*float32, error) {
+	distances := make([]*float32, len(sortOpts))
+	for i, so := range sortOpts {
+		if distFns[i] == nil {
+			continue
+		}
+		dec.Reset(bytes.NewReader(data))
+		res, err := dec.Query(so.Property)
+		if err != nil {
+			return nil, fmt.Errorf("could not query point data, %s: %w", so.Property, err)
+		}
+		if len(res) == 0 {
+			continue
+		}
+		vector, err := models.ConvertToVector(res[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not convert %s to vector: %w", so.Property, err)
+		}
+		if len(vector) != len(so.DistanceTo) {
+			continue
+		}
+		dist := distFns[i](so.DistanceTo, vector)
+		distances[i] = &dist
+	}
+	return distances, nil
+}
+
+// Sets the vector of the queried property on each result from the point data
+// so that the cluster can diversify the merged results.
+func attachMMRVectors(dec *msgpack.Decoder, results []models.SearchResult, property string) error {
+	for i, r := range results {
+		if len(r.Point.Data) == 0 {
+			continue
+		}
+		dec.Reset(bytes.NewReader(r.Point.Data))
+		res, err := dec.Query(property)
+		if err != nil {
+			return fmt.Errorf("could not query point data, %s: %w", property, err)
+		}
+		if len(res) == 0 {
+			continue
+		}
+		vector, err := models.ConvertToVector(res[0])
+		if err != nil {
+			return fmt.Errorf("could not convert %s to vector: %w", property, err)
+		}
+		results[i].MMRVector = vector
+	}
+	return nil
+}
+
+type profileContextKey struct{}
+
+// WithProfile makes SearchPoints record where its time is spent into the
+// profile, including the profile of the query.
+func WithProfile(ctx context.Context, profile *models.ShardProfile) context.Context {
+	return context.WithValue(ctx, profileContextKey{}, profile)
+}
+
+func (s *Shard) SearchPoints(ctx context.Context, searchRequest models.SearchRequest) ([]models.SearchResult, error) {
+	// ---------------------------
+	profile, _ := ctx.Value(profileContextKey{}).(*models.ShardProfile)
+	if profile != nil {
+		startTime := time.Now()
+		defer func() {
+			profile.TotalMs = time.Since(startTime).Seconds() * 1000
+		}()
+	}
+	p, err := s.planSearch(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
 	// ---------------------------
+	var finalResults []models.SearchResult
+	cacheTx := s.cacheManager.NewTransaction()
+	err = s.db.Read(func(bm diskstore.BucketManager) error {
+		res, err := p.run(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+		finalResults = res
+		return err
+	})
+	if err != nil {
+		cacheTx.Commit(true)
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	cacheTx.Commit(false)
+	if profile != nil {
+		profile.CacheHits, profile.CacheMisses = cacheTx.Stats()
+	}
+	return s.finishSearch(p, finalResults)
+}
+
+// SearchPointsBatch runs multiple searches within a single read transaction
+// of the shard. The results and errors are in the order of the requests, a
+// failing search does not affect the others.
+func (s *Shard) SearchPointsBatch(ctx context.Context, searchRequests []models.SearchRequest) ([][]models.SearchResult, []error) {
+	plans := make([]searchPlan, len(searchRequests))
+	results := make([][]models.SearchResult, len(searchRequests))
+	errs := make([]error, len(searchRequests))
+	for i, sr := range searchRequests {
+		plans[i], errs[i] = s.planSearch(ctx, sr)
+	}
+	// ---------------------------
+	err := s.db.Read(func(bm diskstore.BucketManager) error {
+		for i, p := range plans {
+			if errs[i] != nil {
+				continue
+			}
+			/* Each search gets its own cache transaction, a failed transaction
+			 * refuses further use so sharing one would fail the later searches
+			 * as well. */
+			cacheTx := s.cacheManager.NewTransaction()
+			results[i], errs[i] = p.run(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+			cacheTx.Commit(errs[i] != nil)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("search failed: %w", errs[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range errs {
+			errs[i] = fmt.Errorf("search failed: %w", err)
+		}
+		return make([][]models.SearchResult, len(searchRequests)), errs
+	}
+	// ---------------------------
+	for i, p := range plans {
+		if errs[i] != nil {
+			results[i] = nil
+			continue
+		}
+		results[i], errs[i] = s.finishSearch(p, results[i])
+	}
+	return results, errs
+}
+
+// searchPlan holds what is worked out from a search request before reading
+// from the shard so that multiple searches can share a read transaction.
+type searchPlan struct {
+	ctx         context.Context
+	request     models.SearchRequest
+	profile     *models.ShardProfile
+	sortDistFns []distance.FloatDistFunc
+	scoreExpr   *expression.Expr
+	mergeMMR    *models.SearchMMROptions
+	withData    bool
+}
+
+func (s *Shard) planSearch(ctx context.Context, searchRequest models.SearchRequest) (searchPlan, error) {
+	p := searchPlan{ctx: ctx, request: searchRequest}
+	p.profile, _ = ctx.Value(profileContextKey{}).(*models.ShardProfile)
+	if p.profile != nil {
+		p.profile.Query = &models.QueryProfile{}
+		p.ctx = index.WithProfile(p.ctx, p.profile.Query)
+	}
+	// ---------------------------
+	var err error
+	p.sortDistFns, err = sortDistanceFns(s.collection.IndexSchema, searchRequest.Sort)
+	if err != nil {
+		return p, fmt.Errorf("could not sort by distance: %w", err)
+	}
+	if searchRequest.ScoreExpression != "" {
+		if p.scoreExpr, err = expression.Parse(searchRequest.ScoreExpression); err != nil {
+			return p, fmt.Errorf("could not parse score expression: %w", err)
+		}
+	}
+	// The cluster diversifies the merged results with the vectors of the points
+	p.mergeMMR, _ = searchRequest.MergeMMR()
+	// The vectors to sort by distance and the values for score functions and
+	// expressions are read from the point data
+	p.withData = len(searchRequest.Select) > 0 || p.sortDistFns != nil || searchRequest.ScoreFunctions != nil || p.scoreExpr != nil || p.mergeMMR != nil
+	// ---------------------------
+	if searchRequest.Explain {
+		p.ctx = index.WithExplain(p.ctx)
+	}
+	return p, nil
+}
+
+// run searches the indices and backfills the points within a read transaction.
+func (p searchPlan) run(bm diskstore.BucketManager, cacheTx *cache.Transaction, cacheRoot string, indexSchema models.IndexSchema) ([]models.SearchResult, error) {
 	/* rSet contains all the points to return, results contains any ordered
 	 * search results. For example a basic integer equals search pops up in
 	 * rSet, a vector search pops up in rSet and results. */
 	var finalResults []models.SearchResult
 	// ---------------------------
-	cacheTx := s.cacheManager.NewTransaction()
-	err := s.db.Read(func(bm diskstore.BucketManager) error {
-		// ---------------------------
-		bPoints, err := bm.Get(pointstore.POINTSBUCKETNAME)
+	bPoints, err := bm.Get(pointstore.POINTSBUCKETNAME)
+	if err != nil {
+		return nil, fmt.Errorf("could not get points bucket: %w", err)
+	}
+	// ---------------------------
+	im := index.NewIndexManager(bm, cacheTx, cacheRoot, indexSchema)
+	rSet, results, err := im.Search(p.ctx, p.request.Query)
+	if err != nil {
+		return nil, fmt.Errorf("could not perform search: %w", err)
+	}
+	// ---------------------------
+	// Backfill point UUID and data
+	backfillStart := time.Now()
+	for _, r := range results {
+		sp, err := pointstore.GetPointByNodeId(bPoints, r.NodeId, p.withData)
 		if err != nil {
-			return fmt.Errorf("could not get points bucket: %w", err)
+			return nil, fmt.Errorf("could not get point by node id %d: %w", r.NodeId, err)
 		}
-		// ---------------------------
-		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
-		rSet, results, err := im.Search(context.Background(), searchRequest.Query)
+		r.Point = sp.Point
+		rSet.Remove(r.NodeId)
+		finalResults = append(finalResults, r)
+	}
+	// If any points are missing in the results from rSet, we need to append them
+	it := rSet.Iterator()
+	for it.HasNext() {
+		nodeId := it.Next()
+		sp, err := pointstore.GetPointByNodeId(bPoints, nodeId, p.withData)
 		if err != nil {
-			return fmt.Errorf("could not perform search: %w", err)
+			return nil, fmt.Errorf("could not get point by node id %d: %w", nodeId, err)
 		}
-		// ---------------------------
-		// Backfill point UUID and data
-		for _, r := range results {
-			sp, err := pointstore.GetPointByNodeId(bPoints, r.NodeId, len(searchRequest.Select) > 0)
-			if err != nil {
-				return fmt.Errorf("could not get point by node id %d: %w", r.NodeId, err)
+		finalResults = append(finalResults, models.SearchResult{NodeId: nodeId, Point: sp.Point})
+	}
+	if p.profile != nil {
+		p.profile.BackfillMs = time.Since(backfillStart).Seconds() * 1000
+	}
+	// Points only matched by filters have nothing to explain but the shard
+	if p.request.Explain {
+		for i := range finalResults {
+			if finalResults[i].Explanation == nil {
+				finalResults[i].Explanation = &models.SearchExplanation{}
 			}
-			r.Point = sp.Point
-			rSet.Remove(r.NodeId)
-			finalResults = append(finalResults, r)
 		}
-		// If any points are missing in the results from rSet, we need to append them
-		it := rSet.Iterator()
-		for it.HasNext() {
-			nodeId := it.Next()
-			sp, err := pointstore.GetPointByNodeId(bPoints, nodeId, len(searchRequest.Select) > 0)
-			if err != nil {
-				return fmt.Errorf("could not get point by node id %d: %w", nodeId, err)
-			}
-			finalResults = append(finalResults, models.SearchResult{NodeId: nodeId, Point: sp.Point})
+	}
+	// ---------------------------
+	return finalResults, nil
+}
+
+// finishSearch scores, selects, sorts and paginates the backfilled results.
+func (s *Shard) finishSearch(p searchPlan, finalResults []models.SearchResult) ([]models.SearchResult, error) {
+	searchRequest := p.request
+	sortDistFns, scoreExpr, mergeMMR := p.sortDistFns, p.scoreExpr, p.mergeMMR
+	// ---------------------------
+	/* Score functions adjust the hybrid scores of the points found by the
+	 * search, so the results are reordered unless there is an explicit sort. */
+	if searchRequest.ScoreFunctions != nil {
+		if err := applyScoreFunctions(msgpack.NewDecoder(nil), finalResults, *searchRequest.ScoreFunctions); err != nil {
+			return nil, fmt.Errorf("could not apply score functions: %w", err)
+		}
+		if len(searchRequest.Sort) == 0 {
+			slices.SortStableFunc(finalResults, func(a, b models.SearchResult) int {
+				return cmp.Compare(b.HybridScore, a.HybridScore)
+			})
+		}
+	}
+	// The custom score can use the hybrid score adjusted by score functions
+	if scoreExpr != nil {
+		if err := computeCustomScores(msgpack.NewDecoder(nil), finalResults, scoreExpr); err != nil {
+			return nil, fmt.Errorf("could not compute custom scores: %w", err)
+		}
+	}
+	if mergeMMR != nil {
+		if err := attachMMRVectors(msgpack.NewDecoder(nil), finalResults, searchRequest.Query.Property); err != nil {
+			return nil, fmt.Errorf("could not attach mmr vectors: %w", err)
+		}
+	}
+	if len(searchRequest.Select) == 0 && len(searchRequest.Sort) == 0 {
+		// Any data was only fetched for scoring
+		for i := range finalResults {
+			finalResults[i].Data = nil
 		}
-		// ---------------------------
-		return nil
-	})
-	if err != nil {
-		cacheTx.Commit(true)
-		return nil, fmt.Errorf("search failed: %w", err)
 	}
-	cacheTx.Commit(false)
 	// ---------------------------
 	/* Select and sort, if we have * (star) then we don't need to do anything and
 	 * let upstream handle decoding the whole data point. Otherwise we need to
@@ -393,6 +714,13 @@ func (s *Shard) SearchPoints(searchRequest models.SearchRequest) ([]models.Searc
 				// No data to select from
 				continue
 			}
+			if sortDistFns != nil {
+				sortDistances, err := computeSortDistances(dec, r.Point.Data, searchRequest.Sort, sortDistFns)
+				if err != nil {
+					return nil, fmt.Errorf("could not compute sort distances: %w", err)
+				}
+				finalResults[i].SortDistances = sortDistances
+			}
 			// E.g. ["name", "age"]
 			for _, p := range searchRequest.Select {
 				// E.g. p = "name" or "*" (star)
@@ -473,79 +801,238 @@ func (s *Shard) SearchPoints(searchRequest models.SearchRequest) ([]models.Searc
 
 // ---------------------------
 
+// CountPoints returns the number of points in this shard that match the query.
+// Only the indices are searched, the points themselves are not read.
+func (s *Shard) CountPoints(ctx context.Context, query models.Query) (uint64, error) {
+	var count uint64
+	cacheTx := s.cacheManager.NewTransaction()
+	err := s.db.Read(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+		rSet, _, err := im.Search(index.WithCount(ctx), query)
+		if err != nil {
+			return fmt.Errorf("could not perform search: %w", err)
+		}
+		if rSet != nil {
+			count = rSet.GetCardinality()
+		}
+		return nil
+	})
+	if err != nil {
+		cacheTx.Commit(true)
+		return 0, fmt.Errorf("count failed: %w", err)
+	}
+	cacheTx.Commit(false)
+	return count, nil
+}
+
+// ---------------------------
+
+// Suggest returns the most frequent terms of a text index that complete the
+// prefix in this shard.
+func (s *Shard) Suggest(ctx context.Context, req models.SuggestRequest) ([]models.Suggestion, error) {
+	var suggestions []models.Suggestion
+	cacheTx := s.cacheManager.NewTransaction()
+	err := s.db.Read(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+		res, err := im.Suggest(ctx, req)
+		suggestions = res
+		return err
+	})
+	if err != nil {
+		cacheTx.Commit(true)
+		return nil, fmt.Errorf("suggest failed: %w", err)
+	}
+	cacheTx.Commit(false)
+	return suggestions, nil
+}
+
+// MoreLikeThisTerms returns the terms that best describe the text property of
+// the point, nil if the point is not in this shard.
+func (s *Shard) MoreLikeThisTerms(property string, pointId uuid.UUID, maxTerms int) ([]models.WeightedTerm, error) {
+	var terms []models.WeightedTerm
+	cacheTx := s.cacheManager.NewTransaction()
+	err := s.db.Read(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+		res, err := im.MoreLikeThisTerms(property, pointId, maxTerms)
+		terms = res
+		return err
+	})
+	if err != nil {
+		cacheTx.Commit(true)
+		return nil, fmt.Errorf("more like this terms failed: %w", err)
+	}
+	cacheTx.Commit(false)
+	return terms, nil
+}
+
+// RecommendVectors returns the stored vectors of the example points of the
+// vector property that are in this shard.
+func (s *Shard) RecommendVectors(property string, pointIds []uuid.UUID) (map[uuid.UUID][]float32, error) {
+	var vectors map[uuid.UUID][]float32
+	cacheTx := s.cacheManager.NewTransaction()
+	err := s.db.Read(func(bm diskstore.BucketManager) error {
+		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+		res, err := im.RecommendVectors(property, pointIds)
+		vectors = res
+		return err
+	})
+	if err != nil {
+		cacheTx.Commit(true)
+		return nil, fmt.Errorf("recommend vectors failed: %w", err)
+	}
+	cacheTx.Commit(false)
+	return vectors, nil
+}
+
+// ---------------------------
+
 func (s *Shard) DeletePoints(deleteSet map[uuid.UUID]struct{}) ([]uuid.UUID, error) {
 	// ---------------------------
-	deletedIds := make([]uuid.UUID, 0, len(deleteSet))
+	var deletedIds []uuid.UUID
+	cacheTx := s.cacheManager.NewTransaction()
+	err := s.db.Write(func(bm diskstore.BucketManager) error {
+		ids, err := s.deletePoints(bm, cacheTx, deleteSet)
+		deletedIds = ids
+		return err
+	})
+	if err != nil {
+		cacheTx.Commit(true)
+		return nil, fmt.Errorf("could not delete points: %w", err)
+	}
+	cacheTx.Commit(false)
+	return deletedIds, nil
+}
+
+// The points matching a delete query are deleted in chunks to bound the
+// memory used by the deletion pipeline.
+const DELETECHUNKSIZE = 1000
+
+// DeletePointsByQuery deletes the points matching the query within a single
+// write transaction and returns the number of deleted points.
+func (s *Shard) DeletePointsByQuery(ctx context.Context, query models.Query) (int, error) {
 	// ---------------------------
+	deletedCount := 0
 	cacheTx := s.cacheManager.NewTransaction()
 	err := s.db.Write(func(bm diskstore.BucketManager) error {
 		bPoints, err := bm.Get(pointstore.POINTSBUCKETNAME)
 		if err != nil {
 			return fmt.Errorf("could not get write points bucket: %w", err)
 		}
-		bInternal, err := bm.Get(INTERNALBUCKETNAME)
-		if err != nil {
-			return fmt.Errorf("could not get write internal bucket: %w", err)
-		}
 		// ---------------------------
-		nodeCounter, err := NewIdCounter(bInternal, FREENODEIDSKEY, NEXTFREENODEIDKEY)
+		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+		rSet, _, err := im.Search(index.WithCount(ctx), query)
 		if err != nil {
-			return fmt.Errorf("could not create id counter: %w", err)
+			return fmt.Errorf("could not perform search: %w", err)
+		}
+		if rSet == nil {
+			return nil
 		}
 		// ---------------------------
-		// Kick off index dispatcher
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		// ---------------------------
-		pointsQ := utils.ProduceWithContextMapKeys(ctx, deleteSet)
-		indexQ, indexQErrC := utils.TransformWithContext(ctx, pointsQ, func(pointId uuid.UUID) (ipc index.IndexPointChange, skip bool, err error) {
-			sp, err := pointstore.GetPointByUUID(bPoints, pointId)
-			if err == pointstore.ErrPointDoesNotExist {
-				// Deleting a non-existing point is a no-op
-				skip = true
-				return
+		/* The matching node ids are resolved up front, as deleting points
+		 * changes the indices the query runs on. */
+		deleteSet := make(map[uuid.UUID]struct{}, min(rSet.GetCardinality(), DELETECHUNKSIZE))
+		deleteChunk := func() error {
+			deletedIds, err := s.deletePoints(bm, cacheTx, deleteSet)
+			if err != nil {
+				return err
 			}
+			deletedCount += len(deletedIds)
+			clear(deleteSet)
+			return nil
+		}
+		it := rSet.Iterator()
+		for it.HasNext() {
+			nodeId := it.Next()
+			sp, err := pointstore.GetPointByNodeId(bPoints, nodeId, false)
 			if err != nil {
-				err = fmt.Errorf("could not get point for deletion: %w", err)
-				return
+				return fmt.Errorf("could not get point by node id %d: %w", nodeId, err)
 			}
-			deletedIds = append(deletedIds, pointId)
-			nodeCounter.FreeId(sp.NodeId)
-			// ---------------------------
-			if err = pointstore.DeletePoint(bPoints, pointId, sp.NodeId); err != nil {
-				err = fmt.Errorf("could not delete point %s: %w", pointId, err)
-				return
+			deleteSet[sp.Point.Id] = struct{}{}
+			if len(deleteSet) >= DELETECHUNKSIZE {
+				if err := deleteChunk(); err != nil {
+					return err
+				}
 			}
-			// ---------------------------
-			ipc.NodeId = sp.NodeId
-			ipc.PreviousData = sp.Data
-			return
-		})
-		im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
-		dispatchErrC := im.Dispatch(ctx, indexQ)
-		// ---------------------------
-		mergedErrC := utils.MergeErrorsWithContext(ctx, indexQErrC, dispatchErrC)
-		// At this point concurrent stuff is over, we can check for errors
-		if err := <-mergedErrC; err != nil {
-			return fmt.Errorf("could not complete insert: %w", err)
 		}
-		// ---------------------------
-		// Update point count accordingly
-		if err := changePointCount(bInternal, -len(deletedIds)); err != nil {
-			return fmt.Errorf("could not change point count for deletion: %w", err)
-		}
-		// ---------------------------
-		if err := nodeCounter.Flush(); err != nil {
-			return fmt.Errorf("could not flush id counter: %w", err)
+		if len(deleteSet) > 0 {
+			return deleteChunk()
 		}
-		// ---------------------------
 		return nil
 	})
 	if err != nil {
 		cacheTx.Commit(true)
-		return nil, fmt.Errorf("could not delete points: %w", err)
+		return 0, fmt.Errorf("could not delete points by query: %w", err)
 	}
 	cacheTx.Commit(false)
+	return deletedCount, nil
+}
+
+// deletePoints deletes the points from the points bucket and the indices
+// within a write transaction, returning the ids of the points that existed.
+func (s *Shard) deletePoints(bm diskstore.BucketManager, cacheTx *cache.Transaction, deleteSet map[uuid.UUID]struct{}) ([]uuid.UUID, error) {
+	// ---------------------------
+	deletedIds := make([]uuid.UUID, 0, len(deleteSet))
+	// ---------------------------
+	bPoints, err := bm.Get(pointstore.POINTSBUCKETNAME)
+	if err != nil {
+		return nil, fmt.Errorf("could not get write points bucket: %w", err)
+	}
+	bInternal, err := bm.Get(INTERNALBUCKETNAME)
+	if err != nil {
+		return nil, fmt.Errorf("could not get write internal bucket: %w", err)
+	}
+	// ---------------------------
+	nodeCounter, err := NewIdCounter(bInternal, FREENODEIDSKEY, NEXTFREENODEIDKEY)
+	if err != nil {
+		return nil, fmt.Errorf("could not create id counter: %w", err)
+	}
+	// ---------------------------
+	// Kick off index dispatcher
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// ---------------------------
+	pointsQ := utils.ProduceWithContextMapKeys(ctx, deleteSet)
+	indexQ, indexQErrC := utils.TransformWithContext(ctx, pointsQ, func(pointId uuid.UUID) (ipc index.IndexPointChange, skip bool, err error) {
+		sp, err := pointstore.GetPointByUUID(bPoints, pointId)
+		if err == pointstore.ErrPointDoesNotExist {
+			// Deleting a non-existing point is a no-op
+			skip = true
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("could not get point for deletion: %w", err)
+			return
+		}
+		deletedIds = append(deletedIds, pointId)
+		nodeCounter.FreeId(sp.NodeId)
+		// ---------------------------
+		if err = pointstore.DeletePoint(bPoints, pointId, sp.NodeId); err != nil {
+			err = fmt.Errorf("could not delete point %s: %w", pointId, err)
+			return
+		}
+		// ---------------------------
+		ipc.NodeId = sp.NodeId
+		ipc.PreviousData = sp.Data
+		return
+	})
+	im := index.NewIndexManager(bm, cacheTx, s.dbFile, s.collection.IndexSchema)
+	dispatchErrC := im.Dispatch(ctx, indexQ)
+	// ---------------------------
+	mergedErrC := utils.MergeErrorsWithContext(ctx, indexQErrC, dispatchErrC)
+	// At this point concurrent stuff is over, we can check for errors
+	if err := <-mergedErrC; err != nil {
+		return nil, fmt.Errorf("could not complete insert: %w", err)
+	}
+	// ---------------------------
+	// Update point count accordingly
+	if err := changePointCount(bInternal, -len(deletedIds)); err != nil {
+		return nil, fmt.Errorf("could not change point count for deletion: %w", err)
+	}
+	// ---------------------------
+	if err := nodeCounter.Flush(); err != nil {
+		return nil, fmt.Errorf("could not flush id counter: %w", err)
+	}
+	// ---------------------------
 	return deletedIds, nil
 }
 
@@ -1,6 +1,7 @@
 package shard
 
 import (
+	"context"
 	"testing"
 
 	"github.com/semafind/semadb/models"
@@ -36,7 +37,7 @@ func Test_UpdateMerge(t *testing.T) {
 		},
 		Select: []string{"size", "price"},
 	}
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 1)
 	require.Equal(t, int64(100), res[0].DecodedData["size"])
@@ -1,9 +1,11 @@
 package shard
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/semafind/semadb/models"
 	"github.com/stretchr/testify/require"
 )
@@ -41,7 +43,7 @@ func TestSearch_SelectNone(t *testing.T) {
 			},
 		},
 	}
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 6)
 	for i := 0; i < len(res); i++ {
@@ -70,7 +72,7 @@ func TestSearch_SelectStar(t *testing.T) {
 		},
 		Select: []string{"*"},
 	}
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 6)
 	for i := 0; i < len(res); i++ {
@@ -98,7 +100,7 @@ func TestSearch_Select(t *testing.T) {
 		},
 		Select: []string{"size", "category", "nonExistent"},
 	}
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 11)
 	for i := 0; i < 11; i++ {
@@ -131,7 +133,7 @@ func TestSearch_SelectNestedField(t *testing.T) {
 		Select: []string{"nested.vector", "nested.size", "nested", "nested.size"},
 	}
 	s.InsertPoints(points)
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 5)
 	require.Equal(t, points[3].Id, res[0].Point.Id)
@@ -166,7 +168,7 @@ func TestSearch_SelectStarNestedFieldSort(t *testing.T) {
 		},
 	}
 	s.InsertPoints(points)
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 5)
 	require.EqualValues(t, 0, *res[0].Distance)
@@ -204,7 +206,7 @@ func TestSearch_SelectNestedFieldSort(t *testing.T) {
 		},
 	}
 	s.InsertPoints(points)
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 5)
 	require.EqualValues(t, 0, *res[0].Distance)
@@ -239,7 +241,7 @@ func TestSearch_Sort(t *testing.T) {
 			{Property: "size", Descending: true},
 		},
 	}
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 11)
 	for i := 0; i < 11; i++ {
@@ -269,7 +271,7 @@ func TestSearch_SortPartial(t *testing.T) {
 			{Property: "size", Descending: true},
 		},
 	}
-	res, err := s.SearchPoints(sr)
+	res, err := s.SearchPoints(context.Background(), sr)
 	require.NoError(t, err)
 	require.Len(t, res, 11)
 	/* We expect points "extra" property to come first and sorted in descending
@@ -308,3 +310,676 @@ func TestSearch_SortPartial(t *testing.T) {
 		}
 	}
 }
+
+func TestSearch_SortDistance(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	err := s.InsertPoints(points)
+	require.NoError(t, err)
+	// ---------------------------
+	// The flat vectors are [size, size+1]
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "size",
+			Integer: &models.SearchIntegerOptions{
+				Value:    10,
+				Operator: models.OperatorLessOrEq,
+			},
+		},
+		Select: []string{"size"},
+		Sort: []models.SortOption{
+			{Property: "flat", DistanceTo: []float32{3.2, 4.2}},
+		},
+	}
+	res, err := s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 11)
+	sizes := make([]int64, len(res))
+	for i, r := range res {
+		sizes[i] = r.DecodedData["size"].(int64)
+		require.Len(t, r.SortDistances, 1)
+	}
+	require.Equal(t, []int64{3, 4, 2, 5, 1, 6, 0, 7, 8, 9, 10}, sizes)
+	require.InDelta(t, 0.08, *res[0].SortDistances[0], 1e-5)
+	// ---------------------------
+	// Sorting by distance does not need the property selected
+	sr.Select = nil
+	sr.Sort[0].Descending = true
+	sr.Limit = 2
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.InDelta(t, 2*6.8*6.8, *res[0].SortDistances[0], 1e-3)
+	require.Nil(t, res[0].DecodedData["flat"])
+	// ---------------------------
+	sr.Sort[0].Property = "size"
+	_, err = s.SearchPoints(context.Background(), sr)
+	require.Error(t, err)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_ScoreFunctions(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	err := s.InsertPoints(points)
+	require.NoError(t, err)
+	// ---------------------------
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "size",
+			Integer: &models.SearchIntegerOptions{
+				Value:    10,
+				Operator: models.OperatorLessOrEq,
+			},
+		},
+		Select: []string{"size"},
+		ScoreFunctions: &models.ScoreFunctions{
+			Functions: []models.ScoreFunction{
+				{
+					Property: "size",
+					Decay: &models.ScoreDecay{
+						Function: models.ScoreDecayGauss,
+						Origin:   7,
+						Scale:    2,
+					},
+				},
+			},
+		},
+	}
+	res, err := s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 11)
+	require.Equal(t, int64(7), res[0].DecodedData["size"])
+	require.Equal(t, float32(1), res[0].HybridScore)
+	require.Contains(t, []int64{6, 8}, res[1].DecodedData["size"])
+	require.InDelta(t, 0.840896, res[1].HybridScore, 1e-5)
+	for i := 0; i < len(res)-1; i++ {
+		require.GreaterOrEqual(t, res[i].HybridScore, res[i+1].HybridScore)
+	}
+	// ---------------------------
+	// Boosting by a field does not need it selected
+	weight := float32(2)
+	sr.Select = nil
+	sr.ScoreFunctions.Functions = []models.ScoreFunction{
+		{
+			Property:         "price",
+			FieldValueFactor: &models.ScoreFieldValueFactor{},
+			Weight:           &weight,
+		},
+		// Points without the property are left as is
+		{
+			Property:         "nonExistent",
+			FieldValueFactor: &models.ScoreFieldValueFactor{Factor: 100},
+		},
+	}
+	sr.Limit = 3
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	require.Equal(t, float32(21), res[0].HybridScore)
+	require.Equal(t, float32(19), res[1].HybridScore)
+	require.Empty(t, res[0].Data)
+	// ---------------------------
+	// The descriptions match equally, so multiplying ranks by price
+	missing := 5.0
+	sr.Query = models.Query{
+		Property: "description",
+		Text: &models.SearchTextOptions{
+			Value:    "1 2 3",
+			Operator: models.OperatorContainsAny,
+			Limit:    10,
+		},
+	}
+	sr.ScoreFunctions.Functions[1].FieldValueFactor.Missing = &missing
+	sr.ScoreFunctions.Mode = models.ScoreModeMultiply
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	// Missing values are used when given, text score * 2 * 3.5 * 500
+	textScore := res[0].HybridScore / (2 * 3.5 * 500)
+	require.Greater(t, textScore, float32(0))
+	require.InDelta(t, textScore*2*2.5*500, res[1].HybridScore, 1e-3)
+	require.InDelta(t, textScore*2*1.5*500, res[2].HybridScore, 1e-3)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_ScoreExpression(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	err := s.InsertPoints(points)
+	require.NoError(t, err)
+	// ---------------------------
+	// The flat vectors are [size, size+1] and price is size+0.5
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "flat",
+			VectorFlat: &models.SearchVectorFlatOptions{
+				Vector:   []float32{5, 6},
+				Operator: models.OperatorNear,
+				Limit:    10,
+			},
+		},
+		ScoreExpression: "price - 0.1 * _distance",
+		Sort: []models.SortOption{
+			{Property: "_customScore", Descending: true},
+		},
+	}
+	res, err := s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+	for i := 0; i < len(res)-1; i++ {
+		require.GreaterOrEqual(t, *res[i].CustomScore, *res[i+1].CustomScore)
+	}
+	// The squared euclidean distance of size k is 2(k-5)^2, so sizes 7 and 8
+	// score 7.5 - 0.8 and 8.5 - 1.8 which beat 5.5 at distance 0
+	require.InDelta(t, 6.7, *res[0].CustomScore, 1e-5)
+	require.InDelta(t, 6.7, *res[1].CustomScore, 1e-5)
+	require.InDelta(t, 6.3, *res[2].CustomScore, 1e-5)
+	// ---------------------------
+	// Points without a value have no custom score and sort last
+	sr.ScoreExpression = "nonExistent + _hybridScore"
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+	require.Nil(t, res[0].CustomScore)
+	require.Empty(t, res[0].Data)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_MMRVectors(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	err := s.InsertPoints(points)
+	require.NoError(t, err)
+	// ---------------------------
+	// The flat vectors are [size, size+1]
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "flat",
+			VectorFlat: &models.SearchVectorFlatOptions{
+				Vector: []float32{0, 1},
+				Limit:  5,
+				MMR:    &models.SearchMMROptions{Lambda: 0.5},
+			},
+		},
+		Limit: 5,
+	}
+	res, err := s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	require.Equal(t, []float32{0, 1}, res[0].MMRVector)
+	for _, r := range res {
+		require.Len(t, r.MMRVector, 2)
+		require.Nil(t, r.Data)
+	}
+	// ---------------------------
+	// Sorted results are not diversified again when merging
+	sr.Sort = []models.SortOption{{Property: "size"}}
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	for _, r := range res {
+		require.Nil(t, r.MMRVector)
+	}
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_Explain(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	err := s.InsertPoints(points)
+	require.NoError(t, err)
+	// ---------------------------
+	// The flat vectors are [size, size+1] so point 3 is found by both queries
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "_or",
+			Or: []models.Query{
+				{
+					Property: "flat",
+					VectorFlat: &models.SearchVectorFlatOptions{
+						Vector: []float32{3, 4},
+						Limit:  5,
+					},
+				},
+				{
+					Property: "description",
+					Text: &models.SearchTextOptions{
+						Value:    "3",
+						Operator: models.OperatorContainsAny,
+						Limit:    10,
+					},
+				},
+			},
+		},
+		Select: []string{"size"},
+		Limit:  10,
+	}
+	res, err := s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	for _, r := range res {
+		require.Nil(t, r.Explanation)
+	}
+	// ---------------------------
+	sr.Explain = true
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 5)
+	for _, r := range res {
+		require.NotNil(t, r.Explanation)
+		var total float32
+		for _, q := range r.Explanation.Queries {
+			total += q.Contribution
+			require.Equal(t, float32(1), q.Weight)
+		}
+		require.InDelta(t, r.HybridScore, total, 1e-5)
+		if r.DecodedData["size"].(int64) == 3 {
+			require.Len(t, r.Explanation.Queries, 2)
+			require.Equal(t, "flat", r.Explanation.Queries[0].Property)
+			require.Equal(t, float32(0), *r.Explanation.Queries[0].Distance)
+			require.Equal(t, "description", r.Explanation.Queries[1].Property)
+			require.NotNil(t, r.Explanation.Queries[1].Score)
+		} else {
+			require.Len(t, r.Explanation.Queries, 1)
+		}
+	}
+	// ---------------------------
+	// Points found only by filters have an empty explanation
+	sr.Query = models.Query{
+		Property: "size",
+		Integer: &models.SearchIntegerOptions{
+			Value:    3,
+			Operator: models.OperatorEquals,
+		},
+	}
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.NotNil(t, res[0].Explanation)
+	require.Empty(t, res[0].Explanation.Queries)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_Profile(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	err := s.InsertPoints(points)
+	require.NoError(t, err)
+	// ---------------------------
+	sr := models.SearchRequest{
+		Query: models.Query{
+			Property: "_or",
+			Or: []models.Query{
+				{
+					Property: "vector",
+					VectorVamana: &models.SearchVectorVamanaOptions{
+						Vector:     []float32{3, 4},
+						SearchSize: 75,
+						Limit:      5,
+						Filter: &models.Query{
+							Property: "size",
+							Integer: &models.SearchIntegerOptions{
+								Value:    50,
+								Operator: models.OperatorLessThan,
+							},
+						},
+					},
+				},
+				{
+					Property: "description",
+					Text: &models.SearchTextOptions{
+						Value:    "3",
+						Operator: models.OperatorContainsAny,
+						Limit:    10,
+					},
+				},
+			},
+		},
+		Limit:   10,
+		Profile: true,
+	}
+	profile := &models.ShardProfile{}
+	ctx := WithProfile(context.Background(), profile)
+	res, err := s.SearchPoints(ctx, sr)
+	require.NoError(t, err)
+	require.NotEmpty(t, res)
+	require.Greater(t, profile.TotalMs, 0.0)
+	require.GreaterOrEqual(t, profile.TotalMs, profile.BackfillMs)
+	// Inserting the points loaded the vamana index into the cache
+	require.Equal(t, int64(1), profile.CacheHits)
+	require.Equal(t, int64(0), profile.CacheMisses)
+	// ---------------------------
+	// The query profile mirrors the query
+	require.NotNil(t, profile.Query)
+	require.Equal(t, "_or", profile.Query.Property)
+	require.Len(t, profile.Query.Queries, 2)
+	vectorProfile := profile.Query.Queries[0]
+	require.Equal(t, "vector", vectorProfile.Property)
+	require.Equal(t, 5, vectorProfile.Results)
+	require.Greater(t, vectorProfile.NodesVisited, 0)
+	require.NotNil(t, vectorProfile.Filter)
+	require.Equal(t, "size", vectorProfile.Filter.Property)
+	require.EqualValues(t, 50, vectorProfile.Filter.Cardinality)
+	textProfile := profile.Query.Queries[1]
+	require.Equal(t, "description", textProfile.Property)
+	require.Equal(t, 1, textProfile.Results)
+	require.Zero(t, textProfile.NodesVisited)
+	require.EqualValues(t, len(res), profile.Query.Cardinality)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_MoreLikeThis(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	descriptions := []string{
+		"gandalf the wizard rides to rivendell",
+		"gandalf the wizard",
+		"frodo rides to rivendell",
+		"samwise cooks potatoes",
+		"sauron watches",
+	}
+	pointsAsMap := make([]models.PointAsMap, len(descriptions))
+	for i, d := range descriptions {
+		fi := float32(i)
+		pointsAsMap[i] = models.PointAsMap{
+			"vector":      []float32{fi, fi + 1},
+			"description": d,
+		}
+	}
+	points := pointsAsMapToPoints(pointsAsMap)
+	require.NoError(t, s.InsertPoints(points))
+	// ---------------------------
+	terms, err := s.MoreLikeThisTerms("description", points[0].Id, 0)
+	require.NoError(t, err)
+	require.Len(t, terms, 4)
+	terms, err = s.MoreLikeThisTerms("description", uuid.New(), 0)
+	require.NoError(t, err)
+	require.Nil(t, terms)
+	// ---------------------------
+	// The terms are looked up in the shard if they are not given and the
+	// source point is not returned
+	mlt := models.Query{
+		Property: "description",
+		Text: &models.SearchTextOptions{
+			Value:    points[0].Id.String(),
+			Operator: models.OperatorMoreLikeThis,
+			Limit:    10,
+		},
+	}
+	sr := models.SearchRequest{
+		Query:  mlt,
+		Select: []string{"description"},
+		Limit:  10,
+	}
+	res, err := s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, points[1].Id, res[0].Point.Id)
+	require.Equal(t, points[2].Id, res[1].Point.Id)
+	// ---------------------------
+	// Combined with a vector search in a hybrid query
+	sr.Query = models.Query{
+		Property: "_or",
+		Or: []models.Query{
+			mlt,
+			{
+				Property: "vector",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Vector:     []float32{4, 5},
+					Operator:   models.OperatorNear,
+					SearchSize: 75,
+					Limit:      1,
+				},
+			},
+		},
+	}
+	res, err = s.SearchPoints(context.Background(), sr)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+	ids := []uuid.UUID{res[0].Point.Id, res[1].Point.Id, res[2].Point.Id}
+	require.ElementsMatch(t, []uuid.UUID{points[1].Id, points[2].Id, points[4].Id}, ids)
+}
+
+func TestSearch_Recommend(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	pointsAsMap := make([]models.PointAsMap, 10)
+	for i := range pointsAsMap {
+		fi := float32(i)
+		pointsAsMap[i] = models.PointAsMap{
+			"vector": []float32{fi, 0},
+			"flat":   []float32{fi, 0},
+		}
+	}
+	points := pointsAsMapToPoints(pointsAsMap)
+	require.NoError(t, s.InsertPoints(points))
+	// ---------------------------
+	vectors, err := s.RecommendVectors("flat", []uuid.UUID{points[3].Id, uuid.New()})
+	require.NoError(t, err)
+	require.Len(t, vectors, 1)
+	require.Equal(t, []float32{3, 0}, vectors[points[3].Id])
+	// ---------------------------
+	search := func(q models.Query, limit int) []uuid.UUID {
+		res, err := s.SearchPoints(context.Background(), models.SearchRequest{Query: q, Limit: limit})
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, len(res))
+		for i, r := range res {
+			ids[i] = r.Point.Id
+		}
+		return ids
+	}
+	// The examples are looked up in the shard and left out of the results
+	ids := search(models.Query{
+		Property: "vector",
+		VectorVamana: &models.SearchVectorVamanaOptions{
+			Operator:   models.OperatorRecommend,
+			SearchSize: 75,
+			Limit:      2,
+			Recommend:  &models.SearchRecommendOptions{Positive: []string{points[3].Id.String()}},
+		},
+	}, 2)
+	require.ElementsMatch(t, []uuid.UUID{points[2].Id, points[4].Id}, ids)
+	// Negative examples push the average away
+	ids = search(models.Query{
+		Property: "flat",
+		VectorFlat: &models.SearchVectorFlatOptions{
+			Operator: models.OperatorRecommend,
+			Limit:    1,
+			Recommend: &models.SearchRecommendOptions{
+				Positive: []string{points[3].Id.String()},
+				Negative: []string{points[0].Id.String()},
+			},
+		},
+	}, 1)
+	require.Equal(t, []uuid.UUID{points[6].Id}, ids)
+	// Best score penalises points closer to a negative example
+	ids = search(models.Query{
+		Property: "vector",
+		VectorVamana: &models.SearchVectorVamanaOptions{
+			Operator:   models.OperatorRecommend,
+			SearchSize: 75,
+			Limit:      2,
+			Recommend: &models.SearchRecommendOptions{
+				Positive: []string{points[3].Id.String()},
+				Negative: []string{points[4].Id.String()},
+				Strategy: models.RecommendStrategyBestScore,
+			},
+		},
+	}, 2)
+	require.Equal(t, []uuid.UUID{points[2].Id, points[1].Id}, ids)
+	// Without any positive example nothing matches
+	ids = search(models.Query{
+		Property: "flat",
+		VectorFlat: &models.SearchVectorFlatOptions{
+			Operator:  models.OperatorRecommend,
+			Limit:     5,
+			Recommend: &models.SearchRecommendOptions{Positive: []string{uuid.New().String()}},
+		},
+	}, 5)
+	require.Empty(t, ids)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_Batch(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	require.NoError(t, s.InsertPoints(points))
+	// ---------------------------
+	rangeSearch := models.SearchRequest{
+		Query: models.Query{
+			Property: "size",
+			Integer: &models.SearchIntegerOptions{
+				Value:    10,
+				EndValue: 15,
+				Operator: models.OperatorInRange,
+			},
+		},
+		Select: []string{"size"},
+		Sort:   []models.SortOption{{Property: "size", Descending: true}},
+		Limit:  3,
+	}
+	textSearch := models.SearchRequest{
+		Query: models.Query{
+			Property: "description",
+			Text: &models.SearchTextOptions{
+				Value:    "description 42",
+				Operator: models.OperatorContainsAll,
+				Limit:    10,
+			},
+		},
+		Limit: 10,
+	}
+	missingSearch := models.SearchRequest{
+		Query: models.Query{
+			Property: "gandalf",
+			Integer: &models.SearchIntegerOptions{
+				Value:    1,
+				Operator: models.OperatorEquals,
+			},
+		},
+	}
+	results, errs := s.SearchPointsBatch(context.Background(), []models.SearchRequest{rangeSearch, missingSearch, textSearch})
+	require.Len(t, results, 3)
+	require.Len(t, errs, 3)
+	// Each search gets the same results as on its own
+	require.NoError(t, errs[0])
+	res, err := s.SearchPoints(context.Background(), rangeSearch)
+	require.NoError(t, err)
+	require.Equal(t, res, results[0])
+	require.Len(t, results[0], 3)
+	require.EqualValues(t, 15, results[0][0].DecodedData["size"])
+	// A failing search does not affect the others
+	require.Error(t, errs[1])
+	require.Nil(t, results[1])
+	require.NoError(t, errs[2])
+	require.Len(t, results[2], 1)
+	require.Equal(t, points[42].Id, results[2][0].Point.Id)
+	require.NoError(t, s.Close())
+}
+
+func TestSearch_BatchIndexFailure(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	require.NoError(t, s.InsertPoints(points))
+	// ---------------------------
+	vectorSearch := func(searchSize, limit int) models.SearchRequest {
+		return models.SearchRequest{
+			Query: models.Query{
+				Property: "nested.vector",
+				VectorVamana: &models.SearchVectorVamanaOptions{
+					Vector:     getVector(points[3]),
+					SearchSize: searchSize,
+					Limit:      limit,
+					Operator:   "near",
+				},
+			},
+			Limit: limit,
+		}
+	}
+	textSearch := models.SearchRequest{
+		Query: models.Query{
+			Property: "description",
+			Text: &models.SearchTextOptions{
+				Value:    "description 42",
+				Operator: models.OperatorContainsAll,
+				Limit:    10,
+			},
+		},
+		Limit: 10,
+	}
+	// The first search fails inside the vector index after using its cache
+	failingSearch := vectorSearch(5, 10)
+	results, errs := s.SearchPointsBatch(context.Background(), []models.SearchRequest{failingSearch, vectorSearch(75, 5), textSearch})
+	require.Len(t, results, 3)
+	require.Error(t, errs[0])
+	require.Nil(t, results[0])
+	require.NoError(t, errs[1])
+	require.Len(t, results[1], 5)
+	require.Equal(t, points[3].Id, results[1][0].Point.Id)
+	require.NoError(t, errs[2])
+	require.Len(t, results[2], 1)
+	require.Equal(t, points[42].Id, results[2][0].Point.Id)
+	require.NoError(t, s.Close())
+}
+
+func TestCountPoints(t *testing.T) {
+	// ---------------------------
+	s := tempShard(t)
+	points := randPoints(100)
+	require.NoError(t, s.InsertPoints(points))
+	// ---------------------------
+	rangeQuery := models.Query{
+		Property: "size",
+		Integer: &models.SearchIntegerOptions{
+			Value:    10,
+			EndValue: 15,
+			Operator: models.OperatorInRange,
+		},
+	}
+	count, err := s.CountPoints(context.Background(), rangeQuery)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, count)
+	// Text queries count all matches beyond their limit
+	textQuery := models.Query{
+		Property: "description",
+		Text: &models.SearchTextOptions{
+			Value:     "description",
+			Operator:  models.OperatorContainsAll,
+			Limit:     10,
+			Highlight: &models.SearchTextHighlight{},
+		},
+	}
+	count, err = s.CountPoints(context.Background(), textQuery)
+	require.NoError(t, err)
+	require.EqualValues(t, 100, count)
+	res, err := s.SearchPoints(context.Background(), models.SearchRequest{Query: textQuery, Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+	// Vector queries only count their nearest points
+	count, err = s.CountPoints(context.Background(), models.Query{
+		Property: "_or",
+		Or: []models.Query{
+			rangeQuery,
+			{
+				Property: "flat",
+				VectorFlat: &models.SearchVectorFlatOptions{
+					Vector:   []float32{50, 51},
+					Operator: models.OperatorNear,
+					Limit:    5,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 11, count)
+	require.NoError(t, s.Close())
+}
@@ -1,6 +1,7 @@
 package shard
 
 import (
+	"context"
 	"fmt"
 	"math/rand/v2"
 	"path/filepath"
@@ -380,6 +381,36 @@ func TestShard_CreateMorePoints(t *testing.T) {
 	require.NoError(t, shard.Close())
 }
 
+func TestShard_BulkInsertPoints(t *testing.T) {
+	shard := tempShard(t)
+	points := randPoints(2000)
+	require.NoError(t, shard.InsertPoints(points[:1000]))
+	// The batch is not larger than the shard so only the flag triggers bulk
+	require.NoError(t, shard.BulkInsertPoints(points[1000:]))
+	checkPointCount(t, shard, 2000)
+	for _, p := range points[:10] {
+		res, err := shard.SearchPoints(context.Background(), searchRequest(p, 1))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, p.Id, res[0].Point.Id)
+	}
+	require.NoError(t, shard.Close())
+}
+
+func TestShard_InfoCalibration(t *testing.T) {
+	shard := tempShard(t)
+	si, err := shard.Info()
+	require.NoError(t, err)
+	require.Empty(t, si.VamanaCalibrations)
+	require.NoError(t, shard.InsertPoints(randPoints(1200)))
+	si, err = shard.Info()
+	require.NoError(t, err)
+	require.Len(t, si.VamanaCalibrations, 2)
+	require.Equal(t, 1200, si.VamanaCalibrations["vector"].PointCount)
+	require.NotEmpty(t, si.VamanaCalibrations["nested.vector"].Curve)
+	require.NoError(t, shard.Close())
+}
+
 func TestShard_Persistence(t *testing.T) {
 	shardDir := t.TempDir()
 	dbfile := filepath.Join(shardDir, "sharddb.bbolt")
@@ -409,7 +440,7 @@ func TestShard_BasicSearch(t *testing.T) {
 	shard := tempShard(t)
 	points := randPoints(2)
 	shard.InsertPoints(points)
-	res, err := shard.SearchPoints(searchRequest(points[0], 1))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(points[0], 1))
 	require.NoError(t, err)
 	require.Equal(t, 1, len(res))
 	require.Equal(t, points[0].Id, res[0].Point.Id)
@@ -431,7 +462,7 @@ func TestShard_CacheReuse(t *testing.T) {
 	})
 	require.NoError(t, err)
 	// The shared cache should allow us to search
-	res, err := shard.SearchPoints(searchRequest(points[0], 1))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(points[0], 1))
 	require.NoError(t, err)
 	require.Equal(t, 1, len(res))
 	require.Equal(t, points[0].Id, res[0].Point.Id)
@@ -448,7 +479,7 @@ func TestShard_BucketSearch(t *testing.T) {
 	// Clear the cache
 	shard.cacheManager.Release(shard.dbFile + "/index/vectorVamana/vector")
 	// Search from the bucket directly
-	res, err := shard.SearchPoints(searchRequest(points[0], 1))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(points[0], 1))
 	require.NoError(t, err)
 	require.Equal(t, 1, len(res))
 	require.Equal(t, points[0].Id, res[0].Point.Id)
@@ -462,7 +493,7 @@ func TestShard_SearchMaxLimit(t *testing.T) {
 	shard := tempShard(t)
 	points := randPoints(2)
 	shard.InsertPoints(points)
-	res, err := shard.SearchPoints(searchRequest(points[0], 7))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(points[0], 7))
 	require.NoError(t, err)
 	require.Equal(t, 2, len(res))
 	require.NoError(t, shard.Close())
@@ -512,6 +543,73 @@ func TestShard_DeletePoint(t *testing.T) {
 	require.NoError(t, shard.Close())
 }
 
+func TestShard_DeletePointsByQuery(t *testing.T) {
+	shard := tempShard(t)
+	points := randPoints(100)
+	require.NoError(t, shard.InsertPoints(points))
+	rangeQuery := models.Query{
+		Property: "size",
+		Integer: &models.SearchIntegerOptions{
+			Value:    10,
+			EndValue: 15,
+			Operator: models.OperatorInRange,
+		},
+	}
+	count, err := shard.DeletePointsByQuery(context.Background(), rangeQuery)
+	require.NoError(t, err)
+	require.Equal(t, 6, count)
+	checkPointCount(t, shard, 94)
+	for _, p := range points[10:16] {
+		checkNoReferences(t, shard, p.Id)
+	}
+	res, err := shard.SearchPoints(context.Background(), models.SearchRequest{Query: rangeQuery})
+	require.NoError(t, err)
+	require.Len(t, res, 0)
+	// Deleting again finds nothing to delete
+	count, err = shard.DeletePointsByQuery(context.Background(), rangeQuery)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+	// Text queries delete all their matches beyond the limit
+	count, err = shard.DeletePointsByQuery(context.Background(), models.Query{
+		Property: "description",
+		Text: &models.SearchTextOptions{
+			Value:    "description",
+			Operator: models.OperatorContainsAll,
+			Limit:    10,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 94, count)
+	checkPointCount(t, shard, 0)
+	require.NoError(t, shard.Close())
+}
+
+func TestShard_DeletePointsByQueryChunks(t *testing.T) {
+	shard := tempShard(t)
+	points := randPoints(2500)
+	require.NoError(t, shard.InsertPoints(points))
+	// Two full chunks and a partial one
+	rangeQuery := models.Query{
+		Property: "size",
+		Integer: &models.SearchIntegerOptions{
+			Value:    0,
+			EndValue: 2199,
+			Operator: models.OperatorInRange,
+		},
+	}
+	count, err := shard.DeletePointsByQuery(context.Background(), rangeQuery)
+	require.NoError(t, err)
+	require.Equal(t, 2200, count)
+	checkPointCount(t, shard, 300)
+	checkNoReferences(t, shard, points[0].Id, points[999].Id, points[1000].Id, points[2199].Id)
+	// The remaining points are still searchable
+	res, err := shard.SearchPoints(context.Background(), searchRequest(points[2300], 1))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, points[2300].Id, res[0].Point.Id)
+	require.NoError(t, shard.Close())
+}
+
 func TestShard_InsertDeleteSearchInsertPoint(t *testing.T) {
 	shard := tempShard(t)
 	points := randPoints(2)
@@ -528,7 +626,7 @@ func TestShard_InsertDeleteSearchInsertPoint(t *testing.T) {
 	checkNoReferences(t, shard, delIds...)
 	checkMaxNodeId(t, shard, 0)
 	// Try searching for the deleted point
-	res, err := shard.SearchPoints(searchRequest(points[0], 1))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(points[0], 1))
 	require.NoError(t, err)
 	require.Len(t, res, 0)
 	// Try inserting the deleted points
@@ -556,7 +654,7 @@ func TestShard_SearchWhileInsert(t *testing.T) {
 	// Search points
 	go func() {
 		for _, point := range points {
-			res, err := shard.SearchPoints(searchRequest(point, 1))
+			res, err := shard.SearchPoints(context.Background(), searchRequest(point, 1))
 			assert.NoError(t, err)
 			assert.Len(t, res, 1)
 			assert.Equal(t, point.Id, res[0].Point.Id)
@@ -624,7 +722,7 @@ func TestShard_ConcurrentCRUD(t *testing.T) {
 	// Search points
 	go func() {
 		for i := 0; i < 50; i++ {
-			res, err := shard.SearchPoints(searchRequest(points[i], 1))
+			res, err := shard.SearchPoints(context.Background(), searchRequest(points[i], 1))
 			assert.NoError(t, err)
 			assert.Len(t, res, 1)
 			assert.Equal(t, points[i].Id, res[0].Point.Id)
@@ -692,7 +790,7 @@ func TestShard_LargeInsertDeleteInsertSearch(t *testing.T) {
 	checkMaxNodeId(t, shard, initSize)
 	// Try searching for the deleted point
 	sp := points[0]
-	res, err := shard.SearchPoints(searchRequest(sp, 1))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(sp, 1))
 	require.NoError(t, err)
 	require.Len(t, res, 1)
 	require.Equal(t, sp.Id, res[0].Point.Id)
@@ -716,7 +814,7 @@ func TestShard_LargeInsertUpdateSearch(t *testing.T) {
 	checkPointCount(t, shard, initSize)
 	checkMaxNodeId(t, shard, initSize)
 	// Try searching for the updated point
-	res, err := shard.SearchPoints(searchRequest(updatePoints[0], 1))
+	res, err := shard.SearchPoints(context.Background(), searchRequest(updatePoints[0], 1))
 	require.NoError(t, err)
 	require.Len(t, res, 1)
 	require.Equal(t, points[0].Id, res[0].Point.Id)
@@ -3,6 +3,7 @@ package vectorstore
 import (
 	"fmt"
 	"math"
+	"slices"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -72,6 +73,29 @@ func (bq *binaryQuantizer) Get(id uint64) (VectorStorePoint, error) {
 	return bq.items.Get(id)
 }
 
+func (bq *binaryQuantizer) GetVector(id uint64) ([]float32, error) {
+	point, err := bq.items.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(point.Vector) != 0 || bq.threshold == nil {
+		return slices.Clone(point.Vector), nil
+	}
+	/* Only the binary vector is stored once the quantizer is fitted, so we
+	 * decode it around the threshold such that it encodes back to the same
+	 * bits. For hamming and jaccard distances the threshold is 0.5 which gives
+	 * back the original 0s and 1s. */
+	vector := make([]float32, len(bq.threshold))
+	for i, t := range bq.threshold {
+		if point.BinaryVector[i/64]&(1<<(i%64)) != 0 {
+			vector[i] = t + 0.5
+		} else {
+			vector[i] = t - 0.5
+		}
+	}
+	return vector, nil
+}
+
 func (bq *binaryQuantizer) GetMany(ids ...uint64) ([]VectorStorePoint, error) {
 	points, err := bq.items.GetMany(ids...)
 	if err != nil {
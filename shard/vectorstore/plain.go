@@ -3,6 +3,7 @@ package vectorstore
 import (
 	"fmt"
 	"math"
+	"slices"
 
 	"github.com/rs/zerolog/log"
 	"github.com/semafind/semadb/conversion"
@@ -27,6 +28,14 @@ func (ps plainStore) Get(id uint64) (VectorStorePoint, error) {
 	return ps.items.Get(id)
 }
 
+func (ps plainStore) GetVector(id uint64) ([]float32, error) {
+	point, err := ps.items.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(point.Vector), nil
+}
+
 func (ps plainStore) GetMany(ids ...uint64) ([]VectorStorePoint, error) {
 	points, err := ps.items.GetMany(ids...)
 	if err != nil {
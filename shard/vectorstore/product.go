@@ -3,6 +3,7 @@ package vectorstore
 import (
 	"fmt"
 	"math"
+	"slices"
 	"sync"
 
 	"github.com/rs/zerolog/log"
@@ -106,6 +107,22 @@ func (pq *productQuantizer) Get(id uint64) (VectorStorePoint, error) {
 	return pq.items.Get(id)
 }
 
+func (pq *productQuantizer) GetVector(id uint64) ([]float32, error) {
+	point, err := pq.items.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(point.Vector) != 0 {
+		return slices.Clone(point.Vector), nil
+	}
+	// Points read back from disk only load the quantized vector
+	vectorBytes := pq.bucket.Get(conversion.NodeKey(id, 'v'))
+	if vectorBytes == nil {
+		return nil, cache.ErrNotFound
+	}
+	return slices.Clone(conversion.BytesToFloat32(vectorBytes)), nil
+}
+
 func (pq *productQuantizer) GetMany(ids ...uint64) ([]VectorStorePoint, error) {
 	points, err := pq.items.GetMany(ids...)
 	if err != nil {
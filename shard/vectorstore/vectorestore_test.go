@@ -103,6 +103,36 @@ func Test_Persistance(t *testing.T) {
 	}
 }
 
+func Test_GetVector(t *testing.T) {
+	for _, storeType := range storeTypes {
+		for _, trigger := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/fit=%v", storeType.Type, trigger), func(t *testing.T) {
+				bucket := diskstore.NewMemBucket(false)
+				s := setupVectorStore(t, storeType, bucket)
+				if trigger {
+					triggerFit(t, s)
+				}
+				_, err := s.Set(7, []float32{1, 2, 3, 4})
+				require.NoError(t, err)
+				vector, err := s.GetVector(7)
+				require.NoError(t, err)
+				require.Equal(t, []float32{1, 2, 3, 4}, vector)
+				require.NoError(t, s.Flush())
+				// Fitted binary quantizers only keep the bits, so we check the
+				// vector read back is the same point to the store
+				s2 := setupVectorStore(t, storeType, bucket)
+				vector, err = s2.GetVector(7)
+				require.NoError(t, err)
+				point, err := s2.Get(7)
+				require.NoError(t, err)
+				require.Equal(t, float32(0), s2.DistanceFromFloat(vector)(point))
+				_, err = s2.GetVector(8)
+				require.Error(t, err)
+			})
+		}
+	}
+}
+
 type dummyVectorStorePoint struct{}
 
 func (d dummyVectorStorePoint) Id() uint64 {
@@ -152,3 +182,24 @@ func Test_DistanceFromPoint(t *testing.T) {
 		}
 	}
 }
+
+func Test_RecommendDistance(t *testing.T) {
+	bucket := diskstore.NewMemBucket(false)
+	s := setupVectorStore(t, storeTypes[0], bucket)
+	near, err := s.Set(7, []float32{2, 0, 0, 0})
+	require.NoError(t, err)
+	far, err := s.Set(8, []float32{-2, 0, 0, 0})
+	require.NoError(t, err)
+	positives := [][]float32{{1, 0, 0, 0}}
+	negatives := [][]float32{{-1, 0, 0, 0}}
+	// Average moves the query to 2*1 - (-1) = 3
+	dist := vectorstore.RecommendDistance(s, models.RecommendStrategyAverage, positives, negatives)
+	require.Equal(t, s.DistanceFromFloat([]float32{3, 0, 0, 0})(near), dist(near))
+	// Best score penalises points closer to the negative example
+	dist = vectorstore.RecommendDistance(s, models.RecommendStrategyBestScore, positives, negatives)
+	posDist := s.DistanceFromFloat(positives[0])
+	negDist := s.DistanceFromFloat(negatives[0])
+	require.Equal(t, posDist(near), dist(near))
+	require.Equal(t, 2*posDist(far)-negDist(far), dist(far))
+	require.Less(t, dist(near), dist(far))
+}
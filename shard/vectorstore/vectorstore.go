@@ -7,6 +7,7 @@ import (
 	"github.com/semafind/semadb/distance"
 	"github.com/semafind/semadb/models"
 	"github.com/semafind/semadb/shard/cache"
+	"github.com/semafind/semadb/utils"
 )
 
 // ---------------------------
@@ -23,6 +24,9 @@ type PointIdDistFn func(y VectorStorePoint) float32
 type VectorStore interface {
 	Exists(id uint64) bool
 	Get(id uint64) (VectorStorePoint, error)
+	// Returns a copy of the original vector of the point, quantized stores keep
+	// it on disk alongside the quantized version.
+	GetVector(id uint64) ([]float32, error)
 	GetMany(ids ...uint64) ([]VectorStorePoint, error)
 	Set(id uint64, vector []float32) (VectorStorePoint, error)
 	Delete(ids ...uint64) error
@@ -94,3 +98,79 @@ func New(params *models.Quantizer, bucket diskstore.Bucket, distFnName string, v
 	}
 	return nil, fmt.Errorf("unknown vector store type %T", params.Type)
 }
+
+// ---------------------------
+
+// Reranks points ordered by distance to a query with maximal marginal
+// relevance, using the distances between the points in the store. It returns
+// the indices of the picked points in order along with their scores.
+func MaximalMarginalRelevance(vs VectorStore, points []VectorStorePoint, queryDists []float32, lambda float32, limit int) ([]int, []float32) {
+	distFrom := func(i int) func(j int) float32 {
+		distFn := vs.DistanceFromPoint(points[i])
+		return func(j int) float32 {
+			return distFn(points[j])
+		}
+	}
+	return utils.MaximalMarginalRelevance(queryDists, distFrom, lambda, limit)
+}
+
+// ---------------------------
+
+// Creates a distance function for recommendations from positive and negative
+// example vectors. The average strategy searches near the average positive
+// vector pushed away from the average negative one. The best score strategy
+// takes the closest positive example and penalises points that are closer to
+// a negative example instead.
+func RecommendDistance(vs VectorStore, strategy string, positives, negatives [][]float32) PointIdDistFn {
+	if strategy == models.RecommendStrategyBestScore {
+		posFns := make([]PointIdDistFn, len(positives))
+		for i, v := range positives {
+			posFns[i] = vs.DistanceFromFloat(v)
+		}
+		negFns := make([]PointIdDistFn, len(negatives))
+		for i, v := range negatives {
+			negFns[i] = vs.DistanceFromFloat(v)
+		}
+		return func(y VectorStorePoint) float32 {
+			pos := minDistance(posFns, y)
+			if len(negFns) == 0 {
+				return pos
+			}
+			neg := minDistance(negFns, y)
+			if pos <= neg {
+				return pos
+			}
+			return 2*pos - neg
+		}
+	}
+	// ---------------------------
+	query := averageVector(positives)
+	if len(negatives) > 0 {
+		neg := averageVector(negatives)
+		for i := range query {
+			query[i] = 2*query[i] - neg[i]
+		}
+	}
+	return vs.DistanceFromFloat(query)
+}
+
+func minDistance(distFns []PointIdDistFn, y VectorStorePoint) float32 {
+	minDist := distFns[0](y)
+	for _, distFn := range distFns[1:] {
+		minDist = min(minDist, distFn(y))
+	}
+	return minDist
+}
+
+func averageVector(vectors [][]float32) []float32 {
+	avg := make([]float32, len(vectors[0]))
+	for _, v := range vectors {
+		for i := range avg {
+			avg[i] += v[i]
+		}
+	}
+	for i := range avg {
+		avg[i] /= float32(len(vectors))
+	}
+	return avg
+}
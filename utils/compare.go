@@ -52,15 +52,41 @@ func AccessNestedProperty(data map[string]any, path string) (any, bool) {
 	return current, true
 }
 
+// Returns the computed distance of the result for the i-th sort option.
+func sortDistance(r models.SearchResult, i int) (float32, bool) {
+	if i >= len(r.SortDistances) || r.SortDistances[i] == nil {
+		return 0, false
+	}
+	return *r.SortDistances[i], true
+}
+
+func customScore(r models.SearchResult) (float32, bool) {
+	if r.CustomScore == nil {
+		return 0, false
+	}
+	return *r.CustomScore, true
+}
+
 // Attempts to sort search results by the given properties.
 func SortSearchResults(results []models.SearchResult, sortOpts []models.SortOption) {
 	/* Because we don't know the type of the values, this may be a costly
 	 * operation to undertake. We should monitor how this performs. */
 	slices.SortFunc(results, func(a, b models.SearchResult) int {
-		for _, s := range sortOpts {
-			// E.g. s = "age"
-			av, aok := AccessNestedProperty(a.DecodedData, s.Property)
-			bv, bok := AccessNestedProperty(b.DecodedData, s.Property)
+		for i, s := range sortOpts {
+			var av, bv any
+			var aok, bok bool
+			if s.DistanceTo != nil {
+				// The distances are computed upstream, see SortDistances
+				av, aok = sortDistance(a, i)
+				bv, bok = sortDistance(b, i)
+			} else if s.Property == "_customScore" {
+				av, aok = customScore(a)
+				bv, bok = customScore(b)
+			} else {
+				// E.g. s = "age"
+				av, aok = AccessNestedProperty(a.DecodedData, s.Property)
+				bv, bok = AccessNestedProperty(b.DecodedData, s.Property)
+			}
 			/* If the property is missing, we need to decide what to do
 			 * here. We can either put it at the top or bottom. We put it
 			 * at the bottom for now so that missing values are last. */
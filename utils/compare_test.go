@@ -253,3 +253,42 @@ func Test_SortSearchResults(t *testing.T) {
 		})
 	}
 }
+
+func Test_SortSearchResults_Distance(t *testing.T) {
+	near, mid, far := float32(1.5), float32(5), float32(10)
+	results := []models.SearchResult{
+		{NodeId: 1, DecodedData: map[string]any{"age": 1}, SortDistances: []*float32{nil, &far}},
+		{NodeId: 2, DecodedData: map[string]any{"age": 2}},
+		{NodeId: 3, DecodedData: map[string]any{"age": 3}, SortDistances: []*float32{nil, &mid}},
+		{NodeId: 4, DecodedData: map[string]any{"age": 3}, SortDistances: []*float32{nil, &near}},
+	}
+	nodeIds := func() []uint64 {
+		ids := make([]uint64, len(results))
+		for i, r := range results {
+			ids[i] = r.NodeId
+		}
+		return ids
+	}
+	utils.SortSearchResults(results, []models.SortOption{
+		{Property: "age", Descending: true},
+		{Property: "location", DistanceTo: []float32{0, 0}},
+	})
+	require.Equal(t, []uint64{4, 3, 2, 1}, nodeIds())
+	// Points without a distance go last
+	utils.SortSearchResults(results, []models.SortOption{
+		{Property: "gandalf"},
+		{Property: "location", DistanceTo: []float32{0, 0}, Descending: true},
+	})
+	require.Equal(t, []uint64{1, 3, 4, 2}, nodeIds())
+}
+
+func Test_SortSearchResults_CustomScore(t *testing.T) {
+	low, high := float32(-1), float32(2)
+	results := []models.SearchResult{
+		{NodeId: 1, CustomScore: &low},
+		{NodeId: 2},
+		{NodeId: 3, CustomScore: &high},
+	}
+	utils.SortSearchResults(results, []models.SortOption{{Property: "_customScore", Descending: true}})
+	require.Equal(t, []uint64{3, 1, 2}, []uint64{results[0].NodeId, results[1].NodeId, results[2].NodeId})
+}
@@ -0,0 +1,67 @@
+package utils
+
+import "math"
+
+/* MaximalMarginalRelevance greedily picks up to limit candidates, each time
+ * taking the one that is near the query and far from the ones picked so far.
+ * With distances instead of similarities the score of a candidate c is
+ *
+ *   lambda * -queryDists[c] + (1 - lambda) * min distance from c to the picked
+ *
+ * so the first pick is always the nearest candidate. The distFrom function
+ * returns the distances from candidate i to the others, it is called once per
+ * pick. The picked candidate indices are returned in order along with their
+ * scores, which never increase. The diversity term of the first pick is its
+ * largest distance to another candidate so it scores at least the second. */
+func MaximalMarginalRelevance(queryDists []float32, distFrom func(i int) func(j int) float32, lambda float32, limit int) ([]int, []float32) {
+	n := len(queryDists)
+	limit = min(limit, n)
+	picked := make([]bool, n)
+	// Distance of each candidate to the nearest picked one
+	minDists := make([]float32, n)
+	for i := range minDists {
+		minDists[i] = math.MaxFloat32
+	}
+	order := make([]int, 0, limit)
+	scores := make([]float32, 0, limit)
+	for len(order) < limit {
+		best := -1
+		var bestScore float32
+		for c := 0; c < n; c++ {
+			if picked[c] {
+				continue
+			}
+			var diversity float32
+			if len(order) > 0 {
+				diversity = minDists[c]
+			}
+			score := -lambda*queryDists[c] + (1-lambda)*diversity
+			if best == -1 || score > bestScore {
+				best = c
+				bestScore = score
+			}
+		}
+		picked[best] = true
+		order = append(order, best)
+		scores = append(scores, bestScore)
+		if len(order) == limit {
+			break
+		}
+		// ---------------------------
+		distFn := distFrom(best)
+		// Dot product distances can be negative
+		maxDist := float32(-math.MaxFloat32)
+		for c := 0; c < n; c++ {
+			if picked[c] {
+				continue
+			}
+			dist := distFn(c)
+			minDists[c] = min(minDists[c], dist)
+			maxDist = max(maxDist, dist)
+		}
+		if len(order) == 1 {
+			scores[0] += (1 - lambda) * maxDist
+		}
+	}
+	return order, scores
+}
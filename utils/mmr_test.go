@@ -0,0 +1,46 @@
+package utils_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/semafind/semadb/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MaximalMarginalRelevance(t *testing.T) {
+	// The query is at the origin and the first two points are near duplicates
+	points := [][2]float64{{1, 0}, {1.1, 0}, {0, 1.5}, {0, 3}}
+	queryDists := []float32{1, 1.1, 1.5, 3}
+	distFrom := func(i int) func(j int) float32 {
+		return func(j int) float32 {
+			dx, dy := points[i][0]-points[j][0], points[i][1]-points[j][1]
+			return float32(math.Sqrt(dx*dx + dy*dy))
+		}
+	}
+	tests := []struct {
+		name     string
+		lambda   float32
+		limit    int
+		expected []int
+	}{
+		{"relevance only", 1, 4, []int{0, 1, 2, 3}},
+		{"balanced", 0.5, 3, []int{0, 2, 1}},
+		{"diverse", 0.2, 2, []int{0, 3}},
+		{"diversity only", 0, 4, []int{0, 3, 2, 1}},
+		{"limit larger than candidates", 0.2, 10, []int{0, 3, 2, 1}},
+		{"single", 0.5, 1, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, scores := utils.MaximalMarginalRelevance(queryDists, distFrom, tt.lambda, tt.limit)
+			require.Equal(t, tt.expected, order)
+			require.Len(t, scores, len(order))
+			for i := 0; i < len(scores)-1; i++ {
+				require.GreaterOrEqual(t, scores[i], scores[i+1])
+			}
+		})
+	}
+	order, _ := utils.MaximalMarginalRelevance(nil, distFrom, 0.5, 3)
+	require.Empty(t, order)
+}